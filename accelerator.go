@@ -0,0 +1,118 @@
+package pinentry
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A DuplicateAcceleratorError is returned when two button labels have the
+// same keyboard accelerator.
+type DuplicateAcceleratorError struct {
+	Accelerator rune
+	Labels      [2]string
+}
+
+func (e *DuplicateAcceleratorError) Error() string {
+	return fmt.Sprintf("pinentry: duplicate accelerator %q in %q and %q", e.Accelerator, e.Labels[0], e.Labels[1])
+}
+
+// Accelerator returns the keyboard accelerator in label, which is the
+// character following the first single underscore. A literal underscore is
+// written as two underscores.
+func Accelerator(label string) (rune, bool) {
+	for i := 0; i < len(label); i++ {
+		if label[i] != '_' {
+			continue
+		}
+		if i+1 < len(label) && label[i+1] == '_' {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(label[i+1:])
+		if size == 0 {
+			return 0, false
+		}
+		return r, true
+	}
+	return 0, false
+}
+
+// StripAccelerators returns label with its keyboard accelerator markers
+// removed and double underscores replaced with single underscores.
+func StripAccelerators(label string) string {
+	var sb strings.Builder
+	sb.Grow(len(label))
+	for i := 0; i < len(label); i++ {
+		if label[i] == '_' {
+			if i+1 < len(label) && label[i+1] == '_' {
+				sb.WriteByte('_')
+				i++
+			}
+			continue
+		}
+		sb.WriteByte(label[i])
+	}
+	return sb.String()
+}
+
+// ValidateAccelerators returns a *DuplicateAcceleratorError if any two labels
+// have the same keyboard accelerator, ignoring case.
+func ValidateAccelerators(labels ...string) error {
+	labelsByAccelerator := make(map[rune]string)
+	for _, label := range labels {
+		accelerator, ok := Accelerator(label)
+		if !ok {
+			continue
+		}
+		accelerator = unicode.ToLower(accelerator)
+		if otherLabel, ok := labelsByAccelerator[accelerator]; ok {
+			return &DuplicateAcceleratorError{
+				Accelerator: accelerator,
+				Labels:      [2]string{otherLabel, label},
+			}
+		}
+		labelsByAccelerator[accelerator] = label
+	}
+	return nil
+}
+
+// WithButtonLabels sets the OK, Cancel, and NotOK button labels, which may
+// contain keyboard accelerators. Empty labels are not set. NewClient returns a
+// *DuplicateAcceleratorError if two labels have the same accelerator.
+// Accelerators are stripped if the pinentry flavor, determined from the binary
+// name, does not support them.
+func WithButtonLabels(ok, cancel, notOK string) ClientOption {
+	return func(c *Client) {
+		c.buttonLabels = [3]string{ok, cancel, notOK}
+	}
+}
+
+// acceleratorsSupported returns whether the pinentry flavor binaryName
+// supports keyboard accelerators.
+func acceleratorsSupported(binaryName string) bool {
+	switch strings.TrimSuffix(filepath.Base(binaryName), ".exe") {
+	case "pinentry-curses", "pinentry-mac", "pinentry-tty":
+		return false
+	default:
+		return true
+	}
+}
+
+// buttonLabelCommands returns the commands that set the button labels.
+func (c *Client) buttonLabelCommands() []string {
+	var commands []string
+	for i, command := range []string{"SETOK", "SETCANCEL", "SETNOTOK"} {
+		label := c.buttonLabels[i]
+		if label == "" {
+			continue
+		}
+		if !acceleratorsSupported(c.binaryName) {
+			label = StripAccelerators(label)
+		}
+		commands = append(commands, command+" "+escape(label))
+	}
+	return commands
+}
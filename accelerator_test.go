@@ -0,0 +1,50 @@
+package pinentry
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAccelerator(t *testing.T) {
+	for _, tc := range []struct {
+		label               string
+		expectedAccelerator rune
+		expectedOK          bool
+		expectedStripped    string
+	}{
+		{
+			label:            "OK",
+			expectedStripped: "OK",
+		},
+		{
+			label:               "_OK",
+			expectedAccelerator: 'O',
+			expectedOK:          true,
+			expectedStripped:    "OK",
+		},
+		{
+			label:               "snake__case _label",
+			expectedAccelerator: 'l',
+			expectedOK:          true,
+			expectedStripped:    "snake_case label",
+		},
+		{
+			label:               "_Énergie",
+			expectedAccelerator: 'É',
+			expectedOK:          true,
+			expectedStripped:    "Énergie",
+		},
+		{
+			label:            "trailing_",
+			expectedStripped: "trailing",
+		},
+	} {
+		t.Run(tc.label, func(t *testing.T) {
+			accelerator, ok := Accelerator(tc.label)
+			assert.Equal(t, tc.expectedAccelerator, accelerator)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedStripped, StripAccelerators(tc.label))
+		})
+	}
+}
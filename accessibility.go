@@ -0,0 +1,89 @@
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/twpayne/go-pinentry/v4/internal/environ"
+)
+
+// accessibilityTimeoutScale is the factor by which timeouts are extended in
+// accessibility mode.
+const accessibilityTimeoutScale = 3
+
+// AccessibilityEnabled returns whether the environment indicates that
+// assistive technologies, such as a screen reader, or a high contrast theme are
+// in use.
+func AccessibilityEnabled() bool {
+	return accessibilityEnabled(os.Getenv)
+}
+
+// WithAccessibility enables a prompting mode that works better with assistive
+// technologies: it disables keyboard grabbing, which prevents screen readers
+// from receiving key events, triples all timeouts, and, if the binary name has
+// not been set, prefers pinentry flavors that are known to work with assistive
+// technologies.
+func WithAccessibility() ClientOption {
+	binaryName, ok := accessibleBinaryName(exec.LookPath, runtime.GOOS, environ.Graphical(os.Getenv))
+	return func(c *Client) {
+		if ok && c.binaryName == defaultBinaryName {
+			c.binaryName = binaryName
+		}
+		if !slices.Contains(c.args, "--no-global-grab") {
+			c.args = append(c.args, "--no-global-grab")
+		}
+		c.timeoutScale = accessibilityTimeoutScale
+	}
+}
+
+// WithAccessibilityFromEnvironment enables accessibility mode if
+// AccessibilityEnabled returns true.
+func WithAccessibilityFromEnvironment() ClientOption {
+	if !AccessibilityEnabled() {
+		return nil
+	}
+	return WithAccessibility()
+}
+
+// accessibilityEnabled returns whether the environment indicates that
+// accessibility features are in use.
+func accessibilityEnabled(getenv func(string) string) bool {
+	for _, module := range strings.Split(getenv("GTK_MODULES"), ":") {
+		switch module {
+		case "gail", "atk-bridge":
+			return true
+		}
+	}
+	if getenv("QT_ACCESSIBILITY") == "1" || getenv("ACCESSIBILITY_ENABLED") == "1" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(getenv("GTK_THEME")), "highcontrast") {
+		return true
+	}
+	return false
+}
+
+// accessibleBinaryName returns the first available pinentry flavor that is
+// known to work with assistive technologies.
+func accessibleBinaryName(lookPath func(string) (string, error), goos string, graphical bool) (string, bool) {
+	var binaryNames []string
+	switch {
+	case goos == "darwin":
+		binaryNames = []string{"pinentry-mac"}
+	case goos == "windows":
+		binaryNames = []string{"pinentry-qt", "pinentry-w32"}
+	case graphical:
+		binaryNames = []string{"pinentry-gnome3", "pinentry-qt"}
+	default:
+		binaryNames = []string{"pinentry-tty"}
+	}
+	for _, binaryName := range binaryNames {
+		if _, err := lookPath(binaryName); err == nil {
+			return binaryName, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,95 @@
+package pinentry
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAccessibilityEnabled(t *testing.T) {
+	for i, tc := range []struct {
+		env      map[string]string
+		expected bool
+	}{
+		{},
+		{
+			env: map[string]string{
+				"GTK_MODULES": "canberra-gtk-module:atk-bridge",
+			},
+			expected: true,
+		},
+		{
+			env: map[string]string{
+				"QT_ACCESSIBILITY": "1",
+			},
+			expected: true,
+		},
+		{
+			env: map[string]string{
+				"GTK_THEME": "HighContrastInverse",
+			},
+			expected: true,
+		},
+		{
+			env: map[string]string{
+				"GTK_MODULES": "canberra-gtk-module",
+				"GTK_THEME":   "Adwaita:dark",
+			},
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tc.expected, accessibilityEnabled(func(key string) string {
+				return tc.env[key]
+			}))
+		})
+	}
+}
+
+func TestAccessibleBinaryName(t *testing.T) {
+	for i, tc := range []struct {
+		available          []string
+		goos               string
+		graphical          bool
+		expectedBinaryName string
+		expectedOK         bool
+	}{
+		{
+			goos: "linux",
+		},
+		{
+			available:          []string{"pinentry-qt", "pinentry-gnome3", "pinentry-tty"},
+			goos:               "linux",
+			graphical:          true,
+			expectedBinaryName: "pinentry-gnome3",
+			expectedOK:         true,
+		},
+		{
+			available:          []string{"pinentry-qt", "pinentry-gnome3", "pinentry-tty"},
+			goos:               "linux",
+			expectedBinaryName: "pinentry-tty",
+			expectedOK:         true,
+		},
+		{
+			available:          []string{"pinentry-mac"},
+			goos:               "darwin",
+			expectedBinaryName: "pinentry-mac",
+			expectedOK:         true,
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			lookPath := func(file string) (string, error) {
+				for _, available := range tc.available {
+					if file == available {
+						return "/usr/bin/" + file, nil
+					}
+				}
+				return "", errors.New("not found")
+			}
+			actualBinaryName, actualOK := accessibleBinaryName(lookPath, tc.goos, tc.graphical)
+			assert.Equal(t, tc.expectedBinaryName, actualBinaryName)
+			assert.Equal(t, tc.expectedOK, actualOK)
+		})
+	}
+}
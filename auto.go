@@ -0,0 +1,45 @@
+package pinentry
+
+import (
+	"os"
+	"runtime"
+
+	"github.com/twpayne/go-pinentry/v4/internal/environ"
+)
+
+// WithAutoBinaryName selects the pinentry flavor from the session type. In a
+// local graphical session, graphical flavors are preferred. Over SSH or
+// without a display, terminal flavors on the current tty are preferred. The
+// candidates are tried in order as with WithBinaryNames.
+func WithAutoBinaryName() ClientOption {
+	ttyName, hasTTY := cursesTTYName()
+	binaryNames, terminal := autoBinaryNames(os.Getenv, runtime.GOOS, hasTTY)
+	return func(c *Client) {
+		WithBinaryNames(binaryNames)(c)
+		if terminal {
+			c.args = append(c.args, "--ttyname", ttyName)
+		}
+	}
+}
+
+// autoBinaryNames returns the candidate pinentry binary names for the
+// session, and whether they are terminal flavors.
+func autoBinaryNames(getenv func(string) string, goos string, hasTTY bool) ([]string, bool) {
+	switch goos {
+	case "darwin":
+		return []string{"pinentry-mac", "pinentry"}, false
+	case "windows":
+		return []string{"pinentry-qt", "pinentry-w32", "pinentry"}, false
+	}
+	graphical := environ.Graphical(getenv) && getenv("XDG_SESSION_TYPE") != "tty"
+	switch {
+	case environ.PreferTerminal(getenv, hasTTY):
+		return []string{"pinentry-curses", "pinentry-tty", "pinentry"}, true
+	case graphical && getenv("XDG_SESSION_TYPE") == "wayland":
+		return []string{"pinentry-gnome3", "pinentry-qt", "pinentry-gtk-2", "pinentry"}, false
+	case graphical:
+		return []string{"pinentry-gtk-2", "pinentry-gnome3", "pinentry-qt", "pinentry"}, false
+	default:
+		return []string{defaultBinaryName}, false
+	}
+}
@@ -0,0 +1,85 @@
+package pinentry
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestAutoBinaryNames(t *testing.T) {
+	for i, tc := range []struct {
+		env              map[string]string
+		goos             string
+		hasTTY           bool
+		expected         []string
+		expectedTerminal bool
+	}{
+		{
+			goos:     "darwin",
+			expected: []string{"pinentry-mac", "pinentry"},
+		},
+		{
+			goos:     "windows",
+			expected: []string{"pinentry-qt", "pinentry-w32", "pinentry"},
+		},
+		{
+			env: map[string]string{
+				"WAYLAND_DISPLAY":  "wayland-0",
+				"XDG_SESSION_TYPE": "wayland",
+			},
+			goos:     "linux",
+			hasTTY:   true,
+			expected: []string{"pinentry-gnome3", "pinentry-qt", "pinentry-gtk-2", "pinentry"},
+		},
+		{
+			env: map[string]string{
+				"DISPLAY":          ":0",
+				"XDG_SESSION_TYPE": "x11",
+			},
+			goos:     "linux",
+			expected: []string{"pinentry-gtk-2", "pinentry-gnome3", "pinentry-qt", "pinentry"},
+		},
+		{
+			env: map[string]string{
+				"DISPLAY":        "localhost:10.0",
+				"SSH_CONNECTION": "192.0.2.1 51234 192.0.2.2 22",
+			},
+			goos:             "linux",
+			hasTTY:           true,
+			expected:         []string{"pinentry-curses", "pinentry-tty", "pinentry"},
+			expectedTerminal: true,
+		},
+		{
+			env: map[string]string{
+				"DISPLAY":        "localhost:10.0",
+				"SSH_CONNECTION": "192.0.2.1 51234 192.0.2.2 22",
+			},
+			goos:     "linux",
+			expected: []string{"pinentry-gtk-2", "pinentry-gnome3", "pinentry-qt", "pinentry"},
+		},
+		{
+			env: map[string]string{
+				"DISPLAY":          ":0",
+				"XDG_SESSION_TYPE": "tty",
+			},
+			goos:             "linux",
+			hasTTY:           true,
+			expected:         []string{"pinentry-curses", "pinentry-tty", "pinentry"},
+			expectedTerminal: true,
+		},
+		{
+			goos:     "linux",
+			expected: []string{"pinentry"},
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			getenv := func(key string) string {
+				return tc.env[key]
+			}
+			actual, actualTerminal := autoBinaryNames(getenv, tc.goos, tc.hasTTY)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.expectedTerminal, actualTerminal)
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package pinentry
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// A Broker accepts prompt requests from many goroutines and presents them to
+// the user one at a time, highest priority first and in the order that they
+// were requested for equal priorities. It starts the pinentry process when
+// needed, reuses it for consecutive requests, resetting its state between
+// them, and closes it when it has been idle for the idle timeout.
+type Broker struct {
+	clientOptions []ClientOption
+	idleTimeout   time.Duration
+
+	mu        sync.Mutex
+	queue     brokerQueue
+	seq       uint64
+	busy      bool
+	closed    bool
+	client    *Client
+	idleTimer *time.Timer
+}
+
+// A BrokerOption sets an option on a Broker.
+type BrokerOption func(*Broker)
+
+// A BrokerRequest is a request for a prompt made through a Broker. Empty texts
+// are left unchanged.
+type BrokerRequest struct {
+	Priority int
+	Title    string
+	Desc     string
+	Prompt   string
+	Error    string
+	KeyInfo  string
+}
+
+// A brokerTicket is a request waiting for its turn.
+type brokerTicket struct {
+	priority int
+	seq      uint64
+	index    int
+	ready    chan struct{}
+	err      error
+}
+
+// A brokerQueue is a priority queue of tickets.
+type brokerQueue []*brokerTicket
+
+// WithBrokerClientOptions sets the options used to create each Client.
+func WithBrokerClientOptions(options ...ClientOption) BrokerOption {
+	return func(b *Broker) {
+		b.clientOptions = append(b.clientOptions, options...)
+	}
+}
+
+// WithBrokerIdleTimeout sets how long the pinentry process is kept running
+// after the last request so that it can be reused. The default is to close it
+// as soon as there are no more requests.
+func WithBrokerIdleTimeout(idleTimeout time.Duration) BrokerOption {
+	return func(b *Broker) {
+		b.idleTimeout = idleTimeout
+	}
+}
+
+// NewBroker returns a new Broker with the given options.
+func NewBroker(options ...BrokerOption) *Broker {
+	b := &Broker{}
+	for _, option := range options {
+		if option != nil {
+			option(b)
+		}
+	}
+	return b
+}
+
+// Close closes b. Waiting requests return ErrBrokerClosed, the current
+// request, if any, is cancelled, and the pinentry process is closed.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for b.queue.Len() > 0 {
+		ticket := heap.Pop(&b.queue).(*brokerTicket) //nolint:forcetypeassert
+		ticket.err = ErrBrokerClosed
+		close(ticket.ready)
+	}
+	b.stopIdleTimer()
+	client := b.client
+	if b.busy {
+		// The pinentry process is closed when the current request releases
+		// its turn.
+		b.mu.Unlock()
+		if client != nil {
+			return client.Cancel()
+		}
+		return nil
+	}
+	b.client = nil
+	b.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.Close()
+}
+
+// Confirm asks the user for confirmation when request's turn comes. If ctx is
+// done first then ctx's error is returned.
+func (b *Broker) Confirm(ctx context.Context, request BrokerRequest) (bool, error) {
+	var confirmed bool
+	err := b.Do(ctx, request.Priority, func(c *Client) error {
+		if err := request.apply(c); err != nil {
+			return err
+		}
+		var err error
+		confirmed, err = c.ConfirmContext(ctx, "")
+		return err
+	})
+	return confirmed, err
+}
+
+// Do calls f with a Client when it is the turn of a request with priority. f
+// has exclusive use of the Client until it returns and must not retain it. If
+// ctx is done before the request's turn comes then ctx's error is returned.
+// Requests made after b is closed return ErrBrokerClosed.
+func (b *Broker) Do(ctx context.Context, priority int, f func(*Client) error) error {
+	ticket, err := b.enqueue(priority)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-ticket.ready:
+	case <-ctx.Done():
+		if b.dequeue(ticket) {
+			return ctx.Err()
+		}
+		<-ticket.ready
+		if ticket.err == nil {
+			b.release()
+		}
+		return ctx.Err()
+	}
+	if ticket.err != nil {
+		return ticket.err
+	}
+
+	defer b.release()
+	client, err := b.acquireClient(ctx)
+	if err != nil {
+		return err
+	}
+	err = f(client)
+	if client.killed.Load() || errors.Is(err, ErrPinentryTerminated) {
+		b.discardClient()
+	}
+	return err
+}
+
+// GetPIN gets a PIN from the user when request's turn comes. If ctx is done
+// first then ctx's error is returned.
+func (b *Broker) GetPIN(ctx context.Context, request BrokerRequest) (GetPINResult, error) {
+	var result GetPINResult
+	err := b.Do(ctx, request.Priority, func(c *Client) error {
+		if err := request.apply(c); err != nil {
+			return err
+		}
+		var err error
+		result, err = c.GetPINContext(ctx)
+		return err
+	})
+	return result, err
+}
+
+// Len returns the number of requests waiting for their turn.
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.queue.Len()
+}
+
+// acquireClient returns the current Client, after resetting its state, or a
+// new Client if there is none or its state cannot be reset.
+func (b *Broker) acquireClient(ctx context.Context) (*Client, error) {
+	b.mu.Lock()
+	client := b.client
+	b.mu.Unlock()
+
+	if client != nil {
+		if err := client.ResetState(); err == nil {
+			return client, nil
+		}
+		b.discardClient()
+	}
+
+	client, err := NewClientContext(ctx, b.clientOptions...)
+	if err != nil {
+		return nil, err
+	}
+	b.mu.Lock()
+	closed := b.closed
+	if !closed {
+		b.client = client
+	}
+	b.mu.Unlock()
+	if closed {
+		return nil, combineErrors(ErrBrokerClosed, client.Close())
+	}
+	return client, nil
+}
+
+// closeIdle closes the Client if b is idle.
+func (b *Broker) closeIdle() {
+	b.mu.Lock()
+	if b.busy || b.client == nil {
+		b.mu.Unlock()
+		return
+	}
+	client := b.client
+	b.client = nil
+	b.mu.Unlock()
+	_ = client.Close()
+}
+
+// discardClient closes and forgets the current Client.
+func (b *Broker) discardClient() {
+	b.mu.Lock()
+	client := b.client
+	b.client = nil
+	b.mu.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+}
+
+// dequeue removes ticket from the queue, returning false if it has already
+// been given its turn.
+func (b *Broker) dequeue(ticket *brokerTicket) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ticket.index < 0 {
+		return false
+	}
+	heap.Remove(&b.queue, ticket.index)
+	return true
+}
+
+// enqueue adds a request with priority to the queue and returns its ticket.
+// If b is not busy then the ticket is given its turn immediately.
+func (b *Broker) enqueue(priority int) (*brokerTicket, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrBrokerClosed
+	}
+	b.seq++
+	ticket := &brokerTicket{
+		priority: priority,
+		seq:      b.seq,
+		index:    -1,
+		ready:    make(chan struct{}),
+	}
+	if b.busy {
+		heap.Push(&b.queue, ticket)
+		return ticket, nil
+	}
+	b.busy = true
+	b.stopIdleTimer()
+	close(ticket.ready)
+	return ticket, nil
+}
+
+// release ends the current turn and gives the next ticket its turn. If there
+// are no waiting tickets then the Client is closed, either immediately or after
+// the idle timeout.
+func (b *Broker) release() {
+	b.mu.Lock()
+	var client *Client
+	switch {
+	case b.queue.Len() > 0:
+		ticket := heap.Pop(&b.queue).(*brokerTicket) //nolint:forcetypeassert
+		close(ticket.ready)
+	case b.client == nil:
+		b.busy = false
+	case b.closed || b.idleTimeout <= 0:
+		b.busy = false
+		client, b.client = b.client, nil
+	default:
+		b.busy = false
+		b.idleTimer = time.AfterFunc(b.idleTimeout, b.closeIdle)
+	}
+	b.mu.Unlock()
+	if client != nil {
+		_ = client.Close()
+	}
+}
+
+// stopIdleTimer stops the idle timer, if any.
+func (b *Broker) stopIdleTimer() {
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+		b.idleTimer = nil
+	}
+}
+
+// apply sets the texts in r on c.
+func (r BrokerRequest) apply(c *Client) error {
+	for _, set := range []struct {
+		value string
+		f     func(string) error
+	}{
+		{value: r.Title, f: c.SetTitle},
+		{value: r.Desc, f: c.SetDesc},
+		{value: r.Prompt, f: c.SetPrompt},
+		{value: r.Error, f: c.SetError},
+		{value: r.KeyInfo, f: c.SetKeyInfo},
+	} {
+		if set.value == "" {
+			continue
+		}
+		if err := set.f(set.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q brokerQueue) Len() int {
+	return len(q)
+}
+
+func (q brokerQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q brokerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *brokerQueue) Push(x any) {
+	ticket := x.(*brokerTicket) //nolint:forcetypeassert
+	ticket.index = len(*q)
+	*q = append(*q, ticket)
+}
+
+func (q *brokerQueue) Pop() any {
+	old := *q
+	n := len(old)
+	ticket := old[n-1]
+	old[n-1] = nil
+	ticket.index = -1
+	*q = old[:n-1]
+	return ticket
+}
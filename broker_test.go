@@ -0,0 +1,116 @@
+package pinentry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-pinentry/v4"
+)
+
+func TestBroker(t *testing.T) {
+	p := newMockProcess(t)
+
+	b := pinentry.NewBroker(
+		pinentry.WithBrokerClientOptions(
+			pinentry.WithProcess(p),
+		),
+		pinentry.WithBrokerIdleTimeout(time.Hour),
+	)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETDESC desc")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	result, err := b.GetPIN(context.Background(), pinentry.BrokerRequest{
+		Desc: "desc",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+
+	p.expectWritelnOK("RESET")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	confirmed, err := b.Confirm(context.Background(), pinentry.BrokerRequest{})
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+
+	p.expectClose()
+	assert.NoError(t, b.Close())
+
+	_, err = b.GetPIN(context.Background(), pinentry.BrokerRequest{})
+	assert.IsError(t, err, pinentry.ErrBrokerClosed)
+}
+
+func TestBrokerPriority(t *testing.T) {
+	p := newMockProcess(t)
+
+	b := pinentry.NewBroker(
+		pinentry.WithBrokerClientOptions(
+			pinentry.WithProcess(p),
+		),
+	)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("RESET")
+	p.expectWritelnOK("RESET")
+	p.expectWritelnOK("RESET")
+	p.expectClose()
+
+	var order []string
+	first := make(chan struct{})
+	release := make(chan struct{})
+	errCh := make(chan error, 4)
+	go func() {
+		errCh <- b.Do(context.Background(), 0, func(*pinentry.Client) error {
+			close(first)
+			<-release
+			order = append(order, "first")
+			return nil
+		})
+	}()
+	<-first
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for _, request := range []struct {
+		ctx      context.Context //nolint:containedctx
+		name     string
+		priority int
+	}{
+		{ctx: context.Background(), name: "low", priority: 0},
+		{ctx: ctx, name: "cancelled", priority: 2},
+		{ctx: context.Background(), name: "high", priority: 1},
+		{ctx: context.Background(), name: "low2", priority: 0},
+	} {
+		request := request
+		queued := b.Len()
+		go func() {
+			errCh <- b.Do(request.ctx, request.priority, func(*pinentry.Client) error {
+				order = append(order, request.name)
+				return nil
+			})
+		}()
+		for b.Len() == queued {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	cancel()
+	for b.Len() != 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	var cancelledErrs int
+	for i := 0; i < 5; i++ {
+		if err := <-errCh; err != nil {
+			assert.IsError(t, err, context.Canceled)
+			cancelledErrs++
+		}
+	}
+	assert.Equal(t, 1, cancelledErrs)
+	assert.Equal(t, []string{"first", "high", "low", "low2"}, order)
+	assert.NoError(t, b.Close())
+}
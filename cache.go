@@ -0,0 +1,145 @@
+package pinentry
+
+import (
+	"sync"
+	"time"
+)
+
+// A CacheBackend stores passphrases keyed by key info for WithPassphraseCache.
+// Get returns a copy of the passphrase for keyInfo, which the caller owns, and
+// whether it was found. Put stores a copy of passphrase for keyInfo. Invalidate
+// removes the passphrase for keyInfo, if any.
+type CacheBackend interface {
+	Get(keyInfo string) (*Secret, bool, error)
+	Put(keyInfo string, passphrase []byte) error
+	Invalidate(keyInfo string) error
+}
+
+// A PassphraseCache caches passphrases in memory for a limited time, keyed by
+// the key info set with WithKeyInfo or Client.SetKeyInfo. Passphrases are
+// zeroed when they expire or are invalidated. A PassphraseCache may be shared
+// by several Clients.
+type PassphraseCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*passphraseCacheEntry
+}
+
+// A passphraseCacheEntry is a cached passphrase.
+type passphraseCacheEntry struct {
+	secret *Secret
+	timer  *time.Timer
+}
+
+// NewPassphraseCache returns a new PassphraseCache that caches passphrases
+// for ttl.
+func NewPassphraseCache(ttl time.Duration) *PassphraseCache {
+	return &PassphraseCache{
+		ttl:     ttl,
+		entries: make(map[string]*passphraseCacheEntry),
+	}
+}
+
+// WithPassphraseCache sets the client to return passphrases from cache, when
+// the key info is set, instead of prompting the user, and to store
+// passphrases entered by the user in cache. Passphrases returned from cache
+// have PasswordFromCache set in their GetPINResult. Client.ClearPassphrase
+// also invalidates the passphrase in cache. Errors from cache other than from
+// Invalidate are logged and otherwise ignored.
+func WithPassphraseCache(cache CacheBackend) ClientOption {
+	return func(c *Client) {
+		c.passphraseCache = cache
+	}
+}
+
+// Get implements CacheBackend.Get.
+func (pc *PassphraseCache) Get(keyInfo string) (*Secret, bool, error) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	entry, ok := pc.entries[keyInfo]
+	if !ok {
+		return nil, false, nil
+	}
+	return NewSecret(append([]byte(nil), entry.secret.Bytes()...)), true, nil
+}
+
+// Invalidate implements CacheBackend.Invalidate.
+func (pc *PassphraseCache) Invalidate(keyInfo string) error {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if entry, ok := pc.entries[keyInfo]; ok {
+		pc.remove(keyInfo, entry)
+	}
+	return nil
+}
+
+// InvalidateAll removes all passphrases from pc.
+func (pc *PassphraseCache) InvalidateAll() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	for keyInfo, entry := range pc.entries {
+		pc.remove(keyInfo, entry)
+	}
+}
+
+// Put implements CacheBackend.Put.
+func (pc *PassphraseCache) Put(keyInfo string, passphrase []byte) error {
+	if pc.ttl <= 0 {
+		return nil
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if entry, ok := pc.entries[keyInfo]; ok {
+		pc.remove(keyInfo, entry)
+	}
+	entry := &passphraseCacheEntry{
+		secret: NewSecret(append([]byte(nil), passphrase...)),
+	}
+	entry.timer = time.AfterFunc(pc.ttl, func() {
+		pc.mu.Lock()
+		defer pc.mu.Unlock()
+		if pc.entries[keyInfo] == entry {
+			pc.remove(keyInfo, entry)
+		}
+	})
+	pc.entries[keyInfo] = entry
+	return nil
+}
+
+// remove removes entry for keyInfo from pc and zeroes its passphrase. pc.mu
+// must be held.
+func (pc *PassphraseCache) remove(keyInfo string, entry *passphraseCacheEntry) {
+	entry.timer.Stop()
+	entry.secret.Zero()
+	delete(pc.entries, keyInfo)
+}
+
+// cachedPIN returns the passphrase for the current key info from the
+// passphrase cache, if any.
+func (c *Client) cachedPIN() (*Secret, bool) {
+	if c.passphraseCache == nil || c.keyInfo == "" {
+		return nil, false
+	}
+	secret, ok, err := c.passphraseCache.Get(c.keyInfo)
+	logErrorOrInfo(c.logger, "cachedPIN", err, "keyInfo", c.keyInfo, "ok", ok)
+	return secret, ok && err == nil
+}
+
+// cachePIN stores pin for the current key info in the passphrase cache, if
+// any.
+func (c *Client) cachePIN(pin []byte) {
+	if c.passphraseCache == nil || c.keyInfo == "" {
+		return
+	}
+	err := c.passphraseCache.Put(c.keyInfo, pin)
+	logErrorOrInfo(c.logger, "cachePIN", err, "keyInfo", c.keyInfo)
+}
+
+// invalidateCachedPIN removes the passphrase for keyInfo from the passphrase
+// cache, if any.
+func (c *Client) invalidateCachedPIN(keyInfo string) error {
+	if c.passphraseCache == nil {
+		return nil
+	}
+	return c.passphraseCache.Invalidate(keyInfo)
+}
@@ -0,0 +1,41 @@
+package pinentry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestPassphraseCache(t *testing.T) {
+	cache := NewPassphraseCache(50 * time.Millisecond)
+
+	_, ok, err := cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	passphrase := []byte("abc")
+	assert.NoError(t, cache.Put("n/0123", passphrase))
+	passphrase[0] = 'x'
+
+	secret, ok, err := cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("abc"), secret.Bytes())
+	secret.Zero()
+
+	cache.mu.Lock()
+	cachedPassphrase := cache.entries["n/0123"].secret.Bytes()
+	cache.mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	_, ok, err = cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []byte{0, 0, 0}, cachedPassphrase)
+
+	assert.NoError(t, cache.Put("n/0123", []byte("def")))
+	assert.NoError(t, cache.Invalidate("n/0123"))
+	_, ok, err = cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,35 @@
+package pinentry
+
+import "errors"
+
+// Cancel aborts any pending prompt, for example when the thing being unlocked
+// is no longer needed, by killing the pinentry process. It may be called from
+// any goroutine. The pending call, and any subsequent calls, return an error
+// for which IsCancelled returns true. The client must still be closed. Cancel
+// requires that the process supports being killed, which the default process
+// does, and returns errors.ErrUnsupported otherwise.
+func (c *Client) Cancel() error {
+	killer, ok := c.process.(interface{ Kill() error })
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	c.cancelled.Store(true)
+	c.killed.Store(true)
+	err := killer.Kill()
+	logErrorOrInfo(c.logger, "cancel", err)
+	return err
+}
+
+// cancelledError returns an operation cancelled error if err is non-nil and c
+// has been cancelled, recording that the pinentry process was killed.
+// Otherwise, it returns err.
+func (c *Client) cancelledError(err error) error {
+	if err == nil || !c.cancelled.Load() {
+		return err
+	}
+	c.killed.Store(true)
+	return &AssuanError{
+		Code:        AssuanErrorCodeCancelled,
+		Description: "pinentry: cancelled",
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package pinentry
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func setChroot(*exec.Cmd, string) error {
+	return errors.ErrUnsupported
+}
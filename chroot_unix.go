@@ -0,0 +1,18 @@
+//go:build unix
+
+package pinentry
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setChroot configures cmd to run with its root directory changed to dir.
+func setChroot(cmd *exec.Cmd, dir string) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Chroot = dir
+	cmd.Dir = "/"
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package pinentry
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSetChroot(t *testing.T) {
+	cmd := exec.Command("/usr/bin/pinentry")
+	assert.NoError(t, setChroot(cmd, "/var/lib/pinentry"))
+	assert.Equal(t, "/var/lib/pinentry", cmd.SysProcAttr.Chroot)
+	assert.Equal(t, "/", cmd.Dir)
+}
@@ -3,7 +3,14 @@
 package pinentry_test
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -26,6 +33,55 @@ func TestClientClose(t *testing.T) {
 	assert.NoError(t, c.Close())
 }
 
+func TestClientAccessibility(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", []string{"--no-global-grab"})
+	p.expectWritelnOK("SETTIMEOUT 180")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithNoGlobalGrab(),
+		pinentry.WithAccessibility(),
+		pinentry.WithBinaryName("pinentry"),
+		pinentry.WithTimeout(time.Minute),
+		pinentry.WithGetPINTimeout(2*time.Minute),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 360")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestCheckAvailable(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectClose()
+	assert.NoError(t, pinentry.CheckAvailable(
+		pinentry.WithProcess(p),
+		pinentry.WithDesc("desc"),
+	))
+}
+
+func TestCheckAvailableNotFound(t *testing.T) {
+	err := pinentry.CheckAvailable(
+		pinentry.WithBinaryName("pinentry-does-not-exist"),
+	)
+	assert.True(t, errors.Is(err, exec.ErrNotFound))
+	assert.IsError(t, err, pinentry.ErrPinentryNotFound)
+	var notFoundErr *pinentry.PinentryNotFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, []string{"pinentry-does-not-exist"}, notFoundErr.BinaryNames)
+	assert.NotZero(t, notFoundErr.Suggestions)
+}
+
 func TestClientArgs(t *testing.T) {
 	for i, tc := range []struct {
 		clientOptions []pinentry.ClientOption
@@ -43,6 +99,17 @@ func TestClientArgs(t *testing.T) {
 				"--arg2",
 			},
 		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithCursesColors(pinentry.CursesColors{
+					Foreground: pinentry.CursesColorRed.Bright(),
+					Background: pinentry.CursesColorBlack,
+				}),
+			},
+			expectedArgs: []string{
+				"--colors", "bright-red,black,default",
+			},
+		},
 		{
 			clientOptions: []pinentry.ClientOption{
 				pinentry.WithDebug(),
@@ -51,6 +118,30 @@ func TestClientArgs(t *testing.T) {
 				"--debug",
 			},
 		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithDisplayArg(":0"),
+			},
+			expectedArgs: []string{
+				"--display", ":0",
+			},
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithGlobalGrab(),
+			},
+			expectedArgs: []string{
+				"--grab",
+			},
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithLCCTypeArg("en_US.UTF-8"),
+			},
+			expectedArgs: []string{
+				"--lc-ctype", "en_US.UTF-8",
+			},
+		},
 		{
 			clientOptions: []pinentry.ClientOption{
 				pinentry.WithNoGlobalGrab(),
@@ -59,6 +150,30 @@ func TestClientArgs(t *testing.T) {
 				"--no-global-grab",
 			},
 		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithTTYAlert(pinentry.TTYAlertFlash),
+			},
+			expectedArgs: []string{
+				"--ttyalert", "flash",
+			},
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithTTYNameArg("/dev/pts/0"),
+			},
+			expectedArgs: []string{
+				"--ttyname", "/dev/pts/0",
+			},
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithTTYTypeArg("xterm"),
+			},
+			expectedArgs: []string{
+				"--ttytype", "xterm",
+			},
+		},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			p := newMockProcess(t)
@@ -89,6 +204,81 @@ func TestClientBinaryName(t *testing.T) {
 	assert.NoError(t, c.Close())
 }
 
+func TestClientButtonLabels(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry-gnome3", nil)
+	p.expectWritelnOK("SETOK _Unlock")
+	p.expectWritelnOK("SETCANCEL _Cancel")
+	c, err := pinentry.NewClient(
+		pinentry.WithBinaryName("pinentry-gnome3"),
+		pinentry.WithButtonLabels("_Unlock", "_Cancel", ""),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p.expectStart("pinentry-curses", nil)
+	p.expectWritelnOK("SETOK Unlock")
+	p.expectWritelnOK("SETNOTOK Do_not unlock")
+	c, err = pinentry.NewClient(
+		pinentry.WithBinaryName("pinentry-curses"),
+		pinentry.WithButtonLabels("_Unlock", "", "_Do__not unlock"),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	_, err = pinentry.NewClient(
+		pinentry.WithButtonLabels("_OK", "Can_cel", "_Other"),
+		pinentry.WithProcess(p),
+	)
+	assert.Equal(t, error(&pinentry.DuplicateAcceleratorError{
+		Accelerator: 'o',
+		Labels:      [2]string{"_OK", "_Other"},
+	}), err)
+}
+
+func TestClientFormattedPassphrase(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("OPTION formatted-passphrase")
+	p.expectWritelnOK("OPTION formatted-passphrase-hint=Blanks are not part of the passphrase.%0A")
+	c, err := pinentry.NewClient(
+		pinentry.WithFormattedPassphrase("Blanks are not part of the passphrase.\n"),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("OPTION formatted-passphrase")
+	c, err = pinentry.NewClient(
+		pinentry.WithFormattedPassphrase(""),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETDESC desc")
+	p.expectWriteln("OPTION formatted-passphrase")
+	p.expectReadLine("ERR 83886254 Unknown option <Pinentry>")
+	c, err = pinentry.NewClient(
+		pinentry.WithDesc("desc"),
+		pinentry.WithFormattedPassphrase("Blanks are not part of the passphrase."),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
 func TestClientCommands(t *testing.T) {
 	for i, tc := range []struct {
 		clientOptions   []pinentry.ClientOption
@@ -100,6 +290,30 @@ func TestClientCommands(t *testing.T) {
 			},
 			expectedCommand: "SETCANCEL cancel",
 		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithDefaultCancel("_Cancel"),
+			},
+			expectedCommand: "OPTION default-cancel=_Cancel",
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithDefaultOK("_OK"),
+			},
+			expectedCommand: "OPTION default-ok=_OK",
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithDefaultPasswordManager("_Save in password manager"),
+			},
+			expectedCommand: "OPTION default-pwmngr=_Save in password manager",
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithDefaultPrompt("PIN:"),
+			},
+			expectedCommand: "OPTION default-prompt=PIN:",
+		},
 		{
 			clientOptions: []pinentry.ClientOption{
 				pinentry.WithDesc("desc"),
@@ -144,17 +358,17 @@ func TestClientCommands(t *testing.T) {
 		},
 		{
 			clientOptions: []pinentry.ClientOption{
-				pinentry.WithOption("option"),
+				pinentry.WithOption("no-grab"),
 			},
-			expectedCommand: "OPTION option",
+			expectedCommand: "OPTION no-grab",
 		},
 		{
 			clientOptions: []pinentry.ClientOption{
 				pinentry.WithOptions([]string{
-					"option",
+					"lc-messages=C",
 				}),
 			},
-			expectedCommand: "OPTION option",
+			expectedCommand: "OPTION lc-messages=C",
 		},
 		{
 			clientOptions: []pinentry.ClientOption{
@@ -198,6 +412,18 @@ func TestClientCommands(t *testing.T) {
 			},
 			expectedCommand: "SETTITLE title",
 		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithUncheckedOption("option"),
+			},
+			expectedCommand: "OPTION option",
+		},
+		{
+			clientOptions: []pinentry.ClientOption{
+				pinentry.WithTitle("title\x1b[2J\u202e"),
+			},
+			expectedCommand: "SETTITLE title[2J",
+		},
 	} {
 		t.Run(strconv.Itoa(i), func(t *testing.T) {
 			p := newMockProcess(t)
@@ -215,67 +441,73 @@ func TestClientCommands(t *testing.T) {
 	}
 }
 
-func TestClientClearPassphrase(t *testing.T) {
+func TestClientGreetingTimeout(t *testing.T) {
 	p := newMockProcess(t)
 
-	p.expectStart("pinentry", nil)
-	c, err := pinentry.NewClient(
+	var startErr error
+	p.EXPECT().Start("pinentry", nil).Return(nil)
+	p.expectReadLineAfter("OK Pleased to meet you", 100*time.Millisecond)
+	p.EXPECT().Close().Return(nil)
+	_, err := pinentry.NewClient(
 		pinentry.WithProcess(p),
+		pinentry.WithGreetingTimeout(10*time.Millisecond),
+		pinentry.WithStartErrorFunc(func(err error) {
+			startErr = err
+		}),
 	)
-	assert.NoError(t, err)
-
-	p.expectWriteln("CLEARPASSPHRASE cacheID")
-	p.expectReadLine("OK")
-	assert.NoError(t, c.ClearPassphrase("cacheID"))
-
-	p.expectClose()
-	assert.NoError(t, c.Close())
+	assert.IsError(t, err, os.ErrDeadlineExceeded)
+	assert.IsError(t, startErr, os.ErrDeadlineExceeded)
 }
 
-func TestClientGetPIN(t *testing.T) {
+func TestClientReadTimeout(t *testing.T) {
 	p := newMockProcess(t)
 
 	p.expectStart("pinentry", nil)
 	c, err := pinentry.NewClient(
 		pinentry.WithProcess(p),
+		pinentry.WithReadTimeout(10*time.Millisecond),
 	)
 	assert.NoError(t, err)
 
-	expected := pinentry.GetPINResult{
-		PIN: "abc",
-	}
 	p.expectWriteln("GETPIN")
-	p.expectReadLine("D " + expected.PIN)
-	p.expectReadLine("OK")
-	actual, err := c.GetPIN()
-	assert.NoError(t, err)
-	assert.Equal(t, expected, actual)
+	p.expectReadLineAfter("OK", 100*time.Millisecond)
+	_, err = c.GetPIN()
+	assert.IsError(t, err, os.ErrDeadlineExceeded)
+	var timeoutErr *pinentry.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, pinentry.TimeoutError{Op: "read", Timeout: 10 * time.Millisecond}, *timeoutErr)
 
-	p.expectClose()
+	p.EXPECT().Close().Return(nil)
 	assert.NoError(t, c.Close())
 }
 
-func TestClientConfirm(t *testing.T) {
+func TestClientWriteTimeout(t *testing.T) {
 	p := newMockProcess(t)
 
 	p.expectStart("pinentry", nil)
 	c, err := pinentry.NewClient(
 		pinentry.WithProcess(p),
+		pinentry.WithWriteTimeout(10*time.Millisecond),
 	)
 	assert.NoError(t, err)
 
-	expectedConfirm := true
-	p.expectWriteln("CONFIRM confirm")
-	p.expectReadLine("OK")
-	actualConfirm, err := c.Confirm("confirm")
-	assert.NoError(t, err)
-	assert.Equal(t, expectedConfirm, actualConfirm)
+	var written atomic.Bool
+	p.EXPECT().Write([]byte("GETPIN\n")).DoAndReturn(func(data []byte) (int, error) {
+		time.Sleep(100 * time.Millisecond)
+		written.Store(true)
+		return len(data), nil
+	})
+	_, err = c.GetPIN()
+	var timeoutErr *pinentry.TimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	assert.Equal(t, "write", timeoutErr.Op)
+	assert.True(t, written.Load())
 
-	p.expectClose()
+	p.EXPECT().Close().Return(nil)
 	assert.NoError(t, c.Close())
 }
 
-func TestClientConfirmCancel(t *testing.T) {
+func TestClientCancelUnsupported(t *testing.T) {
 	p := newMockProcess(t)
 
 	p.expectStart("pinentry", nil)
@@ -284,18 +516,13 @@ func TestClientConfirmCancel(t *testing.T) {
 	)
 	assert.NoError(t, err)
 
-	p.expectWriteln("CONFIRM confirm")
-	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
-	actualConfirm, err := c.Confirm("confirm")
-	assert.Error(t, err)
-	assert.True(t, pinentry.IsCancelled(err))
-	assert.Equal(t, false, actualConfirm)
+	assert.IsError(t, c.Cancel(), errors.ErrUnsupported)
 
 	p.expectClose()
 	assert.NoError(t, c.Close())
 }
 
-func TestClientGetPINCancel(t *testing.T) {
+func TestClientBusy(t *testing.T) {
 	p := newMockProcess(t)
 
 	p.expectStart("pinentry", nil)
@@ -304,95 +531,1402 @@ func TestClientGetPINCancel(t *testing.T) {
 	)
 	assert.NoError(t, err)
 
-	p.expectWriteln("GETPIN")
-	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
-	_, err = c.GetPIN()
-	assert.Error(t, err)
-	assert.True(t, pinentry.IsCancelled(err))
+	getPINWritten := make(chan struct{})
+	p.EXPECT().Write([]byte("GETPIN\n")).DoAndReturn(func(data []byte) (int, error) {
+		close(getPINWritten)
+		return len(data), nil
+	})
+	p.expectReadLine("D abc")
+	p.expectReadLineAfter("OK", 100*time.Millisecond)
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.GetPIN()
+		errCh <- err
+	}()
+	<-getPINWritten
+
+	_, err = c.Confirm("")
+	assert.IsError(t, err, pinentry.ErrBusy)
+	assert.IsError(t, c.SetDesc("desc"), pinentry.ErrBusy)
+	assert.NoError(t, <-errCh)
 
 	p.expectClose()
 	assert.NoError(t, c.Close())
 }
 
-func TestClientGetPINFromCache(t *testing.T) {
+func TestClientPassphraseCache(t *testing.T) {
 	p := newMockProcess(t)
+	cache := pinentry.NewPassphraseCache(time.Hour)
 
 	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETKEYINFO n/0123")
 	c, err := pinentry.NewClient(
+		pinentry.WithKeyInfo("n/0123"),
+		pinentry.WithPassphraseCache(cache),
 		pinentry.WithProcess(p),
 	)
 	assert.NoError(t, err)
 
-	expected := pinentry.GetPINResult{
-		PIN:               "abc",
-		PasswordFromCache: true,
-	}
 	p.expectWriteln("GETPIN")
-	p.expectReadLine("S PASSWORD_FROM_CACHE")
-	p.expectReadLine("D " + expected.PIN)
+	p.expectReadLine("D abc")
 	p.expectReadLine("OK")
-	actual, err := c.GetPIN()
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.False(t, result.PasswordFromCache)
+
+	result, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.True(t, result.PasswordFromCache)
+
+	p.expectWritelnOK("CLEARPASSPHRASE n/0123")
+	assert.NoError(t, c.ClearPassphrase("n/0123"))
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D def")
+	p.expectReadLine("OK")
+	result, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "def", result.PIN)
+	assert.False(t, result.PasswordFromCache)
+
+	p.expectWritelnOK("SETKEYINFO n/4567")
+	assert.NoError(t, c.SetKeyInfo("n/4567"))
+	p.expectWritelnOK("RESET")
+	p.expectWritelnOK("SETKEYINFO n/0123")
+	assert.NoError(t, c.ResetState())
+	result, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "def", result.PIN)
+
+	cache.InvalidateAll()
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
 	assert.NoError(t, err)
-	assert.Equal(t, expected, actual)
 
 	p.expectClose()
 	assert.NoError(t, c.Close())
 }
 
-func TestClientGetPINQualityBar(t *testing.T) {
+func TestClientInvalidOption(t *testing.T) {
+	_, err := pinentry.NewClient(
+		pinentry.WithOptions([]string{"no-grab", "parent-wid=abc"}),
+		pinentry.WithProcess(newMockProcess(t)),
+	)
+	assert.Equal(t, error(&pinentry.InvalidOptionError{
+		Option: "parent-wid=abc",
+		Reason: "value is not an integer",
+	}), err)
+}
+
+func TestClientStartErrorFunc(t *testing.T) {
 	p := newMockProcess(t)
 
-	p.expectStart("pinentry", nil)
-	p.expectWritelnOK("SETQUALITYBAR")
-	c, err := pinentry.NewClient(
+	startErr := errors.New("exec: \"pinentry\": executable file not found in $PATH")
+	p.EXPECT().Start("pinentry", nil).Return(startErr)
+	var actualErr error
+	_, err := pinentry.NewClient(
 		pinentry.WithProcess(p),
-		pinentry.WithQualityBar(func(pin string) (int, bool) {
-			return 10 * len(pin), true
+		pinentry.WithStartErrorFunc(func(err error) {
+			actualErr = err
 		}),
 	)
-	assert.NoError(t, err)
+	assert.Equal(t, startErr, err)
+	assert.Equal(t, startErr, actualErr)
+}
 
-	expected := pinentry.GetPINResult{
-		PIN: "abc",
+func TestClientStartErrorFuncAndDesktopNotification(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("notify-send is only used on Linux")
 	}
-	p.expectWriteln("GETPIN")
-	p.expectReadLine("INQUIRE QUALITY a")
-	p.expectWriteln("D 10")
-	p.expectWriteln("END")
-	p.expectReadLine("INQUIRE QUALITY ab")
-	p.expectWriteln("D 20")
-	p.expectWriteln("END")
-	p.expectReadLine("INQUIRE QUALITY abc")
-	p.expectWriteln("D 30")
-	p.expectWriteln("END")
-	p.expectReadLine("D abc")
-	p.expectReadLine("OK")
-	actual, err := c.GetPIN()
-	assert.NoError(t, err)
-	assert.Equal(t, expected, actual)
 
-	p.expectClose()
-	assert.NoError(t, c.Close())
+	tempDir := t.TempDir()
+	notifiedFilename := filepath.Join(tempDir, "notified")
+	notifySend := "#!/bin/sh\necho \"$@\" > " + notifiedFilename + "\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "notify-send"), []byte(notifySend), 0o755)) //nolint:gosec
+	t.Setenv("PATH", tempDir)
+
+	p := newMockProcess(t)
+
+	startErr := errors.New("exec: \"pinentry\": executable file not found in $PATH")
+	p.EXPECT().Start("pinentry", nil).Return(startErr)
+	var actualErr error
+	_, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithDesktopNotification("summary", "body"),
+		pinentry.WithStartErrorFunc(func(err error) {
+			actualErr = err
+		}),
+	)
+	assert.Equal(t, startErr, err)
+	assert.Equal(t, startErr, actualErr)
+	notified, err := os.ReadFile(notifiedFilename)
+	assert.NoError(t, err)
+	assert.Equal(t, "-- summary body\n", string(notified))
 }
 
-func TestClientGetPINQualityBarCancel(t *testing.T) {
+func TestClientSmartcardPrompt(t *testing.T) {
 	p := newMockProcess(t)
 
 	p.expectStart("pinentry", nil)
-	p.expectWritelnOK("SETQUALITYBAR")
+	p.expectWritelnOK("SETDESC Please enter the PIN%0A%0ANumber: D2760001240103040006123456780000%0AHolder: Alice%0A[sigs done: 42]")
+	p.expectWritelnOK("SETPROMPT PIN")
+	p.expectWritelnOK("SETERROR Remaining attempts: 2")
 	c, err := pinentry.NewClient(
 		pinentry.WithProcess(p),
-		pinentry.WithQualityBar(func(pin string) (int, bool) {
-			return 0, false
+		pinentry.WithSmartcardPrompt(pinentry.SmartcardPrompt{
+			PIN:               pinentry.SmartcardSigningPIN,
+			SerialNumber:      "D2760001240103040006123456780000",
+			Holder:            "Alice",
+			SignatureCounter:  42,
+			RemainingAttempts: 2,
 		}),
 	)
 	assert.NoError(t, err)
 
-	expected := pinentry.GetPINResult{
-		PIN: "abc",
-	}
-	p.expectWriteln("GETPIN")
-	p.expectReadLine("INQUIRE QUALITY a")
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientTTYSettings(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", []string{
+		"--ttyname", "/dev/pts/0",
+		"--ttytype", "xterm",
+		"--display", ":0",
+	})
+	p.expectWritelnOK("OPTION ttyname=/dev/pts/0")
+	p.expectWritelnOK("OPTION ttytype=xterm")
+	p.expectWritelnOK("OPTION display=:0")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithTTYSettings(pinentry.TTYSettings{
+			TTYName: "/dev/pts/0",
+			TTYType: "xterm",
+			Display: ":0",
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientClearPassphrase(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("CLEARPASSPHRASE cacheID")
+	p.expectReadLine("OK")
+	assert.NoError(t, c.ClearPassphrase("cacheID"))
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPIN(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	expected := pinentry.GetPINResult{
+		PIN: "abc",
+	}
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D " + expected.PIN)
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientConfirm(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	expectedConfirm := true
+	p.expectWriteln("CONFIRM confirm")
+	p.expectReadLine("OK")
+	actualConfirm, err := c.Confirm("confirm")
+	assert.NoError(t, err)
+	assert.Equal(t, expectedConfirm, actualConfirm)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientCommandTimeouts(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETTIMEOUT 60")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithTimeout(time.Minute),
+		pinentry.WithConfirmTimeout(10*time.Second),
+		pinentry.WithGetPINTimeout(2*time.Minute),
+		pinentry.WithMessageTimeout(5*time.Second),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 120")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 10")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	_, err = c.Confirm("")
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 5")
+	p.expectWriteln("MESSAGE")
+	p.expectReadLine("OK")
+	assert.NoError(t, c.Message())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientCommandTimeoutRestored(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETTIMEOUT 60")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithTimeout(time.Minute),
+		pinentry.WithConfirmTimeout(10*time.Second),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 10")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	_, err = c.Confirm("")
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 60")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p.expectStart("pinentry", nil)
+	c, err = pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithConfirmTimeout(10*time.Second),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 10")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	_, err = c.Confirm("")
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETTIMEOUT 0")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientConfirmOneButton(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("CONFIRM --one-button")
+	p.expectReadLine("OK")
+	assert.NoError(t, c.ConfirmOneButton())
+
+	p.expectWriteln("CONFIRM --one-button")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	assert.True(t, pinentry.IsCancelled(c.ConfirmOneButton()))
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientSet(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		setFunc  func(string) error
+		arg      string
+		expected string
+	}{
+		{setFunc: c.SetCancel, arg: "cancel", expected: "SETCANCEL cancel"},
+		{setFunc: c.SetDesc, arg: "desc\nline", expected: "SETDESC desc%0Aline"},
+		{setFunc: c.SetError, arg: "100%", expected: "SETERROR 100%25"},
+		{setFunc: c.SetGenPIN, arg: "genpin", expected: "SETGENPIN genpin"},
+		{setFunc: c.SetGenPINToolTip, arg: "genpintt", expected: "SETGENPIN_TT genpintt"},
+		{setFunc: c.SetKeyInfo, arg: "n/0123", expected: "SETKEYINFO n/0123"},
+		{setFunc: c.SetNotOK, arg: "notok", expected: "SETNOTOK notok"},
+		{setFunc: c.SetOK, arg: "ok", expected: "SETOK ok"},
+		{setFunc: c.SetPrompt, arg: "prompt", expected: "SETPROMPT prompt"},
+		{setFunc: c.SetQualityBarToolTip, arg: "qualitybartt", expected: "SETQUALITYBAR_TT qualitybartt"},
+		{setFunc: c.SetRepeat, arg: "repeat", expected: "SETREPEAT repeat"},
+		{setFunc: c.SetRepeatError, arg: "repeaterror", expected: "SETREPEATERROR repeaterror"},
+		{setFunc: c.SetRepeatOK, arg: "repeatok", expected: "SETREPEATOK repeatok"},
+		{setFunc: c.SetTitle, arg: "title", expected: "SETTITLE title"},
+	} {
+		p.expectWritelnOK(tc.expected)
+		assert.NoError(t, tc.setFunc(tc.arg))
+	}
+
+	p.expectWriteln("SETERROR error")
+	p.expectReadLine("ERR 83886360 IPC parameter error <Pinentry>")
+	assert.Error(t, c.SetError("error"))
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientResetState(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETTIMEOUT 60")
+	p.expectWritelnOK("SETDESC desc")
+	c, err := pinentry.NewClient(
+		pinentry.WithDesc("desc"),
+		pinentry.WithProcess(p),
+		pinentry.WithTimeout(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETERROR error")
+	assert.NoError(t, c.SetError("error"))
+
+	p.expectWritelnOK("RESET")
+	p.expectWritelnOK("SETTIMEOUT 60")
+	p.expectWritelnOK("SETDESC desc")
+	assert.NoError(t, c.ResetState())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientWithBinaryNames(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.EXPECT().Start("pinentry-gnome3", nil).Return(exec.ErrNotFound)
+	p.EXPECT().Start("pinentry-qt", nil).Return(exec.ErrNotFound)
+	p.expectStart("pinentry-curses", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithBinaryNames([]string{"pinentry-gnome3", "pinentry-qt", "pinentry-curses", "pinentry"}),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p = newMockProcess(t)
+	p.EXPECT().Start("pinentry-gnome3", nil).Return(exec.ErrNotFound)
+	p.EXPECT().Start("pinentry-qt", nil).Return(exec.ErrNotFound)
+	_, err = pinentry.NewClient(
+		pinentry.WithBinaryNames([]string{"pinentry-gnome3", "pinentry-qt"}),
+		pinentry.WithProcess(p),
+	)
+	assert.IsError(t, err, exec.ErrNotFound)
+	var notFoundErr *pinentry.PinentryNotFoundError
+	assert.True(t, errors.As(err, &notFoundErr))
+	assert.Equal(t, []string{"pinentry-gnome3", "pinentry-qt"}, notFoundErr.BinaryNames)
+
+	p = newMockProcess(t)
+	p.EXPECT().Start("pinentry-gnome3", nil).Return(exec.ErrNotFound)
+	p.EXPECT().Start("pinentry-qt", nil).Return(os.ErrPermission)
+	_, err = pinentry.NewClient(
+		pinentry.WithBinaryNames([]string{"pinentry-gnome3", "pinentry-qt"}),
+		pinentry.WithProcess(p),
+	)
+	assert.IsError(t, err, os.ErrPermission)
+	assert.False(t, errors.Is(err, pinentry.ErrPinentryNotFound))
+
+	p = newMockProcess(t)
+	p.expectStart("pinentry-tty", nil)
+	c, err = pinentry.NewClient(
+		pinentry.WithBinaryNames([]string{"pinentry-gnome3", "pinentry-qt"}),
+		pinentry.WithBinaryName("pinentry-tty"),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientConfirmWithResult(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("CONFIRM")
+	p.expectReadLineAfter("OK", 10*time.Millisecond)
+	actual, err := c.ConfirmWithResult("")
+	assert.NoError(t, err)
+	assert.True(t, actual.Confirmed)
+	assert.True(t, actual.Duration >= 10*time.Millisecond)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientConfirmCancel(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("CONFIRM confirm")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	actualConfirm, err := c.Confirm("confirm")
+	assert.Error(t, err)
+	assert.True(t, pinentry.IsCancelled(err))
+	assert.Equal(t, false, actualConfirm)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientConfirmWithOptions(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETOK Yes")
+	c, err := pinentry.NewClient(
+		pinentry.WithOK("Yes"),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("SETDESC Delete key?")
+	p.expectWritelnOK("SETOK Delete")
+	p.expectWriteln("CONFIRM --one-button")
+	p.expectReadLine("ERR 83886194 Not confirmed <Pinentry>")
+	p.expectWritelnOK("SETOK Yes")
+	p.expectWritelnOK("SETDESC")
+	actual, err := c.ConfirmWithOptions(context.Background(), pinentry.ConfirmOptions{
+		Desc:      "Delete key?",
+		OK:        "Delete",
+		OneButton: true,
+	})
+	assert.NoError(t, err)
+	assert.False(t, actual.Confirmed)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.ConfirmWithOptions(ctx, pinentry.ConfirmOptions{
+		Desc: "Delete key?",
+	})
+	assert.IsError(t, err, context.Canceled)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientWithGPGTTYStrict(t *testing.T) {
+	t.Setenv("GPG_TTY", "")
+	_, err := pinentry.NewClient(
+		pinentry.WithGPGTTYStrict(),
+		pinentry.WithProcess(newMockProcess(t)),
+	)
+	assert.IsError(t, err, pinentry.ErrNoGPGTTY)
+
+	t.Setenv("GPG_TTY", filepath.Join(t.TempDir(), "missing"))
+	_, err = pinentry.NewClient(
+		pinentry.WithGPGTTYStrict(),
+		pinentry.WithProcess(newMockProcess(t)),
+	)
+	assert.IsError(t, err, pinentry.ErrNoGPGTTY)
+
+	gpgTTY := filepath.Join(t.TempDir(), "tty")
+	assert.NoError(t, os.WriteFile(gpgTTY, nil, 0o600))
+	t.Setenv("GPG_TTY", gpgTTY)
+	p := newMockProcess(t)
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("OPTION ttyname=" + gpgTTY)
+	c, err := pinentry.NewClient(
+		pinentry.WithGPGTTYStrict(),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientWithClientOptionE(t *testing.T) {
+	errOption := errors.New("option")
+	_, err := pinentry.NewClient(
+		pinentry.WithClientOptionE(func(*pinentry.Client) error {
+			return errOption
+		}),
+		pinentry.WithProcess(newMockProcess(t)),
+	)
+	assert.IsError(t, err, errOption)
+
+	p := newMockProcess(t)
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETTITLE title")
+	c, err := pinentry.NewClient(
+		pinentry.WithClientOptionE(func(c *pinentry.Client) error {
+			pinentry.WithTitle("title")(c)
+			return nil
+		}),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINSecret(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc%25")
+	p.expectReadLine("OK")
+	secret, result, err := c.GetPINSecret()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc%", string(secret.Bytes()))
+	assert.Equal(t, "", result.PIN)
+	assert.Equal(t, "[redacted]", secret.String())
+	assert.NoError(t, secret.Close())
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	secret, _, err = c.GetPINSecret()
+	assert.True(t, pinentry.IsCancelled(err))
+	assert.Zero(t, secret)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINContext(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPINContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", actual.PIN)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	p.expectWriteln("GETPIN")
+	p.expectReadLineAfter("OK", 100*time.Millisecond)
+	_, err = c.GetPINContext(ctx)
+	assert.IsError(t, err, context.DeadlineExceeded)
+
+	p.EXPECT().Close().Return(nil)
+	assert.NoError(t, c.Close())
+}
+
+func TestClientNewClientContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := pinentry.NewClientContext(ctx,
+		pinentry.WithProcess(newMockProcess(t)),
+	)
+	assert.IsError(t, err, context.Canceled)
+}
+
+func TestClientGetCode(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETTIMEOUT 60")
+	p.expectWritelnOK("SETDESC desc")
+	c, err := pinentry.NewClient(
+		pinentry.WithDesc("desc"),
+		pinentry.WithProcess(p),
+		pinentry.WithTimeout(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	p.expectWritelnOK("OPTION default-tt-visi=Show the code")
+	p.expectWritelnOK("OPTION default-tt-hide=Hide the code")
+	p.expectWritelnOK("SETDESC Enter the code%0A%0AThe code expires in 90 seconds.")
+	p.expectWritelnOK("SETTIMEOUT 90")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D 12a456")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETERROR Enter 6 digits")
+	p.EXPECT().Write(gomock.Any()).DoAndReturn(func(data []byte) (int, error) {
+		assert.Contains(t, string(data), "SETDESC Enter the code%0A%0AThe code expires in ")
+		return len(data), nil
+	})
+	p.expectReadLine("OK")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D 123 456")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETDESC desc")
+	p.expectWritelnOK("SETTIMEOUT 60")
+	p.expectWritelnOK("OPTION default-tt-hide=")
+	p.expectWritelnOK("OPTION default-tt-visi=")
+	code, err := c.GetCode(pinentry.CodePrompt{
+		Desc:    "Enter the code",
+		Digits:  6,
+		Expiry:  time.Now().Add(90*time.Second + 900*time.Millisecond),
+		Visible: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "123456", code)
+
+	_, err = c.GetCode(pinentry.CodePrompt{
+		Expiry: time.Now(),
+	})
+	assert.IsError(t, err, pinentry.ErrCodeExpired)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientWithPINRetryLimitInvalid(t *testing.T) {
+	for _, limit := range []int{0, -1} {
+		_, err := pinentry.NewClient(
+			pinentry.WithPINRetryLimit(limit),
+			pinentry.WithProcess(newMockProcess(t)),
+		)
+		assert.IsError(t, err, pinentry.ErrInvalidPINRetryLimit)
+	}
+}
+
+func TestClientWithPINRetry(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETKEYINFO n/0123456789ABCDEF")
+	c, err := pinentry.NewClient(
+		pinentry.WithKeyInfo("n/0123456789ABCDEF"),
+		pinentry.WithPINRetryLimit(2),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D wrong")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("CLEARPASSPHRASE n/0123456789ABCDEF")
+	p.expectWritelnOK("SETERROR Wrong passphrase")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D right")
+	p.expectReadLine("OK")
+	var pins []string
+	assert.NoError(t, c.WithPINRetry(context.Background(), func(pin string) error {
+		pins = append(pins, pin)
+		if pin != "right" {
+			return pinentry.ErrWrongPIN
+		}
+		return nil
+	}))
+	assert.Equal(t, []string{"wrong", "right"}, pins)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D wrong")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("CLEARPASSPHRASE n/0123456789ABCDEF")
+	p.expectWritelnOK("SETERROR Wrong passphrase")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D wrong")
+	p.expectReadLine("OK")
+	assert.IsError(t, c.WithPINRetry(context.Background(), func(string) error {
+		return pinentry.ErrWrongPIN
+	}), pinentry.ErrWrongPIN)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientRunWizard(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	steps := []pinentry.WizardStep{
+		pinentry.WizardGetPIN("passphrase", "Enter a passphrase", "Passphrase:", "Repeat:"),
+		pinentry.WizardConfirm("backup", "Have you backed up your key?", true),
+		pinentry.WizardMessage("Done"),
+	}
+
+	p.expectWritelnOK("SETTITLE Key generation")
+	p.expectWritelnOK("SETDESC Enter a passphrase")
+	p.expectWritelnOK("SETPROMPT Passphrase:")
+	p.expectWritelnOK("SETREPEAT Repeat:")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("S PIN_REPEATED")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETDESC Have you backed up your key?")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETDESC Done")
+	p.expectWritelnOK("MESSAGE")
+	actual, err := c.RunWizard("Key generation", steps...)
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.WizardResult{
+		PINs:          map[string]string{"passphrase": "abc"},
+		Confirmations: map[string]bool{"backup": true},
+		Completed:     true,
+	}, actual)
+
+	p.expectWritelnOK("SETTITLE Key generation")
+	p.expectWritelnOK("SETDESC Enter a passphrase")
+	p.expectWritelnOK("SETPROMPT Passphrase:")
+	p.expectWritelnOK("SETREPEAT Repeat:")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETDESC Have you backed up your key?")
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("ERR 83886194 Not confirmed <Pinentry>")
+	actual, err = c.RunWizard("Key generation", steps...)
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.WizardResult{
+		PINs:          map[string]string{"passphrase": "abc"},
+		Confirmations: map[string]bool{"backup": false},
+	}, actual)
+
+	p.expectWritelnOK("SETTITLE Key generation")
+	p.expectWritelnOK("SETDESC Enter a passphrase")
+	p.expectWritelnOK("SETPROMPT Passphrase:")
+	p.expectWritelnOK("SETREPEAT Repeat:")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	actual, err = c.RunWizard("Key generation", steps...)
+	assert.True(t, pinentry.IsCancelled(err))
+	assert.False(t, actual.Completed)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINCancel(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	_, err = c.GetPIN()
+	assert.Error(t, err)
+	assert.True(t, pinentry.IsCancelled(err))
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINDuration(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLineAfter("OK", 10*time.Millisecond)
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.True(t, actual.Duration >= 10*time.Millisecond)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLineAfter("ERR 83886179 Operation cancelled <Pinentry>", 10*time.Millisecond)
+	actual, err = c.GetPIN()
+	assert.True(t, pinentry.IsCancelled(err))
+	assert.True(t, actual.Duration >= 10*time.Millisecond)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINHeartbeat(t *testing.T) {
+	p := newMockProcess(t)
+
+	var heartbeats atomic.Int32
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithHeartbeat(time.Millisecond, func(elapsed time.Duration) {
+			heartbeats.Add(1)
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLineAfter("OK", 20*time.Millisecond)
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.True(t, heartbeats.Load() > 0)
+
+	heartbeatsAfterGetPIN := heartbeats.Load()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, heartbeatsAfterGetPIN, heartbeats.Load())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINInputValidation(t *testing.T) {
+	for i, tc := range []struct {
+		line         string
+		expectedChar byte
+	}{
+		{
+			line:         "D a%00b",
+			expectedChar: 0,
+		},
+		{
+			line:         "D a%1B[2Jb",
+			expectedChar: 0x1b,
+		},
+		{
+			line:         "S PIN\tREPEATED",
+			expectedChar: '\t',
+		},
+		{
+			line:         "INQUIRE QUALITY a\x7f",
+			expectedChar: 0x7f,
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			p := newMockProcess(t)
+
+			p.expectStart("pinentry", nil)
+			c, err := pinentry.NewClient(
+				pinentry.WithProcess(p),
+				pinentry.WithInputValidation(),
+			)
+			assert.NoError(t, err)
+
+			p.expectWriteln("GETPIN")
+			p.expectReadLine(tc.line)
+			_, err = c.GetPIN()
+			assert.Equal(t, &pinentry.InvalidDataError{
+				Char: tc.expectedChar,
+			}, err.(*pinentry.InvalidDataError)) //nolint:forcetypeassert,errorlint
+
+			p.expectClose()
+			assert.NoError(t, c.Close())
+		})
+	}
+}
+
+func TestClientGetPINKeyboardGrabError(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("ERR 83886166 Pinentry error <grab>")
+	_, err = c.GetPIN()
+	var keyboardGrabError *pinentry.KeyboardGrabError
+	assert.True(t, errors.As(err, &keyboardGrabError))
+	assert.False(t, pinentry.IsCancelled(err))
+
+	for _, line := range []string{
+		"ERR 83886166 Pinentry error <Pinentry>",
+		"ERR 83886360 Cannot grab the display <Pinentry>",
+	} {
+		p.expectWriteln("GETPIN")
+		p.expectReadLine(line)
+		_, err = c.GetPIN()
+		assert.False(t, errors.As(err, &keyboardGrabError))
+		var assuanError *pinentry.AssuanError
+		assert.True(t, errors.As(err, &assuanError))
+	}
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINNoGlobalGrabRetry(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETDESC desc")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithDesc("desc"),
+		pinentry.WithNoGlobalGrabRetry(),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("ERR 83886166 Pinentry error <grab>")
+	p.expectClose()
+	p.expectStart("pinentry", []string{"--no-global-grab"})
+	p.expectWritelnOK("SETDESC desc")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", actual.PIN)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINFromCache(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	expected := pinentry.GetPINResult{
+		PIN:               "abc",
+		PasswordFromCache: true,
+		Status: map[string][]string{
+			"PASSWORD_FROM_CACHE": nil,
+		},
+	}
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("S PASSWORD_FROM_CACHE")
+	p.expectReadLine("D " + expected.PIN)
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINStatus(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	expected := pinentry.GetPINResult{
+		PIN: "abc",
+		Status: map[string][]string{
+			"CUSTOM":              {"arg1", "arg 2"},
+			"PASSWORD_FROM_CACHE": nil,
+		},
+		PasswordFromCache: true,
+	}
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("S CUSTOM ignored")
+	p.expectReadLine("S PASSWORD_FROM_CACHE")
+	p.expectReadLine("S CUSTOM arg1 arg%202")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINQualityBar(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETQUALITYBAR")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithQualityBar(func(pin string) (int, bool) {
+			return 10 * len(pin), true
+		}),
+	)
+	assert.NoError(t, err)
+
+	expected := pinentry.GetPINResult{
+		PIN: "abc",
+	}
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE QUALITY a")
+	p.expectWriteln("D 10")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE QUALITY ab")
+	p.expectWriteln("D 20")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE QUALITY abc")
+	p.expectWriteln("D 30")
+	p.expectWriteln("END")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINInquiryHandler(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithInquiryHandler("CHUNKS", func(inquiry *pinentry.Inquiry) error {
+			assert.Equal(t, "a b", inquiry.Args)
+			for _, chunk := range []string{"abc", "d%f"} {
+				if _, err := inquiry.Write([]byte(chunk)); err != nil {
+					return err
+				}
+			}
+			return inquiry.End()
+		}),
+		pinentry.WithInquiryHandler("UNHANDLED", func(*pinentry.Inquiry) error {
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE CHUNKS a b")
+	p.expectWriteln("D abc")
+	p.expectWriteln("D d%25f")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE UNHANDLED")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINGenPINFunc(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWriteln("SETGENPIN Generate")
+	p.expectReadLine("OK")
+	genPINs := []string{"gen%pin", ""}
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithGenPIN("Generate"),
+		pinentry.WithGenPINFunc(func() (string, bool) {
+			genPIN := genPINs[0]
+			genPINs = genPINs[1:]
+			return genPIN, genPIN != ""
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE GENPIN")
+	p.expectWriteln("D gen%25pin")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE GENPIN")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D gen%25pin")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "gen%pin"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINGenPINFuncTooLong(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithGenPINFunc(func() (string, bool) {
+			return "genpin", true
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE MAXLEN 4")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE GENPIN")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINConstraints(t *testing.T) {
+	constraints := pinentry.Constraints{
+		MinLen:         4,
+		RequireClasses: pinentry.CharClassDigit,
+		HintShort:      "4 characters",
+	}
+
+	p := newMockProcess(t)
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("OPTION constraints-enforce")
+	p.expectWritelnOK("OPTION constraints-hint-short=4 characters")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithPassphraseConstraints(constraints),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE CHECKPIN abc")
+	p.expectWriteln("D Passphrase must be at least 4 characters long and contain a digit.")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE CHECKPIN abc1")
+	p.expectWriteln("END")
+	p.expectReadLine("D abc1")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc1"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+
+	p.expectStart("pinentry", nil)
+	p.expectWriteln("OPTION constraints-enforce")
+	p.expectReadLine("ERR 83886254 Unknown option <Pinentry>")
+	c, err = pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithPassphraseConstraints(constraints),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	p.expectWritelnOK("SETERROR Passphrase must be at least 4 characters long and contain a digit.")
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc1")
+	p.expectReadLine("OK")
+	actual, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc1"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINPinentryNotify(t *testing.T) {
+	p := newMockProcess(t)
+
+	var pinentryLauncheds []pinentry.PinentryLaunched
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("OPTION allow-pinentry-notify")
+	c, err := pinentry.NewClient(
+		pinentry.WithPinentryNotify(func(pinentryLaunched pinentry.PinentryLaunched) {
+			pinentryLauncheds = append(pinentryLauncheds, pinentryLaunched)
+		}),
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE PINENTRY_LAUNCHED 1234 gnome3 1.2.1 /dev/pts/0 xterm-256color :0")
+	p.expectWriteln("END")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc"}, actual, assert.Exclude[time.Duration]())
+	assert.Equal(t, []pinentry.PinentryLaunched{
+		{
+			PID:     1234,
+			Flavor:  "gnome3",
+			Version: "1.2.1",
+			Args:    []string{"/dev/pts/0", "xterm-256color", ":0"},
+		},
+	}, pinentryLauncheds)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINMaxLen(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETQUALITYBAR")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithQualityBar(func(pin string) (int, bool) {
+			return 10 * len(pin), true
+		}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.MaxLen())
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE MAXLEN invalid")
+	p.expectWriteln("CAN")
+	p.expectReadLine("INQUIRE MAXLEN 1")
+	p.expectWriteln("END")
+	p.expectReadLine("INQUIRE QUALITY a")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D a")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "a", actual.PIN)
+	assert.Equal(t, 1, c.MaxLen())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINQualityEvents(t *testing.T) {
+	p := newMockProcess(t)
+
+	var qualityEvents []pinentry.QualityEvent
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETQUALITYBAR")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithQualityBar(func(pin string) (int, bool) {
+			return 60 * len(pin), len(pin) > 1
+		}),
+		pinentry.WithQualityEventFunc(func(qualityEvent pinentry.QualityEvent) {
+			qualityEvents = append(qualityEvents, qualityEvent)
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE QUALITY a")
+	p.expectWriteln("CAN")
+	p.expectReadLine("INQUIRE QUALITY éb")
+	p.expectWriteln("D 100")
+	p.expectWriteln("END")
+	p.expectReadLine("D éb")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, []pinentry.QualityEvent{
+		{Length: 1, Quality: 60},
+		{Length: 2, Quality: 100, OK: true},
+	}, qualityEvents)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINQualityBarCancel(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	p.expectWritelnOK("SETQUALITYBAR")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithQualityBar(func(pin string) (int, bool) {
+			return 0, false
+		}),
+	)
+	assert.NoError(t, err)
+
+	expected := pinentry.GetPINResult{
+		PIN: "abc",
+	}
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE QUALITY a")
 	p.expectWriteln("CAN")
 	p.expectReadLine("INQUIRE QUALITY ab")
 	p.expectWriteln("CAN")
@@ -402,7 +1936,7 @@ func TestClientGetPINQualityBarCancel(t *testing.T) {
 	p.expectReadLine("OK")
 	actual, err := c.GetPIN()
 	assert.NoError(t, err)
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
 
 	p.expectClose()
 	assert.NoError(t, c.Close())
@@ -422,6 +1956,9 @@ func TestClientGetPINRepeat(t *testing.T) {
 	expected := pinentry.GetPINResult{
 		PIN:         "abc",
 		PINRepeated: true,
+		Status: map[string][]string{
+			"PIN_REPEATED": nil,
+		},
 	}
 	p.expectWriteln("GETPIN")
 	p.expectReadLine("S PIN_REPEATED")
@@ -429,7 +1966,7 @@ func TestClientGetPINRepeat(t *testing.T) {
 	p.expectReadLine("OK")
 	actual, err := c.GetPIN()
 	assert.NoError(t, err)
-	assert.Equal(t, expected, actual)
+	assert.Equal(t, expected, actual, assert.Exclude[time.Duration]())
 
 	p.expectClose()
 	assert.NoError(t, c.Close())
@@ -446,12 +1983,117 @@ func TestClientGetPINineUnexpectedResponse(t *testing.T) {
 
 	p.expectWriteln("GETPIN")
 	p.expectReadLine("unexpected response")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	p.expectWriteln("NOP")
+	p.expectReadLine("OK")
 	_, err = c.GetPIN()
 	assert.Error(t, err)
 	assert.Equal(t, pinentry.UnexpectedResponseError{
 		Line: "unexpected response",
 	}, err.(pinentry.UnexpectedResponseError)) //nolint:forcetypeassert,errorlint
 
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D def")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "def", actual.PIN)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINUnexpectedInquiryResync(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE UNKNOWN")
+	p.expectWriteln("CAN")
+	p.expectReadLine("ERR 83886179 Operation cancelled <Pinentry>")
+	p.expectWriteln("NOP")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.Equal(t, pinentry.UnexpectedResponseError{
+		Line: "INQUIRE UNKNOWN",
+	}, err.(pinentry.UnexpectedResponseError)) //nolint:forcetypeassert,errorlint
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc"}, actual, assert.Exclude[time.Duration]())
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINInquiryHandlerError(t *testing.T) {
+	p := newMockProcess(t)
+
+	errHandler := errors.New("handler")
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithInquiryHandler("FAIL", func(*pinentry.Inquiry) error {
+			return errHandler
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("INQUIRE FAIL")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D typed")
+	p.expectReadLine("OK")
+	_, err = c.GetPIN()
+	assert.IsError(t, err, errHandler)
+
+	p.expectWriteln("CONFIRM")
+	p.expectReadLine("OK")
+	confirmed, err := c.Confirm("")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetPINUnexpectedResponseFunc(t *testing.T) {
+	p := newMockProcess(t)
+
+	var unexpectedResponses []string
+	p.expectStart("pinentry", nil)
+	p.expectWriteln("SETPROMPT prompt")
+	p.expectReadLine("chatty")
+	p.expectReadLine("OK")
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithPrompt("prompt"),
+		pinentry.WithUnexpectedResponseFunc(func(line string) {
+			unexpectedResponses = append(unexpectedResponses, line)
+		}),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETPIN")
+	p.expectReadLine("unexpected response")
+	p.expectReadLine("INQUIRE UNKNOWN")
+	p.expectWriteln("CAN")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	actual, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.GetPINResult{PIN: "abc"}, actual, assert.Exclude[time.Duration]())
+	assert.Equal(t, []string{"chatty", "unexpected response", "INQUIRE UNKNOWN"}, unexpectedResponses)
+
 	p.expectClose()
 	assert.NoError(t, c.Close())
 }
@@ -473,6 +2115,111 @@ func TestClientMessage(t *testing.T) {
 	assert.NoError(t, c.Close())
 }
 
+func TestClientPromptLock(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+		pinentry.WithPromptLockFile(filepath.Join(t.TempDir(), "lock")),
+	)
+	assert.NoError(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientGetInfo(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETINFO flavor")
+	p.expectReadLine("D gtk2:curses")
+	p.expectReadLine("OK")
+	flavor, err := c.Flavor()
+	assert.NoError(t, err)
+	assert.Equal(t, "gtk2:curses", flavor)
+
+	p.expectWriteln("GETINFO pid")
+	p.expectReadLine("D 1234")
+	p.expectReadLine("OK")
+	pid, err := c.PID()
+	assert.NoError(t, err)
+	assert.Equal(t, 1234, pid)
+
+	p.expectWriteln("GETINFO ttyinfo")
+	p.expectReadLine("D /dev/pts/1 xterm-256color - - 1000/1000 0")
+	p.expectReadLine("OK")
+	ttyInfo, err := c.TTYInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.TTYInfo{
+		TTYName: "/dev/pts/1",
+		TTYType: "xterm-256color",
+	}, ttyInfo)
+
+	p.expectWriteln("GETINFO version")
+	p.expectReadLine("D 1.2.1")
+	p.expectReadLine("OK")
+	version, err := c.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.1", version)
+
+	p.expectWriteln("GETINFO pid")
+	p.expectReadLine("D abc")
+	p.expectReadLine("OK")
+	_, err = c.PID()
+	assert.Error(t, err)
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
+func TestClientReadResponse(t *testing.T) {
+	p := newMockProcess(t)
+
+	p.expectStart("pinentry", nil)
+	c, err := pinentry.NewClient(
+		pinentry.WithProcess(p),
+	)
+	assert.NoError(t, err)
+
+	p.expectWriteln("GETINFO version")
+	assert.NoError(t, c.WriteCommand("GETINFO version"))
+
+	p.expectReadLine("S STATUS one")
+	p.expectReadLine("D 1.2")
+	p.expectReadLine("D .1%0A")
+	p.expectReadLine("OK done")
+	response, err := c.ReadResponse()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.Response{
+		Data:   []byte("1.2.1\n"),
+		Status: []string{"STATUS one"},
+		OK:     "done",
+	}, response)
+	assert.Equal(t, map[string][]string{
+		"STATUS": {"one"},
+	}, response.StatusMap())
+
+	p.expectWriteln("UNKNOWN")
+	assert.NoError(t, c.WriteCommand("UNKNOWN"))
+
+	p.expectReadLine("ERR 536871187 Unknown IPC command <User defined source 1>")
+	_, err = c.ReadResponse()
+	assert.Equal(t, &pinentry.AssuanError{
+		Code:        536871187,
+		Description: "Unknown IPC command <User defined source 1>",
+	}, err.(*pinentry.AssuanError)) //nolint:forcetypeassert,errorlint
+
+	p.expectClose()
+	assert.NoError(t, c.Close())
+}
+
 func TestClientReadLineIgnoreBlank(t *testing.T) {
 	p := newMockProcess(t)
 
@@ -520,6 +2267,13 @@ func (p *MockProcess) expectReadLine(line string) {
 	p.EXPECT().ReadLine().Return([]byte(line), false, nil)
 }
 
+func (p *MockProcess) expectReadLineAfter(line string, delay time.Duration) {
+	p.EXPECT().ReadLine().DoAndReturn(func() ([]byte, bool, error) {
+		time.Sleep(delay)
+		return []byte(line), false, nil
+	})
+}
+
 func (p *MockProcess) expectStart(name string, args []string) {
 	p.EXPECT().Start(name, args).Return(nil)
 	p.expectReadLine("OK Pleased to meet you")
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/twpayne/go-pinentry/v4/internal/environ"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+// flavorEnvVar is the environment variable that sets the default flavor.
+const flavorEnvVar = "PINENTRY_GO_FLAVOR"
+
+var (
+	errNoFlavor          = errors.New("no flavor available")
+	errFlavorUnavailable = errors.New("flavor not available in this build")
+	errUnknownFlavor     = errors.New("unknown flavor")
+)
+
+// frontEnds are the available front ends, by flavor.
+var frontEnds = map[string]func(ttyName string) server.UI{
+	"tty": func(ttyName string) server.UI {
+		return &tty{
+			name: ttyName,
+		}
+	},
+}
+
+// flavors are all flavors, available or not.
+var flavors = []string{"tty", "tui", "gui"}
+
+// autoFlavors returns the flavors to try, in order, when the flavor is auto,
+// using the same environment detection as pinentry.WithAutoBinaryName.
+func autoFlavors(getenv func(string) string, goos string, hasTTY bool) []string {
+	if goos == "darwin" || goos == "windows" || !environ.PreferTerminal(getenv, hasTTY) {
+		return []string{"gui", "tui", "tty"}
+	}
+	return []string{"tui", "tty"}
+}
+
+// selectFlavor returns the flavor and its front end for flavor, which may be
+// auto.
+func selectFlavor(flavor string, getenv func(string) string, goos string, hasTTY bool) (string, func(string) server.UI, error) {
+	if flavor == "auto" {
+		for _, flavor := range autoFlavors(getenv, goos, hasTTY) {
+			if frontEnd, ok := frontEnds[flavor]; ok {
+				return flavor, frontEnd, nil
+			}
+		}
+		return "", nil, errNoFlavor
+	}
+	if frontEnd, ok := frontEnds[flavor]; ok {
+		return flavor, frontEnd, nil
+	}
+	for _, f := range flavors {
+		if f == flavor {
+			return "", nil, fmt.Errorf("%s: %w", flavor, errFlavorUnavailable)
+		}
+	}
+	return "", nil, fmt.Errorf("%s: %w", flavor, errUnknownFlavor)
+}
+
+// defaultFlavor returns the default flavor.
+func defaultFlavor() string {
+	if flavor := os.Getenv(flavorEnvVar); flavor != "" {
+		return flavor
+	}
+	return "auto"
+}
+
+// hasTTY returns whether ttyName or the controlling terminal can be opened.
+func hasTTY(ttyName string) bool {
+	if ttyName == "" {
+		ttyName = "/dev/tty"
+	}
+	f, err := os.OpenFile(ttyName, os.O_RDWR, 0)
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
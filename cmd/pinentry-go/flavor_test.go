@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSelectFlavor(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		flavor         string
+		env            map[string]string
+		goos           string
+		hasTTY         bool
+		expectedFlavor string
+		expectedErr    error
+	}{
+		{
+			name:           "tty",
+			flavor:         "tty",
+			goos:           "linux",
+			expectedFlavor: "tty",
+		},
+		{
+			name:        "gui",
+			flavor:      "gui",
+			goos:        "linux",
+			expectedErr: errFlavorUnavailable,
+		},
+		{
+			name:        "unknown",
+			flavor:      "curses",
+			goos:        "linux",
+			expectedErr: errUnknownFlavor,
+		},
+		{
+			name:           "auto_terminal",
+			flavor:         "auto",
+			goos:           "linux",
+			hasTTY:         true,
+			expectedFlavor: "tty",
+		},
+		{
+			name:   "auto_graphical",
+			flavor: "auto",
+			env: map[string]string{
+				"DISPLAY": ":0",
+			},
+			goos:           "linux",
+			hasTTY:         true,
+			expectedFlavor: "tty",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tc.env[key]
+			}
+			flavor, frontEnd, err := selectFlavor(tc.flavor, getenv, tc.goos, tc.hasTTY)
+			if tc.expectedErr != nil {
+				assert.IsError(t, err, tc.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedFlavor, flavor)
+			assert.NotZero(t, frontEnd(""))
+		})
+	}
+}
+
+func TestAutoFlavors(t *testing.T) {
+	getenv := func(key string) string {
+		return map[string]string{"DISPLAY": ":0"}[key]
+	}
+	assert.Equal(t, []string{"tui", "tty"}, autoFlavors(func(string) string { return "" }, "linux", true))
+	assert.Equal(t, []string{"gui", "tui", "tty"}, autoFlavors(getenv, "linux", true))
+	assert.Equal(t, []string{"gui", "tui", "tty"}, autoFlavors(getenv, "darwin", true))
+}
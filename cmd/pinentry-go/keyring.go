@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+// secretServiceApplication is the application attribute of passphrases saved
+// with the Secret Service.
+const secretServiceApplication = "pinentry-go"
+
+// keyringConfirmDesc is the description shown when confirming the use of a
+// passphrase saved in the keyring.
+const keyringConfirmDesc = "Use the passphrase saved in the keyring?"
+
+// keyringConfirmGate returns a server.CacheGateFunc that asks the user to
+// confirm with ui before a passphrase saved in the keyring is used. Native
+// biometric checks, such as Touch ID or Windows Hello, need platform APIs that
+// are only available with cgo, so an explicit confirmation is used instead.
+func keyringConfirmGate(ui server.UI) server.CacheGateFunc {
+	return func(ctx context.Context, state *server.State) (bool, error) {
+		if state.Desc == "" {
+			state.Desc = keyringConfirmDesc
+		} else {
+			state.Desc += "\n\n" + keyringConfirmDesc
+		}
+		state.Error = ""
+		return ui.Confirm(ctx, state, false)
+	}
+}
+
+// keyringCache returns the cache backend for goos's keyring: the Keychain on
+// macOS, Credential Manager on Windows, and the Secret Service elsewhere.
+func keyringCache(goos string) pinentry.CacheBackend {
+	switch goos {
+	case "darwin":
+		return pinentry.NewKeychainCache(pinentry.DefaultKeychainService)
+	case "windows":
+		return pinentry.NewCredentialManagerCache(pinentry.DefaultCredentialManagerTargetPrefix)
+	default:
+		return pinentry.NewSecretServiceCache(secretServiceApplication)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+func TestKeyringCache(t *testing.T) {
+	_, ok := keyringCache("darwin").(*pinentry.KeychainCache)
+	assert.True(t, ok)
+	_, ok = keyringCache("windows").(*pinentry.CredentialManagerCache)
+	assert.True(t, ok)
+	_, ok = keyringCache("linux").(*pinentry.SecretServiceCache)
+	assert.True(t, ok)
+}
+
+// A confirmUI is a server.UI that records the state passed to Confirm.
+type confirmUI struct {
+	server.UI
+	state     server.State
+	confirmed bool
+}
+
+func (ui *confirmUI) Confirm(_ context.Context, state *server.State, _ bool) (bool, error) {
+	ui.state = *state
+	return ui.confirmed, nil
+}
+
+func TestKeyringConfirmGate(t *testing.T) {
+	ui := &confirmUI{
+		confirmed: true,
+	}
+	confirmed, err := keyringConfirmGate(ui)(context.Background(), &server.State{
+		Desc:  "desc",
+		Error: "error",
+	})
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+	assert.Equal(t, server.State{
+		Desc: "desc\n\n" + keyringConfirmDesc,
+	}, ui.state)
+}
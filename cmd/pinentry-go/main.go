@@ -0,0 +1,94 @@
+// Command pinentry-go is a pinentry that prompts on the terminal. It is useful
+// on minimal systems where no other pinentry is installed.
+//
+// The front end is chosen with --flavor, which defaults to the value of
+// PINENTRY_GO_FLAVOR or auto. The flavors are tty, tui, and gui, of which only
+// tty is currently available. With auto, the first available flavor is chosen
+// using the same environment detection as pinentry.WithAutoBinaryName.
+//
+// With --keyring, passphrases are saved in the OS keyring, like pinentry-mac's
+// and pinentry-gnome3's "Save in keyring", and are returned from it without
+// prompting. As with those pinentries, this only happens when the client
+// allows it with OPTION allow-external-password-cache, which gpg-agent sends
+// unless it is configured with no-allow-external-cache. With --keyring-confirm,
+// the user must also confirm before a passphrase saved in the keyring is used.
+// Biometric checks such as Touch ID and Windows Hello are not supported, as
+// they require cgo.
+//
+// By default it serves a single client on stdin and stdout, as gpg-agent
+// expects. With --listen it instead serves clients one at a time on a Unix
+// socket until it is interrupted.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+var (
+	flavor         = flag.String("flavor", defaultFlavor(), "front end flavor: tty, tui, gui, or auto")
+	keyring        = flag.Bool("keyring", false, "save passphrases in the OS keyring when the client allows it")
+	keyringConfirm = flag.Bool("keyring-confirm", false, "confirm before using a passphrase saved in the OS keyring")
+	listen         = flag.String("listen", "", "serve clients on the Unix socket at this path instead of stdin and stdout")
+	ttyName        = flag.String("ttyname", "", "set the tty terminal node name")
+
+	// Flags passed by gpg-agent and other clients that are accepted and
+	// ignored.
+	_ = flag.Bool("debug", false, "ignored")
+	_ = flag.String("display", "", "ignored")
+	_ = flag.String("lc-ctype", "", "ignored")
+	_ = flag.String("lc-messages", "", "ignored")
+	_ = flag.Bool("no-global-grab", false, "ignored")
+	_ = flag.Int("timeout", 0, "ignored")
+	_ = flag.String("ttytype", "", "ignored")
+)
+
+func run() error {
+	selectedFlavor, frontEnd, err := selectFlavor(*flavor, os.Getenv, runtime.GOOS, hasTTY(*ttyName))
+	if err != nil {
+		return err
+	}
+	ui := frontEnd(*ttyName)
+	options := []server.Option{
+		server.WithFlavor("go-" + selectedFlavor),
+		server.WithUI(ui),
+	}
+	if *keyring {
+		options = append(options, server.WithCache(keyringCache(runtime.GOOS)))
+		if *keyringConfirm {
+			options = append(options, server.WithCacheGate(keyringConfirmGate(ui)))
+		}
+	}
+	s := server.New(options...)
+	if *listen == "" {
+		return s.Serve(context.Background(), os.Stdin, os.Stdout)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	listener, err := net.Listen("unix", *listen)
+	if err != nil {
+		return err
+	}
+	if err := s.ServeListener(ctx, listener); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// makeRaw returns errors.ErrUnsupported.
+func makeRaw(*os.File) (func() error, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,46 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// makeRaw turns off echo and line buffering on f and returns a function that
+// restores the previous state.
+func makeRaw(f *os.File) (func() error, error) {
+	var oldTermios syscall.Termios
+	if err := ioctlTermios(f, ioctlGetTermios, &oldTermios); err != nil {
+		return nil, err
+	}
+	newTermios := oldTermios
+	newTermios.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG
+	newTermios.Cc[syscall.VMIN] = 1
+	newTermios.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(f, ioctlSetTermios, &newTermios); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return ioctlTermios(f, ioctlSetTermios, &oldTermios)
+	}, nil
+}
+
+// ioctlTermios calls ioctl with request and termios on f.
+func ioctlTermios(f *os.File, request uintptr, termios *syscall.Termios) error {
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var errno syscall.Errno
+	if err := rawConn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios)))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
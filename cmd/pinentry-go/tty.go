@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+// Control characters.
+const (
+	ctrlC     = 0x03
+	ctrlD     = 0x04
+	ctrlH     = 0x08
+	ctrlU     = 0x15
+	backspace = 0x7f
+)
+
+// Default texts.
+const (
+	defaultCancel = "Cancel"
+	defaultOK     = "OK"
+	defaultPrompt = "PIN:"
+)
+
+// A choice is a choice offered by confirm.
+type choice struct {
+	label string
+	err   error
+}
+
+// A tty prompts the user on a terminal.
+type tty struct {
+	name string
+}
+
+// Confirm implements server.UI.Confirm.
+func (t *tty) Confirm(ctx context.Context, state *server.State, oneButton bool) (confirmed bool, err error) {
+	err = t.with(ctx, state, func(f *os.File) error {
+		writeHeader(f, state)
+		choices := []choice{
+			{label: orDefault(state.OK, defaultOK)},
+		}
+		if !oneButton {
+			if state.NotOK != "" {
+				choices = append(choices, choice{label: state.NotOK, err: server.ErrNotConfirmed})
+			}
+			choices = append(choices, choice{label: orDefault(state.Cancel, defaultCancel), err: server.ErrCancelled})
+		}
+		labels := make([]string, 0, len(choices))
+		for _, choice := range choices {
+			labels = append(labels, "["+pinentry.StripAccelerators(choice.label)+"]")
+		}
+		reader := bufio.NewReader(f)
+		for {
+			fmt.Fprintf(f, "%s ", strings.Join(labels, " "))
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return readError(ctx, err)
+			}
+			answer := strings.TrimSpace(line)
+			if oneButton {
+				return nil
+			}
+			for _, choice := range choices {
+				if choice.match(answer) {
+					return choice.err
+				}
+			}
+		}
+	})
+	switch {
+	case errors.Is(err, server.ErrNotConfirmed):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// GetPIN implements server.UI.GetPIN. The input is masked.
+func (t *tty) GetPIN(ctx context.Context, state *server.State) (pin *pinentry.Secret, err error) {
+	err = t.with(ctx, state, func(f *os.File) error {
+		restore, err := makeRaw(f)
+		if err != nil {
+			return err
+		}
+		defer restore() //nolint:errcheck
+
+		writeHeader(f, state)
+		pin, err = readMasked(ctx, f, orDefault(state.Prompt, defaultPrompt))
+		return err
+	})
+	return
+}
+
+// Message implements server.UI.Message.
+func (t *tty) Message(ctx context.Context, state *server.State) error {
+	_, err := t.Confirm(ctx, state, true)
+	return err
+}
+
+// with opens the tty and calls f with it. If ctx is done then any blocked read
+// from the tty returns.
+func (t *tty) with(ctx context.Context, state *server.State, f func(*os.File) error) error {
+	name := t.name
+	if ttyName := state.Options["ttyname"]; ttyName != "" {
+		name = ttyName
+	}
+	if name == "" {
+		name = "/dev/tty"
+	}
+	file, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close() //nolint:errcheck
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = file.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	return f(file)
+}
+
+// match returns if answer selects c, either by its accelerator, its first
+// letter, or its full label.
+func (c choice) match(answer string) bool {
+	if answer == "" {
+		return false
+	}
+	stripped := pinentry.StripAccelerators(c.label)
+	if strings.EqualFold(answer, stripped) {
+		return true
+	}
+	r, ok := pinentry.Accelerator(c.label)
+	if !ok {
+		if stripped == "" {
+			return false
+		}
+		r, _ = utf8.DecodeRuneInString(stripped)
+	}
+	answerRunes := []rune(answer)
+	return len(answerRunes) == 1 && unicode.ToLower(answerRunes[0]) == unicode.ToLower(r)
+}
+
+// orDefault returns s, or defaultS if s is empty.
+func orDefault(s, defaultS string) string {
+	if s == "" {
+		return defaultS
+	}
+	return s
+}
+
+// readError returns the error to return for err, returned by a read.
+func readError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, io.EOF) {
+		return server.ErrCancelled
+	}
+	return err
+}
+
+// readMasked prompts for and reads a line from f, which must be in raw mode,
+// echoing an asterisk for each character. The line is read into a buffer that
+// is zeroed whenever it is discarded or reallocated.
+func readMasked(ctx context.Context, f *os.File, prompt string) (*pinentry.Secret, error) {
+	fmt.Fprintf(f, "%s ", prompt)
+	line := make([]byte, 0, 64)
+	zero := func() {
+		line = line[:cap(line)]
+		for i := range line {
+			line[i] = 0
+		}
+		line = line[:0]
+	}
+	buffer := make([]byte, 1)
+	for {
+		if _, err := f.Read(buffer); err != nil {
+			zero()
+			return nil, readError(ctx, err)
+		}
+		switch c := buffer[0]; c {
+		case '\r', '\n':
+			fmt.Fprintln(f)
+			return pinentry.NewSecret(line), nil
+		case ctrlC, ctrlD:
+			fmt.Fprintln(f)
+			zero()
+			return nil, server.ErrCancelled
+		case ctrlH, backspace:
+			if len(line) > 0 {
+				// Remove a whole UTF-8 sequence.
+				i := len(line) - 1
+				for i > 0 && line[i]&0xc0 == 0x80 {
+					i--
+				}
+				for j := i; j < len(line); j++ {
+					line[j] = 0
+				}
+				line = line[:i]
+				fmt.Fprint(f, "\b \b")
+			}
+		case ctrlU:
+			fmt.Fprint(f, strings.Repeat("\b \b", utf8.RuneCount(line)))
+			zero()
+		default:
+			if len(line) == cap(line) {
+				grown := make([]byte, len(line), 2*cap(line))
+				copy(grown, line)
+				zero()
+				line = grown
+			}
+			line = append(line, c)
+			buffer[0] = 0
+			if c&0xc0 != 0x80 {
+				fmt.Fprint(f, "*")
+			}
+		}
+	}
+}
+
+// writeHeader writes the title, description, and error in state to f.
+func writeHeader(f *os.File, state *server.State) {
+	for _, s := range []string{state.Title, state.Desc, state.Error} {
+		if s != "" {
+			fmt.Fprintln(f, pinentry.SanitizeText(s))
+		}
+	}
+}
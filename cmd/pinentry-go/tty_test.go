@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestChoiceMatch(t *testing.T) {
+	for _, tc := range []struct {
+		label    string
+		answer   string
+		expected bool
+	}{
+		{label: "_OK", answer: "o", expected: true},
+		{label: "_OK", answer: "O", expected: true},
+		{label: "_OK", answer: "ok", expected: true},
+		{label: "_OK", answer: "OK", expected: true},
+		{label: "_OK", answer: "k"},
+		{label: "_OK", answer: ""},
+		{label: "Can_cel", answer: "c", expected: true},
+		{label: "Can_cel", answer: "C", expected: true},
+		{label: "Can_cel", answer: "cancel", expected: true},
+		{label: "Cancel", answer: "c", expected: true},
+		{label: "Cancel", answer: "a"},
+		{label: "Ändern", answer: "ä", expected: true},
+		{label: "_", answer: "x"},
+		{label: "_", answer: "_"},
+		{label: "", answer: "x"},
+	} {
+		t.Run(tc.label+"/"+tc.answer, func(t *testing.T) {
+			assert.Equal(t, tc.expected, choice{label: tc.label}.match(tc.answer))
+		})
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		defaultS string
+		expected string
+	}{
+		{s: "s", defaultS: "default", expected: "s"},
+		{s: "", defaultS: "default", expected: "default"},
+		{s: "", defaultS: "", expected: ""},
+	} {
+		assert.Equal(t, tc.expected, orDefault(tc.s, tc.defaultS))
+	}
+}
@@ -0,0 +1,111 @@
+// Command pinentry-proxy is a pinentry that forwards to another pinentry,
+// rewriting the client's commands in flight according to a rules file, for
+// example to enforce enterprise policies or to debug clients.
+//
+// The rules file contains one rule per line. Empty lines and lines starting
+// with # are ignored. The rules are:
+//
+//	set COMMAND [ARGS]  replace the arguments of COMMAND with ARGS
+//	drop COMMAND        answer COMMAND with OK without forwarding it
+//
+// ARGS are escaped as in the Assuan protocol. Rules apply only to commands,
+// not to the data lines and END sent in response to inquiries. For example:
+//
+//	# Brand the window title.
+//	set SETTITLE ACME Corp
+//	# Never time out.
+//	drop SETTIMEOUT
+//	# Do not let the pinentry identify the key.
+//	drop SETKEYINFO
+//
+// The flags that gpg-agent passes, such as --display and --ttyname, are
+// passed on to the pinentry.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+)
+
+var (
+	pinentryBinary = flag.String("pinentry", "pinentry", "pinentry to forward to")
+	rulesFile      = flag.String("rules", "", "rules file")
+
+	// Flags passed by gpg-agent and other clients that are passed on to the
+	// pinentry.
+	_ = flag.Bool("debug", false, "passed to the pinentry")
+	_ = flag.String("display", "", "passed to the pinentry")
+	_ = flag.String("lc-ctype", "", "passed to the pinentry")
+	_ = flag.String("lc-messages", "", "passed to the pinentry")
+	_ = flag.Bool("no-global-grab", false, "passed to the pinentry")
+	_ = flag.Int("timeout", 0, "passed to the pinentry")
+	_ = flag.String("ttyname", "", "passed to the pinentry")
+	_ = flag.String("ttytype", "", "passed to the pinentry")
+)
+
+// pinentryArgs returns the arguments to pass to the pinentry.
+func pinentryArgs() []string {
+	var args []string
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "pinentry", "rules":
+		default:
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args
+}
+
+// readRules reads the rules from the rules file, if any.
+func readRules() (rules, error) {
+	if *rulesFile == "" {
+		return rules{}, nil
+	}
+	file, err := os.Open(*rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	result, err := parseRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", *rulesFile, err)
+	}
+	return result, nil
+}
+
+func run() error {
+	rules, err := readRules()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(*pinentryBinary, pinentryArgs()...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if err := proxy(rules, os.Stdin, os.Stdout, stdout, stdin); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return err
+	}
+	return cmd.Wait()
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
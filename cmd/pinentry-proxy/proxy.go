@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/twpayne/go-pinentry/v4/internal/assuan"
+)
+
+// A lockedWriter serializes writes to its underlying writer.
+type lockedWriter struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+func (w *lockedWriter) Write(data []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.w.Write(data)
+}
+
+// proxy forwards the client's commands from clientR to the pinentry on
+// pinentryW, applying rules, and the pinentry's responses from pinentryR to
+// clientW. When clientR reaches EOF, pinentryW is closed and proxy waits for
+// the pinentry to close pinentryR.
+func proxy(rules rules, clientR io.Reader, clientW io.Writer, pinentryR io.Reader, pinentryW io.WriteCloser) error {
+	lockedClientW := &lockedWriter{w: clientW}
+	copyErrCh := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(lockedClientW, pinentryR)
+		copyErrCh <- err
+	}()
+
+	err := forwardCommands(rules, clientR, lockedClientW, pinentryW)
+	if closeErr := pinentryW.Close(); err == nil {
+		err = closeErr
+	}
+	if copyErr := <-copyErrCh; err == nil {
+		err = copyErr
+	}
+	return err
+}
+
+// forwardCommands forwards lines from clientR to pinentryW until clientR
+// reaches EOF. Commands dropped by rules are answered with OK on clientW.
+// Assuan clients wait for each response before sending the next command, so
+// the pinentry's previous response has already been written to clientW.
+func forwardCommands(rules rules, clientR io.Reader, clientW, pinentryW io.Writer) error {
+	reader := bufio.NewReader(clientR)
+	for {
+		line, err := assuan.ReadLine(reader)
+		switch {
+		case errors.Is(err, assuan.ErrLineTooLong):
+			if _, err := io.WriteString(clientW, assuan.ErrorLine(assuan.ErrorCodeLineTooLong, "Line too long")+"\n"); err != nil {
+				return err
+			}
+			continue
+		case errors.Is(err, io.EOF) && line == "":
+			return nil
+		case err != nil && !errors.Is(err, io.EOF):
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		keyword, _, _ := strings.Cut(line, " ")
+		if !isResponseKeyword(keyword) {
+			var forward bool
+			if line, forward = rules.apply(line); !forward {
+				if _, err := io.WriteString(clientW, "OK\n"); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if _, err := io.WriteString(pinentryW, line+"\n"); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+func TestParseRules(t *testing.T) {
+	actualRules, err := parseRules(strings.NewReader(strings.Join([]string{
+		"# Comment",
+		"",
+		"set SETTITLE ACME Corp",
+		"set SETKEYINFO",
+		"drop SETTIMEOUT",
+	}, "\n")))
+	assert.NoError(t, err)
+	assert.Equal(t, rules{
+		"SETKEYINFO": {},
+		"SETTIMEOUT": {drop: true},
+		"SETTITLE":   {args: "ACME Corp"},
+	}, actualRules)
+
+	for _, line := range []string{
+		"set",
+		"drop SETTIMEOUT 5",
+		"drop END",
+		"replace SETTITLE ACME Corp",
+	} {
+		_, err := parseRules(strings.NewReader(line))
+		assert.IsError(t, err, errInvalidRule)
+	}
+}
+
+func TestRulesApply(t *testing.T) {
+	testRules := rules{
+		"SETKEYINFO": {},
+		"SETTIMEOUT": {drop: true},
+		"SETTITLE":   {args: "ACME Corp"},
+	}
+	for _, tc := range []struct {
+		line            string
+		expectedLine    string
+		expectedForward bool
+	}{
+		{line: "GETPIN", expectedLine: "GETPIN", expectedForward: true},
+		{line: "SETDESC Enter PIN", expectedLine: "SETDESC Enter PIN", expectedForward: true},
+		{line: "SETKEYINFO n/0123", expectedLine: "SETKEYINFO", expectedForward: true},
+		{line: "SETTIMEOUT 5", expectedForward: false},
+		{line: "SETTITLE Title", expectedLine: "SETTITLE ACME Corp", expectedForward: true},
+	} {
+		t.Run(tc.line, func(t *testing.T) {
+			line, forward := testRules.apply(tc.line)
+			assert.Equal(t, tc.expectedLine, line)
+			assert.Equal(t, tc.expectedForward, forward)
+		})
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var states []server.State
+	s := server.New(
+		server.WithGetPINFunc(func(_ context.Context, state *server.State) (*pinentry.Secret, error) {
+			states = append(states, *state)
+			return pinentry.NewSecret([]byte("abc")), nil
+		}),
+	)
+	toPinentryR, toPinentryW := io.Pipe()
+	fromPinentryR, fromPinentryW := io.Pipe()
+	go func() {
+		_ = fromPinentryW.CloseWithError(s.Serve(context.Background(), toPinentryR, fromPinentryW))
+	}()
+
+	clientInR, clientInW := io.Pipe()
+	clientOutR, clientOutW := io.Pipe()
+	proxyErrCh := make(chan error, 1)
+	go func() {
+		err := proxy(rules{
+			"SETTIMEOUT": {drop: true},
+			"SETTITLE":   {args: "ACME Corp"},
+		}, clientInR, clientOutW, fromPinentryR, toPinentryW)
+		_ = clientOutW.Close()
+		proxyErrCh <- err
+	}()
+
+	reader := bufio.NewReader(clientOutR)
+	readResponse := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			assert.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			lines = append(lines, line)
+			if line == "OK" || strings.HasPrefix(line, "OK ") || strings.HasPrefix(line, "ERR ") {
+				return lines
+			}
+		}
+	}
+	transact := func(command string) []string {
+		_, err := io.WriteString(clientInW, command+"\n")
+		assert.NoError(t, err)
+		return readResponse()
+	}
+
+	assert.Equal(t, []string{"OK Pleased to meet you"}, readResponse())
+	assert.Equal(t, []string{"OK"}, transact("SETTIMEOUT 5"))
+	assert.Equal(t, []string{"OK"}, transact("SETTITLE Title"))
+	assert.Equal(t, []string{"OK"}, transact("SETDESC Enter PIN"))
+	assert.Equal(t, []string{"D abc", "OK"}, transact("GETPIN"))
+	assert.Equal(t, []string{"ERR 536871175 Line too long"}, transact(strings.Repeat("X", 2000)))
+	assert.Equal(t, []string{"OK closing connection"}, transact("BYE"))
+	assert.NoError(t, clientInW.Close())
+	assert.NoError(t, <-proxyErrCh)
+
+	assert.Equal(t, 1, len(states))
+	assert.Equal(t, "ACME Corp", states[0].Title)
+	assert.Equal(t, "Enter PIN", states[0].Desc)
+	assert.Equal(t, time.Duration(0), states[0].Timeout)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// errInvalidRule is returned by parseRules when a rule is invalid.
+var errInvalidRule = errors.New("invalid rule")
+
+// A rule is applied to a command. If drop is set then the command is answered
+// with OK without being forwarded. Otherwise, the command's arguments are
+// replaced with args.
+type rule struct {
+	drop bool
+	args string
+}
+
+// rules maps commands to the rules applied to them.
+type rules map[string]rule
+
+// parseRules parses rules from r.
+func parseRules(r io.Reader) (rules, error) {
+	result := make(rules)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		action, rest, _ := strings.Cut(line, " ")
+		command, args, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+		switch {
+		case command == "" || isResponseKeyword(command):
+			return nil, fmt.Errorf("line %d: %w: %s", lineNumber, errInvalidRule, line)
+		case action == "set":
+			result[command] = rule{args: args}
+		case action == "drop" && args == "":
+			result[command] = rule{drop: true}
+		default:
+			return nil, fmt.Errorf("line %d: %w: %s", lineNumber, errInvalidRule, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// apply applies the rule for the command in line. It returns the line to
+// forward and whether to forward it.
+func (r rules) apply(line string) (string, bool) {
+	command, _, _ := strings.Cut(line, " ")
+	rule, ok := r[command]
+	switch {
+	case !ok:
+		return line, true
+	case rule.drop:
+		return "", false
+	case rule.args == "":
+		return command, true
+	default:
+		return command + " " + rule.args, true
+	}
+}
+
+// isResponseKeyword returns whether keyword starts a line sent by the client
+// in response to an inquiry rather than a command.
+func isResponseKeyword(keyword string) bool {
+	switch keyword {
+	case "CAN", "D", "END":
+		return true
+	default:
+		return false
+	}
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/twpayne/go-pinentry/v4"
 )
 
+var repl = flag.Bool("repl", false, "read raw Assuan commands from stdin")
+
 func run() error {
 	client, err := pinentry.NewClient(
 		pinentry.WithBinaryNameFromGnuPGAgentConf(),
@@ -46,8 +52,75 @@ func run() error {
 	return nil
 }
 
+// runREPL reads raw Assuan commands from stdin, writes them to the pinentry
+// process, and prints the responses. Data is redacted unless redaction is
+// turned off with /redact off.
+func runREPL() error {
+	client, err := pinentry.NewClient(
+		pinentry.WithBinaryNameFromGnuPGAgentConf(),
+		pinentry.WithGPGTTY(),
+	)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.Close(); err != nil {
+			slog.Error("close", "err", err)
+		}
+	}()
+
+	fmt.Println("Type Assuan commands, /redact on|off to toggle redaction of data, or /quit to quit.")
+	redact := true
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "/quit":
+			return nil
+		case "/redact on":
+			redact = true
+			continue
+		case "/redact off":
+			redact = false
+			continue
+		}
+
+		if err := client.WriteCommand(line); err != nil {
+			return err
+		}
+		response, err := client.ReadResponse()
+		var assuanError *pinentry.AssuanError
+		switch {
+		case errors.As(err, &assuanError):
+			fmt.Printf("ERR %d %s\n", assuanError.Code, assuanError.Description)
+			continue
+		case err != nil:
+			return err
+		}
+		if response.Data != nil {
+			if redact {
+				fmt.Println("D [redacted]")
+			} else {
+				fmt.Printf("D %s\n", response.Data)
+			}
+		}
+		for _, status := range response.Status {
+			fmt.Printf("S %s\n", status)
+		}
+		fmt.Println(strings.TrimSpace("OK " + response.OK))
+	}
+	return scanner.Err()
+}
+
 func main() {
-	if err := run(); err != nil {
+	flag.Parse()
+	runFunc := run
+	if *repl {
+		runFunc = runREPL
+	}
+	if err := runFunc(); err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
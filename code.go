@@ -0,0 +1,147 @@
+package pinentry
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A CodePrompt describes a request for a short numeric code, such as a TOTP
+// code.
+type CodePrompt struct {
+	// Desc is the description. If Expiry is set then the time remaining until
+	// the code expires is appended to it.
+	Desc string
+	// Digits is the number of digits in the code, or zero for any number.
+	Digits int
+	// Expiry is the time at which the code expires, or the zero time if the
+	// code does not expire.
+	Expiry time.Time
+	// Visible allows the user to show the code while typing it, if the
+	// pinentry flavor supports it.
+	Visible bool
+}
+
+// GetCode gets a numeric code from the user. Spaces in the code are ignored.
+// If the user enters anything other than the expected number of digits then
+// the user is prompted again. If the code expires then ErrCodeExpired is
+// returned. If the user cancels, an error is returned which can be tested with
+// IsCancelled. The previous description, timeout, and tooltips are restored
+// afterwards.
+func (c *Client) GetCode(prompt CodePrompt) (code string, err error) {
+	if err = c.tryLock(); err != nil {
+		return
+	}
+	defer c.mu.Unlock()
+
+	if prompt.Visible {
+		for _, option := range []struct {
+			name  string
+			value string
+		}{
+			{name: "default-tt-visi", value: "Show the code"},
+			{name: "default-tt-hide", value: "Hide the code"},
+		} {
+			previousCommand := c.optionCommand(option.name)
+			defer func() {
+				if c.killed.Load() {
+					return
+				}
+				combineErrorFunc(&err, func() error {
+					return c.command(previousCommand)
+				})
+			}()
+			if err = c.command("OPTION " + option.name + "=" + escape(option.value)); err != nil {
+				return
+			}
+		}
+	}
+
+	getPINTimeout := c.getPINTimeout
+	previousDesc, descSet := c.texts["SETDESC"], false
+	defer func() {
+		c.getPINTimeout = getPINTimeout
+		if c.killed.Load() {
+			return
+		}
+		if descSet {
+			command := "SETDESC"
+			if previousDesc != "" {
+				command += " " + previousDesc
+			}
+			combineErrorFunc(&err, func() error {
+				return c.command(command)
+			})
+		}
+		combineErrorFunc(&err, func() error {
+			return c.setTimeout(nil)
+		})
+	}()
+
+	for {
+		desc := prompt.Desc
+		if !prompt.Expiry.IsZero() {
+			remaining := time.Until(prompt.Expiry).Truncate(time.Second)
+			if remaining <= 0 {
+				err = ErrCodeExpired
+				return
+			}
+			desc = strings.TrimPrefix(fmt.Sprintf("%s\n\nThe code expires in %d seconds.", desc, remaining/time.Second), "\n\n")
+			c.getPINTimeout = &remaining
+		}
+		descSet = true
+		if err = c.command("SETDESC " + escapeText(desc)); err != nil {
+			return
+		}
+
+		var result GetPINResult
+		secretResult, getPINErr := withNoGlobalGrabRetry(c, c.getPIN)
+		if result, err = secretResult.pinResult(getPINErr); err != nil {
+			return
+		}
+		if !prompt.Expiry.IsZero() && !time.Now().Before(prompt.Expiry) {
+			err = ErrCodeExpired
+			return
+		}
+		if code = strings.ReplaceAll(result.PIN, " ", ""); validCode(code, prompt.Digits) {
+			return
+		}
+		code = ""
+
+		var errorText string
+		if prompt.Digits > 0 {
+			errorText = fmt.Sprintf("Enter %d digits", prompt.Digits)
+		} else {
+			errorText = "Enter digits only"
+		}
+		if err = c.command("SETERROR " + escape(errorText)); err != nil {
+			return
+		}
+	}
+}
+
+// optionCommand returns the last command set by options that sets the option
+// name, or a command that sets it to the empty string if there is none.
+func (c *Client) optionCommand(name string) string {
+	prefix := "OPTION " + name + "="
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		if strings.HasPrefix(c.commands[i], prefix) {
+			return c.commands[i]
+		}
+	}
+	return prefix
+}
+
+// validCode returns whether code consists of only digits and, if digits is
+// positive, has exactly digits digits.
+func validCode(code string, digits int) bool {
+	if code == "" || digits > 0 && len(code) != digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || '9' < r {
+			return false
+		}
+	}
+	return true
+}
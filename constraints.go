@@ -0,0 +1,177 @@
+package pinentry
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CharClasses is a set of classes of characters.
+type CharClasses int
+
+// Character classes.
+const (
+	CharClassLower CharClasses = 1 << iota
+	CharClassUpper
+	CharClassDigit
+	CharClassSymbol
+)
+
+// Constraints are constraints on new passphrases. MinLen is the minimum
+// length in characters and RequireClasses are the classes of characters that
+// must each occur at least once. HintShort, HintLong, and ErrorTitle are shown
+// by pinentries that support constraints.
+type Constraints struct {
+	MinLen         int
+	RequireClasses CharClasses
+	HintShort      string
+	HintLong       string
+	ErrorTitle     string
+}
+
+// A ConstraintsError is returned when a passphrase does not meet its
+// constraints. Its message is suitable for showing to the user.
+type ConstraintsError struct {
+	MinLen         int
+	MissingClasses CharClasses
+}
+
+func (e *ConstraintsError) Error() string {
+	var requirements []string
+	if e.MinLen > 0 {
+		requirements = append(requirements, "be at least "+strconv.Itoa(e.MinLen)+" characters long")
+	}
+	var missingClasses []string
+	for _, charClass := range []struct {
+		charClass CharClasses
+		name      string
+	}{
+		{charClass: CharClassLower, name: "a lowercase letter"},
+		{charClass: CharClassUpper, name: "an uppercase letter"},
+		{charClass: CharClassDigit, name: "a digit"},
+		{charClass: CharClassSymbol, name: "a symbol"},
+	} {
+		if e.MissingClasses&charClass.charClass != 0 {
+			missingClasses = append(missingClasses, charClass.name)
+		}
+	}
+	if len(missingClasses) > 0 {
+		requirements = append(requirements, "contain "+joinList(missingClasses))
+	}
+	return "Passphrase must " + joinList(requirements) + "."
+}
+
+// WithPassphraseConstraints sets constraints on the passphrases returned by
+// GetPIN and related methods. Pinentries that support constraints are asked to
+// enforce them, checking each passphrase with the client before accepting it.
+// With older pinentries, each passphrase is checked after it is entered and, if
+// it does not meet the constraints, the user is prompted again with the reason
+// as the error text until it does or the user cancels.
+func WithPassphraseConstraints(constraints Constraints) ClientOption {
+	return func(c *Client) {
+		c.constraints = &constraints
+		WithInquiryHandler("CHECKPIN", func(inquiry *Inquiry) error {
+			if err := constraints.Check(getPIN([]byte(inquiry.Args))); err != nil {
+				if _, err := inquiry.Write([]byte(err.Error())); err != nil {
+					return err
+				}
+			}
+			return inquiry.End()
+		})(c)
+	}
+}
+
+// Check returns a *ConstraintsError if pin does not meet cs.
+func (cs Constraints) Check(pin string) error {
+	return cs.check([]byte(pin))
+}
+
+// check returns a *ConstraintsError if pin does not meet cs.
+func (cs Constraints) check(pin []byte) error {
+	var classes CharClasses
+	for i := 0; i < len(pin); {
+		r, size := utf8.DecodeRune(pin[i:])
+		i += size
+		switch {
+		case unicode.IsLower(r):
+			classes |= CharClassLower
+		case unicode.IsUpper(r):
+			classes |= CharClassUpper
+		case unicode.IsDigit(r):
+			classes |= CharClassDigit
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			classes |= CharClassSymbol
+		}
+	}
+	var constraintsError ConstraintsError
+	if utf8.RuneCount(pin) < cs.MinLen {
+		constraintsError.MinLen = cs.MinLen
+	}
+	constraintsError.MissingClasses = cs.RequireClasses &^ classes
+	if constraintsError == (ConstraintsError{}) {
+		return nil
+	}
+	return &constraintsError
+}
+
+// getPINWithConstraints gets a PIN from the user, prompting again with the
+// reason as the error text until the PIN meets the constraints, if any.
+func (c *Client) getPINWithConstraints() (secretResult, error) {
+	for {
+		result, err := c.getPIN()
+		if err != nil || c.constraints == nil {
+			return result, err
+		}
+		constraintsErr := c.constraints.check(result.secret.Bytes())
+		if constraintsErr == nil {
+			return result, nil
+		}
+		result.secret.Zero()
+		if err := c.command("SETERROR " + escape(constraintsErr.Error())); err != nil {
+			return secretResult{}, err
+		}
+	}
+}
+
+// setConstraintsOptions asks the pinentry process to enforce the constraints,
+// if any. Pinentries that do not support constraints reject the options, in
+// which case the constraints are only checked by getPINWithConstraints.
+func (c *Client) setConstraintsOptions() error {
+	if c.constraints == nil {
+		return nil
+	}
+	if ok, err := c.optionalCommand("OPTION " + OptionConstraintsEnforce); !ok || err != nil {
+		return err
+	}
+	for _, option := range []struct {
+		name  string
+		value string
+	}{
+		{name: OptionConstraintsHintShort, value: c.constraints.HintShort},
+		{name: OptionConstraintsHintLong, value: c.constraints.HintLong},
+		{name: OptionConstraintsErrorTitle, value: c.constraints.ErrorTitle},
+	} {
+		if option.value == "" {
+			continue
+		}
+		if err := c.command("OPTION " + option.name + "=" + escape(option.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinList joins items as an English list.
+func joinList(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	case 2:
+		return items[0] + " and " + items[1]
+	default:
+		return strings.Join(items[:len(items)-1], ", ") + ", and " + items[len(items)-1]
+	}
+}
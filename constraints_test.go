@@ -0,0 +1,33 @@
+package pinentry
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestConstraintsCheck(t *testing.T) {
+	constraints := Constraints{
+		MinLen:         4,
+		RequireClasses: CharClassUpper | CharClassDigit | CharClassSymbol,
+	}
+	for _, tc := range []struct {
+		pin             string
+		expectedMessage string
+	}{
+		{pin: "Ab1!"},
+		{pin: "Äb1€"},
+		{pin: "Ab1", expectedMessage: "Passphrase must be at least 4 characters long and contain a symbol."},
+		{pin: "ab1!", expectedMessage: "Passphrase must contain an uppercase letter."},
+		{pin: "a b", expectedMessage: "Passphrase must be at least 4 characters long and contain an uppercase letter, a digit, and a symbol."},
+	} {
+		t.Run(tc.pin, func(t *testing.T) {
+			err := constraints.Check(tc.pin)
+			if tc.expectedMessage == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedMessage)
+			}
+		})
+	}
+}
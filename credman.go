@@ -0,0 +1,41 @@
+package pinentry
+
+// DefaultCredentialManagerTargetPrefix is the default prefix of the target
+// names of credentials stored by a CredentialManagerCache.
+const DefaultCredentialManagerTargetPrefix = "go-pinentry:"
+
+// A CredentialManagerCache is a CacheBackend that stores passphrases in the
+// Windows Credential Manager as generic credentials with a target name of a
+// prefix followed by the key info. On other systems, its methods return
+// errors.ErrUnsupported.
+type CredentialManagerCache struct {
+	targetPrefix string
+}
+
+// NewCredentialManagerCache returns a new CredentialManagerCache that stores
+// credentials with target names starting with targetPrefix, for example
+// DefaultCredentialManagerTargetPrefix.
+func NewCredentialManagerCache(targetPrefix string) *CredentialManagerCache {
+	return &CredentialManagerCache{
+		targetPrefix: targetPrefix,
+	}
+}
+
+// Get implements CacheBackend.Get.
+func (cc *CredentialManagerCache) Get(keyInfo string) (*Secret, bool, error) {
+	passphrase, ok, err := readCredential(cc.targetPrefix + keyInfo)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return NewSecret(passphrase), true, nil
+}
+
+// Invalidate implements CacheBackend.Invalidate.
+func (cc *CredentialManagerCache) Invalidate(keyInfo string) error {
+	return deleteCredential(cc.targetPrefix + keyInfo)
+}
+
+// Put implements CacheBackend.Put.
+func (cc *CredentialManagerCache) Put(keyInfo string, passphrase []byte) error {
+	return writeCredential(cc.targetPrefix+keyInfo, keyInfo, passphrase)
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package pinentry
+
+import "errors"
+
+// readCredential returns errors.ErrUnsupported as there is no Credential
+// Manager.
+func readCredential(string) ([]byte, bool, error) {
+	return nil, false, errors.ErrUnsupported
+}
+
+// deleteCredential returns errors.ErrUnsupported as there is no Credential
+// Manager.
+func deleteCredential(string) error {
+	return errors.ErrUnsupported
+}
+
+// writeCredential returns errors.ErrUnsupported as there is no Credential
+// Manager.
+func writeCredential(string, string, []byte) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,20 @@
+package pinentry
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCredentialManagerCacheUnsupported(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Credential Manager is supported on Windows")
+	}
+	cc := NewCredentialManagerCache(DefaultCredentialManagerTargetPrefix)
+	_, _, err := cc.Get("n/0123")
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+	assert.True(t, errors.Is(cc.Put("n/0123", []byte("abc")), errors.ErrUnsupported))
+	assert.True(t, errors.Is(cc.Invalidate("n/0123"), errors.ErrUnsupported))
+}
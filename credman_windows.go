@@ -0,0 +1,104 @@
+package pinentry
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+)
+
+// A credential is a CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// readCredential returns the blob of the generic credential with targetName.
+// The blob returned by the Credential Manager is zeroed after it is copied.
+func readCredential(targetName string) ([]byte, bool, error) {
+	targetNameUTF16, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return nil, false, err
+	}
+	var cred *credential
+	if r1, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(targetNameUTF16)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r1 == 0 {
+		if errors.Is(err, errorNotFound) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred))) //nolint:errcheck
+
+	var blob []byte
+	if cred.CredentialBlobSize > 0 {
+		blob = unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	}
+	data := append([]byte(nil), blob...)
+	for i := range blob {
+		blob[i] = 0
+	}
+	return data, true, nil
+}
+
+// deleteCredential deletes the generic credential with targetName, if it
+// exists.
+func deleteCredential(targetName string) error {
+	targetNameUTF16, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return err
+	}
+	if r1, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(targetNameUTF16)), credTypeGeneric, 0); r1 == 0 && !errors.Is(err, errorNotFound) {
+		return err
+	}
+	return nil
+}
+
+// writeCredential creates or replaces the generic credential with targetName
+// and userName, persisted on the local machine, with blob.
+func writeCredential(targetName, userName string, blob []byte) error {
+	targetNameUTF16, err := syscall.UTF16PtrFromString(targetName)
+	if err != nil {
+		return err
+	}
+	userNameUTF16, err := syscall.UTF16PtrFromString(userName)
+	if err != nil {
+		return err
+	}
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         targetNameUTF16,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           userNameUTF16,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+	if r1, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r1 == 0 {
+		return err
+	}
+	return nil
+}
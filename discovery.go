@@ -0,0 +1,110 @@
+package pinentry
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// macOSPinentryBinaries are the standard locations of pinentry-touchid and
+// pinentry-mac installed by Homebrew, MacPorts, and GPG Suite, in order of
+// preference.
+var macOSPinentryBinaries = []string{
+	"/opt/homebrew/bin/pinentry-touchid",
+	"/usr/local/bin/pinentry-touchid",
+	"/opt/homebrew/bin/pinentry-mac",
+	"/usr/local/bin/pinentry-mac",
+	"/opt/local/bin/pinentry-mac",
+	"/Applications/MacPorts/pinentry-mac.app/Contents/MacOS/pinentry-mac",
+	"/usr/local/MacGPG2/libexec/pinentry-mac.app/Contents/MacOS/pinentry-mac",
+}
+
+// Windows registry keys containing the install directory of Gpg4win and
+// GnuPG, in order of preference.
+var windowsRegistryInstallDirectoryKeys = []string{
+	`Software\Gpg4win`,
+	`Software\GnuPG`,
+}
+
+// Windows pinentry binaries in the bin subdirectory of the Gpg4win and GnuPG
+// install directories, in order of preference.
+var windowsPinentryBinaries = []string{
+	"pinentry.exe",
+	"pinentry-qt.exe",
+	"pinentry-w32.exe",
+	"pinentry-basic.exe",
+}
+
+// WithBinaryNameFromMacOSLocations sets the name of the pinentry binary to the
+// best pinentry found in the standard locations used by Homebrew, MacPorts,
+// and GPG Suite, preferring pinentry-touchid over pinentry-mac. It does nothing
+// on other operating systems or if no pinentry is found.
+func WithBinaryNameFromMacOSLocations() ClientOption {
+	if runtime.GOOS != "darwin" {
+		return nil
+	}
+	binaryName, ok := firstExistingFile(macOSPinentryBinaries, fileExists)
+	if !ok {
+		return nil
+	}
+	return WithBinaryName(binaryName)
+}
+
+// WithBinaryNameFromWindowsRegistry sets the name of the pinentry binary to
+// the pinentry shipped with Gpg4win or GnuPG, found from their install
+// directories in the registry or, failing that, in the standard install
+// locations. It does nothing on other operating systems or if no pinentry is
+// found.
+func WithBinaryNameFromWindowsRegistry() ClientOption {
+	if runtime.GOOS != "windows" {
+		return nil
+	}
+	binaryName, ok := windowsPinentryBinaryName(readRegistryString, os.Getenv, fileExists)
+	if !ok {
+		return nil
+	}
+	return WithBinaryName(binaryName)
+}
+
+// windowsPinentryBinaryName returns the path to the first pinentry binary
+// found in the install directories of Gpg4win and GnuPG.
+func windowsPinentryBinaryName(readRegistryString func(string, string) (string, bool), getenv func(string) string, fileExists func(string) bool) (string, bool) {
+	var installDirs []string
+	for _, key := range windowsRegistryInstallDirectoryKeys {
+		if installDir, ok := readRegistryString(key, "Install Directory"); ok && installDir != "" {
+			installDirs = append(installDirs, installDir)
+		}
+	}
+	for _, programFilesVar := range []string{"ProgramFiles(x86)", "ProgramFiles"} {
+		if programFiles := getenv(programFilesVar); programFiles != "" {
+			installDirs = append(installDirs,
+				filepath.Join(programFiles, "Gpg4win"),
+				filepath.Join(programFiles, "GnuPG"),
+			)
+		}
+	}
+	for _, installDir := range installDirs {
+		for _, binary := range windowsPinentryBinaries {
+			if binaryName := filepath.Join(installDir, "bin", binary); fileExists(binaryName) {
+				return binaryName, true
+			}
+		}
+	}
+	return "", false
+}
+
+// firstExistingFile returns the first of names that exists.
+func firstExistingFile(names []string, fileExists func(string) bool) (string, bool) {
+	for _, name := range names {
+		if fileExists(name) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// fileExists returns whether name exists and is a regular file.
+func fileExists(name string) bool {
+	fileInfo, err := os.Stat(name)
+	return err == nil && fileInfo.Mode().IsRegular()
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package pinentry
+
+// readRegistryString returns false as there is no registry.
+func readRegistryString(string, string) (string, bool) {
+	return "", false
+}
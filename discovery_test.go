@@ -0,0 +1,60 @@
+package pinentry
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestFirstExistingFile(t *testing.T) {
+	fileExists := func(name string) bool {
+		return name == "/opt/homebrew/bin/pinentry-mac" || name == "/opt/local/bin/pinentry-mac"
+	}
+	binaryName, ok := firstExistingFile(macOSPinentryBinaries, fileExists)
+	assert.True(t, ok)
+	assert.Equal(t, "/opt/homebrew/bin/pinentry-mac", binaryName)
+
+	_, ok = firstExistingFile(macOSPinentryBinaries, func(string) bool { return false })
+	assert.False(t, ok)
+}
+
+func TestWindowsPinentryBinaryName(t *testing.T) {
+	env := map[string]string{
+		"ProgramFiles":      "/pf",
+		"ProgramFiles(x86)": "/pfx86",
+	}
+	getenv := func(key string) string {
+		return env[key]
+	}
+	noRegistry := func(string, string) (string, bool) {
+		return "", false
+	}
+	registry := func(key, name string) (string, bool) {
+		if key == `Software\GnuPG` && name == "Install Directory" {
+			return "/gnupg", true
+		}
+		return "", false
+	}
+	files := func(names ...string) func(string) bool {
+		return func(name string) bool {
+			for _, n := range names {
+				if name == filepath.FromSlash(n) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	_, ok := windowsPinentryBinaryName(noRegistry, getenv, files())
+	assert.False(t, ok)
+
+	binaryName, ok := windowsPinentryBinaryName(registry, getenv, files("/gnupg/bin/pinentry-basic.exe", "/pfx86/Gpg4win/bin/pinentry.exe"))
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/gnupg/bin/pinentry-basic.exe"), binaryName)
+
+	binaryName, ok = windowsPinentryBinaryName(noRegistry, getenv, files("/pf/GnuPG/bin/pinentry-basic.exe", "/pfx86/Gpg4win/bin/pinentry.exe"))
+	assert.True(t, ok)
+	assert.Equal(t, filepath.FromSlash("/pfx86/Gpg4win/bin/pinentry.exe"), binaryName)
+}
@@ -0,0 +1,51 @@
+package pinentry
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// readRegistryString reads the string value name of the key subkey under
+// HKEY_CURRENT_USER or HKEY_LOCAL_MACHINE, in either the 64-bit or 32-bit
+// registry view.
+func readRegistryString(subkey, name string) (string, bool) {
+	subkeyUTF16, err := syscall.UTF16PtrFromString(subkey)
+	if err != nil {
+		return "", false
+	}
+	nameUTF16, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return "", false
+	}
+	for _, root := range []syscall.Handle{syscall.HKEY_CURRENT_USER, syscall.HKEY_LOCAL_MACHINE} {
+		for _, view := range []uint32{syscall.KEY_WOW64_64KEY, syscall.KEY_WOW64_32KEY} {
+			if value, ok := readRegistryKeyString(root, subkeyUTF16, nameUTF16, view); ok {
+				return value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// readRegistryKeyString reads the string value name of subkey under root in
+// view.
+func readRegistryKeyString(root syscall.Handle, subkey, name *uint16, view uint32) (string, bool) {
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(root, subkey, 0, syscall.KEY_READ|view, &key); err != nil {
+		return "", false
+	}
+	defer syscall.RegCloseKey(key) //nolint:errcheck
+
+	var valueType, size uint32
+	if err := syscall.RegQueryValueEx(key, name, nil, &valueType, nil, &size); err != nil {
+		return "", false
+	}
+	if valueType != syscall.REG_SZ && valueType != syscall.REG_EXPAND_SZ || size < 2 {
+		return "", false
+	}
+	buffer := make([]uint16, size/2)
+	if err := syscall.RegQueryValueEx(key, name, nil, &valueType, (*byte)(unsafe.Pointer(&buffer[0])), &size); err != nil {
+		return "", false
+	}
+	return syscall.UTF16ToString(buffer), true
+}
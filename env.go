@@ -0,0 +1,138 @@
+package pinentry
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvAllowlist is the set of environment variables passed to the
+// pinentry process by WithScrubbedEnvironment when no variables are given. It
+// contains the variables that pinentry needs to find its display, terminal,
+// and locale.
+var DefaultEnvAllowlist = []string{
+	"DBUS_SESSION_BUS_ADDRESS",
+	"DISPLAY",
+	"GPG_TTY",
+	"HOME",
+	"LANG",
+	"LANGUAGE",
+	"LC_ALL",
+	"LC_CTYPE",
+	"LC_MESSAGES",
+	"PATH",
+	"SYSTEMROOT",
+	"TERM",
+	"TMPDIR",
+	"WAYLAND_DISPLAY",
+	"XAUTHORITY",
+	"XDG_RUNTIME_DIR",
+	"XDG_SESSION_TYPE",
+}
+
+// WithEnv sets the environment variables in env in the pinentry process, for
+// example GPG_TTY, DISPLAY, or LANG, without modifying the environment of the
+// current process. Variables not in env are inherited.
+func WithEnv(env map[string]string) ClientOption {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	environ := make([]string, 0, len(keys))
+	for _, key := range keys {
+		environ = append(environ, key+"="+env[key])
+	}
+	return WithEnviron(environ)
+}
+
+// WithEnviron sets the environment variables in environ, each of the form
+// key=value, in the pinentry process without modifying the environment of the
+// current process. Variables not in environ are inherited. If a variable is set
+// more than once then the last value is used.
+func WithEnviron(environ []string) ClientOption {
+	return func(c *Client) {
+		c.env = append(c.env, environ...)
+	}
+}
+
+// WithScrubbedEnvironment starts the pinentry process with only the
+// environment variables in allowlist inherited from the current process, so
+// that the current process's full environment is not leaked to the pinentry
+// process. If allowlist is empty then DefaultEnvAllowlist is used. Variables
+// set with WithEnv, WithEnviron, or WithWaylandEnvironment are always passed.
+func WithScrubbedEnvironment(allowlist ...string) ClientOption {
+	if len(allowlist) == 0 {
+		allowlist = DefaultEnvAllowlist
+	}
+	return func(c *Client) {
+		c.envAllowlist = append(c.envAllowlist, allowlist...)
+	}
+}
+
+// WithWaylandEnvironment forwards WAYLAND_DISPLAY, XDG_RUNTIME_DIR, and
+// XDG_SESSION_TYPE to the pinentry process. In a Wayland session, where
+// global keyboard grabs are not supported, it also passes --no-global-grab.
+func WithWaylandEnvironment() ClientOption {
+	env, wayland := waylandEnvironment(os.LookupEnv)
+	return func(c *Client) {
+		c.env = append(c.env, env...)
+		if wayland {
+			c.args = append(c.args, "--no-global-grab")
+		}
+	}
+}
+
+// waylandEnvironment returns the Wayland-related environment variables and
+// whether the session is a Wayland session.
+func waylandEnvironment(lookupEnv func(string) (string, bool)) ([]string, bool) {
+	var env []string
+	for _, key := range []string{
+		"WAYLAND_DISPLAY",
+		"XDG_RUNTIME_DIR",
+		"XDG_SESSION_TYPE",
+	} {
+		if value, ok := lookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	waylandDisplay, _ := lookupEnv("WAYLAND_DISPLAY")
+	sessionType, _ := lookupEnv("XDG_SESSION_TYPE")
+	return env, waylandDisplay != "" || sessionType == "wayland"
+}
+
+// scrubEnviron returns the variables in environ whose keys are in allowlist.
+// If foldCase is true then keys are compared case-insensitively, as on
+// Windows.
+func scrubEnviron(environ, allowlist []string, foldCase bool) []string {
+	allowed := make(map[string]struct{}, len(allowlist))
+	for _, key := range allowlist {
+		if foldCase {
+			key = strings.ToUpper(key)
+		}
+		allowed[key] = struct{}{}
+	}
+	scrubbed := make([]string, 0, len(allowlist))
+	for _, keyValue := range environ {
+		key, _, ok := strings.Cut(keyValue, "=")
+		if !ok {
+			continue
+		}
+		if foldCase {
+			key = strings.ToUpper(key)
+		}
+		if _, ok := allowed[key]; ok {
+			scrubbed = append(scrubbed, keyValue)
+		}
+	}
+	return scrubbed
+}
+
+// baseEnviron returns the environment inherited by the pinentry process.
+func baseEnviron(allowlist []string) []string {
+	if allowlist == nil {
+		return os.Environ()
+	}
+	return scrubEnviron(os.Environ(), allowlist, runtime.GOOS == "windows")
+}
@@ -0,0 +1,68 @@
+package pinentry
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestWaylandEnvironment(t *testing.T) {
+	for i, tc := range []struct {
+		env             map[string]string
+		expectedEnv     []string
+		expectedWayland bool
+	}{
+		{},
+		{
+			env: map[string]string{
+				"DISPLAY":          ":0",
+				"XDG_RUNTIME_DIR":  "/run/user/1000",
+				"XDG_SESSION_TYPE": "x11",
+			},
+			expectedEnv: []string{
+				"XDG_RUNTIME_DIR=/run/user/1000",
+				"XDG_SESSION_TYPE=x11",
+			},
+		},
+		{
+			env: map[string]string{
+				"WAYLAND_DISPLAY":  "wayland-0",
+				"XDG_RUNTIME_DIR":  "/run/user/1000",
+				"XDG_SESSION_TYPE": "wayland",
+			},
+			expectedEnv: []string{
+				"WAYLAND_DISPLAY=wayland-0",
+				"XDG_RUNTIME_DIR=/run/user/1000",
+				"XDG_SESSION_TYPE=wayland",
+			},
+			expectedWayland: true,
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			actualEnv, actualWayland := waylandEnvironment(func(key string) (string, bool) {
+				value, ok := tc.env[key]
+				return value, ok
+			})
+			assert.Equal(t, tc.expectedEnv, actualEnv)
+			assert.Equal(t, tc.expectedWayland, actualWayland)
+		})
+	}
+}
+
+func TestScrubEnviron(t *testing.T) {
+	environ := []string{
+		"HOME=/home/user",
+		"Path=C:\\Windows",
+		"SECRET=secret",
+		"malformed",
+	}
+	assert.Equal(t, []string{
+		"HOME=/home/user",
+	}, scrubEnviron(environ, []string{"HOME", "PATH"}, false))
+	assert.Equal(t, []string{
+		"HOME=/home/user",
+		"Path=C:\\Windows",
+	}, scrubEnviron(environ, []string{"HOME", "PATH"}, true))
+	assert.Equal(t, []string{}, scrubEnviron(environ, nil, false))
+}
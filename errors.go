@@ -5,6 +5,61 @@ import (
 	"log/slog"
 )
 
+// ErrBrokerClosed is returned by Broker methods after the Broker is closed.
+var ErrBrokerClosed = errors.New("pinentry: broker closed")
+
+// ErrBusy is returned when an operation is requested on a Client while another
+// operation, for example a prompt, is in progress.
+var ErrBusy = errors.New("pinentry: another operation is in progress")
+
+// ErrChrootVerification is returned when starting the pinentry process if
+// WithChroot is combined with WithBinaryAllowlist or WithBinarySHA256, as the
+// binary would be verified outside the new root directory.
+var ErrChrootVerification = errors.New("pinentry: binary verification is not supported with chroot")
+
+// ErrCodeExpired is returned by Client.GetCode when the code expires.
+var ErrCodeExpired = errors.New("pinentry: code expired")
+
+// ErrInvalidTranscript is returned by NewReplayProcess when the transcript is
+// invalid.
+var ErrInvalidTranscript = errors.New("pinentry: invalid transcript")
+
+// ErrInvalidPINRetryLimit is returned by NewClient when WithPINRetryLimit is
+// used with a limit less than one.
+var ErrInvalidPINRetryLimit = errors.New("pinentry: PIN retry limit must be at least 1")
+
+// ErrNoGPGTTY is returned by NewClient when WithGPGTTYStrict is used and
+// GPG_TTY does not name a tty.
+var ErrNoGPGTTY = errors.New("pinentry: GPG_TTY not set")
+
+// ErrNoPinentryProgram is returned by NewClient when
+// WithBinaryNameFromGnuPGAgentConfStrict is used and gpg-agent.conf does not
+// set pinentry-program.
+var ErrNoPinentryProgram = errors.New("pinentry: no pinentry-program")
+
+// ErrPinentryNotFound is matched by errors returned when no pinentry binary
+// could be found. The error is a *PinentryNotFoundError.
+var ErrPinentryNotFound = errors.New("pinentry: pinentry not found")
+
+// ErrPinentryTerminated is matched by errors returned when the pinentry process
+// exits while a response is expected. The error is a *ProcessExitedError.
+var ErrPinentryTerminated = errors.New("pinentry: pinentry terminated")
+
+// ErrWrongPIN should be wrapped by errors returned by attempt functions passed
+// to Client.WithPINRetry when the PIN is wrong.
+var ErrWrongPIN = errors.New("pinentry: wrong PIN")
+
+// Errors returned by Inquiry methods.
+var (
+	ErrInquiryDataTooLong = errors.New("pinentry: inquiry data too long")
+	ErrInquiryFinished    = errors.New("pinentry: inquiry already finished")
+)
+
+var (
+	errUnterminatedEscape = errors.New("unterminated escape")
+	errUnterminatedQuote  = errors.New("unterminated quote")
+)
+
 // combineErrors combines all non-nil errors in errs into one. If there are no
 // non-nil errors, it returns nil. If there is exactly one non-nil error then it
 // returns that error. Otherwise, it returns the non-nil errors combined with
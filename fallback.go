@@ -0,0 +1,91 @@
+package pinentry
+
+import (
+	"errors"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const cursesBinaryName = "pinentry-curses"
+
+// displayErrorRx matches the errors written by GTK, Qt, and other toolkits
+// when they cannot connect to an X11 or Wayland display.
+var displayErrorRx = regexp.MustCompile(`(?i)\b(?:(?:cannot|can't|could not|couldn't|failed to|unable to) (?:open|connect to)\b.*\bdisplay|no display)`)
+
+// WithCursesFallback sets the client to restart with pinentry-curses on the
+// current tty if the pinentry binary exits during the initial handshake
+// because it cannot connect to the display. The tty is GPG_TTY, if set, or the
+// controlling terminal.
+func WithCursesFallback() ClientOption {
+	return func(c *Client) {
+		c.cursesFallback = true
+	}
+}
+
+// fallBackToCurses restarts the pinentry process with pinentry-curses if err,
+// returned by the initial handshake, was caused by the pinentry process
+// failing to connect to the display. Otherwise, it returns err.
+func (c *Client) fallBackToCurses(err error) error {
+	if !c.cursesFallback || !errors.Is(err, ErrPinentryTerminated) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	exiter, ok := c.process.(interface{ ExitStatus() (int, []byte) })
+	if !ok {
+		return err
+	}
+	exitCode, stderr := exiter.ExitStatus()
+	if exitCode == 0 || !displayErrorRx.Match(stderr) {
+		return err
+	}
+	ttyName, ok := cursesTTYName()
+	if !ok {
+		return err
+	}
+	logErrorOrInfo(c.logger, "fallBackToCurses", err, "stderr", stderr, "ttyName", ttyName)
+
+	closeErr := c.process.Close()
+	logErrorOrInfo(c.logger, "close", closeErr)
+
+	c.binaryName = cursesBinaryName
+	c.args = withTTYNameArg(c.args, ttyName)
+	if err := c.process.Start(c.binaryName, c.args); err != nil {
+		c.startError(err)
+		return err
+	}
+	return c.handshake()
+}
+
+// withTTYNameArg returns a copy of args with any --ttyname argument replaced
+// by ttyName.
+func withTTYNameArg(args []string, ttyName string) []string {
+	result := make([]string, 0, len(args)+2)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--ttyname":
+			i++
+		case strings.HasPrefix(args[i], "--ttyname="):
+		default:
+			result = append(result, args[i])
+		}
+	}
+	return append(result, "--ttyname", ttyName)
+}
+
+// cursesTTYName returns the name of the tty for pinentry-curses.
+func cursesTTYName() (string, bool) {
+	if runtime.GOOS == "windows" {
+		return "", false
+	}
+	if gpgTTY := os.Getenv("GPG_TTY"); gpgTTY != "" {
+		return gpgTTY, true
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", false
+	}
+	_ = tty.Close()
+	return "/dev/tty", true
+}
@@ -0,0 +1,36 @@
+package pinentry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDisplayErrorRx(t *testing.T) {
+	for _, s := range []string{
+		"(pinentry-gtk-2:1234): Gtk-WARNING **: cannot open display: :0",
+		"qt.qpa.xcb: could not connect to display",
+		"Failed to connect to Wayland display: No such file or directory",
+		"pinentry-gnome3: no display",
+	} {
+		assert.True(t, displayErrorRx.MatchString(s), s)
+	}
+	for _, s := range []string{
+		"",
+		"pinentry-curses: no LC_CTYPE known - assuming UTF-8",
+	} {
+		assert.False(t, displayErrorRx.MatchString(s), s)
+	}
+}
+
+func TestStderrBuffer(t *testing.T) {
+	var b stderrBuffer
+	n, err := b.Write([]byte(strings.Repeat("a", maxStderrLen-1)))
+	assert.NoError(t, err)
+	assert.Equal(t, maxStderrLen-1, n)
+	n, err = b.Write([]byte("bc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, strings.Repeat("a", maxStderrLen-1)+"b", b.String())
+}
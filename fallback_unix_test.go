@@ -0,0 +1,45 @@
+//go:build unix
+
+package pinentry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestCursesFallback(t *testing.T) {
+	binDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "pinentry-gtk-2"), []byte(""+
+		"#!/bin/sh\n"+
+		"echo 'Gtk-WARNING **: cannot open display: :0' >&2\n"+
+		"exit 1\n",
+	), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(binDir, "pinentry-curses"), []byte(""+
+		"#!/bin/sh\n"+
+		"echo \"OK $*\"\n"+
+		"while read -r line; do\n"+
+		"  echo OK\n"+
+		"  [ \"$line\" = BYE ] && exit 0\n"+
+		"done\n",
+	), 0o700))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("GPG_TTY", "/dev/pts/1")
+
+	c, err := NewClient(
+		WithBinaryName("pinentry-gtk-2"),
+		WithArgs([]string{"--debug", "--ttyname", "/dev/pts/0", "--lc-ctype=C"}),
+		WithCursesFallback(),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, "pinentry-curses", c.binaryName)
+	assert.Equal(t, []string{"--debug", "--lc-ctype=C", "--ttyname", "/dev/pts/1"}, c.args)
+	assert.NoError(t, c.Close())
+
+	_, err = NewClient(
+		WithBinaryName("pinentry-gtk-2"),
+	)
+	assert.Error(t, err)
+}
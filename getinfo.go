@@ -0,0 +1,84 @@
+package pinentry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A TTYInfo is the tty information returned by Client.TTYInfo. Unset values
+// are empty.
+type TTYInfo struct {
+	TTYName string
+	TTYType string
+	Display string
+}
+
+// Flavor returns the flavor of the running pinentry, for example gtk2 or
+// curses. If a graphical pinentry has fallen back to curses then the flavor is
+// followed by a colon and curses, for example gtk2:curses.
+func (c *Client) Flavor() (string, error) {
+	return c.getInfo("flavor")
+}
+
+// PID returns the process ID of the running pinentry.
+func (c *Client) PID() (int, error) {
+	data, err := c.getInfo("pid")
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(data)
+	if err != nil {
+		return 0, fmt.Errorf("pinentry: GETINFO pid: %w", err)
+	}
+	return pid, nil
+}
+
+// TTYInfo returns the tty information of the running pinentry.
+func (c *Client) TTYInfo() (TTYInfo, error) {
+	data, err := c.getInfo("ttyinfo")
+	if err != nil {
+		return TTYInfo{}, err
+	}
+	return parseTTYInfo(data)
+}
+
+// Version returns the version of the running pinentry.
+func (c *Client) Version() (string, error) {
+	return c.getInfo("version")
+}
+
+// getInfo returns the data returned by GETINFO what.
+func (c *Client) getInfo(what string) (string, error) {
+	if err := c.tryLock(); err != nil {
+		return "", err
+	}
+	defer c.mu.Unlock()
+	if err := c.writeLine("GETINFO " + what); err != nil {
+		return "", err
+	}
+	response, err := c.readResponse()
+	if err != nil {
+		return "", err
+	}
+	return string(response.Data), nil
+}
+
+// parseTTYInfo parses the data returned by GETINFO ttyinfo. Later versions of
+// pinentry return extra fields, which are ignored.
+func parseTTYInfo(data string) (TTYInfo, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 3 {
+		return TTYInfo{}, fmt.Errorf("pinentry: GETINFO ttyinfo: %q: invalid data", data)
+	}
+	for i, field := range fields[:3] {
+		if field == "-" {
+			fields[i] = ""
+		}
+	}
+	return TTYInfo{
+		TTYName: fields[0],
+		TTYType: fields[1],
+		Display: fields[2],
+	}, nil
+}
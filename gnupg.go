@@ -1,39 +1,131 @@
 package pinentry
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha1" //nolint:gosec
+	"fmt"
+	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
-var gnuPGAgentConfPINEntryProgramRx = regexp.MustCompile(`(?m)^\s*pinentry-program\s+(\S+)`)
+// zBase32Alphabet is the z-base-32 alphabet used by GnuPG.
+const zBase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
 
-// WithBinaryNameFromGnuPGAgentConf sets the name of the pinentry binary by
-// reading ~/.gnupg/gpg-agent.conf, if it exists.
-func WithBinaryNameFromGnuPGAgentConf() (clientOption ClientOption) {
-	clientOption = func(*Client) {}
+// runUserDir is the directory containing users' runtime directories.
+var runUserDir = "/run/user"
 
-	userHomeDir, err := os.UserHomeDir()
+var gnuPGAgentConfPINEntryProgramRx = regexp.MustCompile(`(?m)^[ \t]*pinentry-program[ \t]+(.*?)[ \t\r]*$`)
+
+// A GnuPGCacheMode is a gpg-agent cache mode, used as the prefix of key
+// information set with SETKEYINFO.
+type GnuPGCacheMode byte
+
+// GnuPG cache modes.
+const (
+	GnuPGCacheModeNormal GnuPGCacheMode = 'n'
+	GnuPGCacheModeSSH    GnuPGCacheMode = 's'
+	GnuPGCacheModeUser   GnuPGCacheMode = 'u'
+)
+
+// GnuPGAgentSockets contains the paths to gpg-agent's sockets.
+type GnuPGAgentSockets struct {
+	Standard string
+	Extra    string
+}
+
+// FindGnuPGAgentSockets returns the paths to gpg-agent's standard and extra
+// sockets. It runs gpgconf --list-dirs and, if gpgconf is not available,
+// falls back to the default locations derived from $GNUPGHOME.
+func FindGnuPGAgentSockets() (GnuPGAgentSockets, error) {
+	if output, err := exec.Command("gpgconf", "--list-dirs").Output(); err == nil {
+		dirs := parseGPGConfListDirs(output)
+		if sockets := (GnuPGAgentSockets{
+			Standard: dirs["agent-socket"],
+			Extra:    dirs["agent-extra-socket"],
+		}); sockets.Standard != "" {
+			return sockets, nil
+		}
+	}
+
+	socketDir, err := gnuPGSocketDir()
 	if err != nil {
-		return
+		return GnuPGAgentSockets{}, err
 	}
+	return GnuPGAgentSockets{
+		Standard: filepath.Join(socketDir, "S.gpg-agent"),
+		Extra:    filepath.Join(socketDir, "S.gpg-agent.extra"),
+	}, nil
+}
 
-	data, err := os.ReadFile(filepath.Join(userHomeDir, ".gnupg", "gpg-agent.conf"))
+// WithBinaryNameFromGnuPGAgentConf sets the name of the pinentry binary by
+// reading ~/.gnupg/gpg-agent.conf, if it exists. The pinentry-program value is
+// split into words using shell-like quoting rules, and any words after the
+// binary name are appended to the arguments.
+func WithBinaryNameFromGnuPGAgentConf() ClientOption {
+	words, err := gnuPGAgentConfPinentryProgram()
 	if err != nil {
-		return
+		return func(*Client) {}
+	}
+	return func(c *Client) {
+		WithBinaryName(words[0])(c)
+		c.args = append(c.args, words[1:]...)
 	}
+}
 
-	match := gnuPGAgentConfPINEntryProgramRx.FindSubmatch(data)
-	if match == nil {
-		return
+// WithBinaryNameFromGnuPGAgentConfStrict sets the name of the pinentry binary
+// like WithBinaryNameFromGnuPGAgentConf, except that NewClient returns an error
+// if ~/.gnupg/gpg-agent.conf cannot be read, does not set pinentry-program, or
+// the pinentry-program is not an executable.
+func WithBinaryNameFromGnuPGAgentConfStrict() ClientOption {
+	words, err := gnuPGAgentConfPinentryProgram()
+	if err == nil {
+		_, err = exec.LookPath(words[0])
 	}
+	return WithClientOptionE(func(c *Client) error {
+		if err != nil {
+			return fmt.Errorf("pinentry: gpg-agent.conf: %w", err)
+		}
+		WithBinaryName(words[0])(c)
+		c.args = append(c.args, words[1:]...)
+		return nil
+	})
+}
 
-	return func(c *Client) {
-		c.binaryName = string(match[1])
+// GnuPGKeyInfo returns the key information for keygrip in cacheMode using
+// gpg-agent's conventions, for example n/0123456789ABCDEF0123456789ABCDEF01234567,
+// so that passphrases cached by the pinentry process are shared with GnuPG.
+func GnuPGKeyInfo(cacheMode GnuPGCacheMode, keygrip string) string {
+	return string(cacheMode) + "/" + strings.ToUpper(keygrip)
+}
+
+// ParseGnuPGKeyInfo parses key information in gpg-agent's format into its
+// cache mode and keygrip.
+func ParseGnuPGKeyInfo(keyInfo string) (GnuPGCacheMode, string, bool) {
+	prefix, keygrip, ok := strings.Cut(keyInfo, "/")
+	if !ok || len(prefix) != 1 || keygrip == "" {
+		return 0, "", false
+	}
+	switch cacheMode := GnuPGCacheMode(prefix[0]); cacheMode {
+	case GnuPGCacheModeNormal, GnuPGCacheModeSSH, GnuPGCacheModeUser:
+		return cacheMode, keygrip, true
+	default:
+		return 0, "", false
 	}
 }
 
+// WithGnuPGKeyInfo sets the key information for keygrip in cacheMode using
+// gpg-agent's conventions.
+func WithGnuPGKeyInfo(cacheMode GnuPGCacheMode, keygrip string) ClientOption {
+	return WithKeyInfo(GnuPGKeyInfo(cacheMode, keygrip))
+}
+
 // WithGPGTTY sets the tty.
 func WithGPGTTY() ClientOption {
 	if runtime.GOOS == "windows" {
@@ -45,3 +137,195 @@ func WithGPGTTY() ClientOption {
 	}
 	return WithCommandf("OPTION %s=%s", OptionTTYName, gpgTTY)
 }
+
+// WithGPGTTYStrict sets the tty like WithGPGTTY, except that NewClient returns
+// an error wrapping ErrNoGPGTTY if GPG_TTY is unset or empty or does not name
+// an existing file.
+func WithGPGTTYStrict() ClientOption {
+	return WithClientOptionE(func(c *Client) error {
+		gpgTTY := os.Getenv("GPG_TTY")
+		if gpgTTY == "" {
+			return ErrNoGPGTTY
+		}
+		if _, err := os.Stat(gpgTTY); err != nil {
+			return fmt.Errorf("%w: %w", ErrNoGPGTTY, err)
+		}
+		WithCommandf("OPTION %s=%s", OptionTTYName, gpgTTY)(c)
+		return nil
+	})
+}
+
+// gnuPGAgentConfPinentryProgram returns the words of the pinentry-program value
+// in ~/.gnupg/gpg-agent.conf.
+func gnuPGAgentConfPinentryProgram() ([]string, error) {
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(userHomeDir, ".gnupg", "gpg-agent.conf"))
+	if err != nil {
+		return nil, err
+	}
+
+	match := gnuPGAgentConfPINEntryProgramRx.FindSubmatch(data)
+	if match == nil {
+		return nil, ErrNoPinentryProgram
+	}
+
+	// gpg-agent uses the value as the path to the binary, so a value that names
+	// an existing file is used as-is, even if it contains spaces.
+	program := string(match[1])
+	if fileInfo, err := os.Stat(program); err == nil && !fileInfo.IsDir() {
+		return []string{program}, nil
+	}
+
+	words, err := splitShellWords(program, runtime.GOOS != "windows")
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 {
+		return nil, ErrNoPinentryProgram
+	}
+	return words, nil
+}
+
+// gnuPGHomeDir returns GnuPG's home directory.
+func gnuPGHomeDir() (string, error) {
+	if gnuPGHome, ok := os.LookupEnv("GNUPGHOME"); ok && gnuPGHome != "" {
+		return gnuPGHome, nil
+	}
+	if runtime.GOOS == "windows" {
+		if appData, ok := os.LookupEnv("APPDATA"); ok && appData != "" {
+			return filepath.Join(appData, "gnupg"), nil
+		}
+	}
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userHomeDir, ".gnupg"), nil
+}
+
+// gnuPGSocketDir returns the directory containing GnuPG's sockets. On Unix
+// systems, if /run/user/$UID exists, GnuPG uses /run/user/$UID/gnupg for the
+// default home directory and a subdirectory named after a hash of the home
+// directory for other home directories.
+func gnuPGSocketDir() (string, error) {
+	homeDir, err := gnuPGHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return homeDir, nil
+	}
+	userRunDir := filepath.Join(runUserDir, strconv.Itoa(os.Getuid()))
+	if fileInfo, err := os.Stat(userRunDir); err != nil || !fileInfo.IsDir() {
+		return homeDir, nil
+	}
+	socketDir := filepath.Join(userRunDir, "gnupg")
+	if homeDir, err = filepath.Abs(homeDir); err != nil {
+		return "", err
+	}
+	if userHomeDir, err := os.UserHomeDir(); err == nil && homeDir == filepath.Join(userHomeDir, ".gnupg") {
+		return socketDir, nil
+	}
+	return filepath.Join(socketDir, gnuPGHomeDirHash(homeDir)), nil
+}
+
+// gnuPGHomeDirHash returns the name of the socket subdirectory that GnuPG uses
+// for the non-default home directory homeDir: d. followed by the z-base-32
+// encoding of the first 120 bits of the SHA-1 hash of homeDir.
+func gnuPGHomeDirHash(homeDir string) string {
+	sum := sha1.Sum([]byte(homeDir))
+	var sb strings.Builder
+	sb.WriteString("d.")
+	var bits uint32
+	n := 0
+	for _, b := range sum[:15] {
+		bits = bits<<8 | uint32(b)
+		n += 8
+		for n >= 5 {
+			n -= 5
+			sb.WriteByte(zBase32Alphabet[bits>>n&0x1f])
+		}
+	}
+	return sb.String()
+}
+
+// splitShellWords splits s into words, honoring single quotes, double quotes,
+// and, if backslashEscapes is true, backslash escapes in the same way as a
+// POSIX shell. If backslashEscapes is false, as on Windows where backslashes
+// separate path components, backslashes are literal.
+func splitShellWords(s string, backslashEscapes bool) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case '\\':
+			if !backslashEscapes {
+				word.WriteByte(c)
+				inWord = true
+				break
+			}
+			i++
+			if i == len(s) {
+				return nil, errUnterminatedEscape
+			}
+			word.WriteByte(s[i])
+			inWord = true
+		case '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end == -1 {
+				return nil, errUnterminatedQuote
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if backslashEscapes && s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\", s[i+1]) != -1 {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errUnterminatedQuote
+			}
+			inWord = true
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// parseGPGConfListDirs parses the output of gpgconf --list-dirs.
+func parseGPGConfListDirs(output []byte) map[string]string {
+	dirs := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := bytes.Cut(scanner.Bytes(), []byte(":"))
+		if !ok {
+			continue
+		}
+		if unescapedValue, err := url.PathUnescape(string(value)); err == nil {
+			dirs[string(key)] = unescapedValue
+		} else {
+			dirs[string(key)] = string(value)
+		}
+	}
+	return dirs
+}
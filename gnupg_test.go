@@ -0,0 +1,221 @@
+package pinentry
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestParseGPGConfListDirs(t *testing.T) {
+	output := []byte("" +
+		"sysconfdir:/etc/gnupg\n" +
+		"homedir:/home/user/.gnupg\n" +
+		"socketdir:/run/user/1000/gnupg\n" +
+		"agent-socket:/run/user/1000/gnupg/S.gpg-agent\n" +
+		"agent-extra-socket:/run/user/1000/gnupg/S.gpg-agent.extra\n" +
+		"dirmngr-socket:C%3a\\Users\\user\\AppData\\Roaming\\gnupg\\S.dirmngr\n" +
+		"malformed\n",
+	)
+	assert.Equal(t, map[string]string{
+		"sysconfdir":         "/etc/gnupg",
+		"homedir":            "/home/user/.gnupg",
+		"socketdir":          "/run/user/1000/gnupg",
+		"agent-socket":       "/run/user/1000/gnupg/S.gpg-agent",
+		"agent-extra-socket": "/run/user/1000/gnupg/S.gpg-agent.extra",
+		"dirmngr-socket":     "C:\\Users\\user\\AppData\\Roaming\\gnupg\\S.dirmngr",
+	}, parseGPGConfListDirs(output))
+}
+
+func TestFindGnuPGAgentSocketsFallback(t *testing.T) {
+	t.Setenv("PATH", "")
+	t.Setenv("GNUPGHOME", "/gnupghome")
+	setRunUserDir(t, filepath.Join(t.TempDir(), "nonexistent"))
+	sockets, err := FindGnuPGAgentSockets()
+	assert.NoError(t, err)
+	assert.Equal(t, GnuPGAgentSockets{
+		Standard: "/gnupghome/S.gpg-agent",
+		Extra:    "/gnupghome/S.gpg-agent.extra",
+	}, sockets)
+}
+
+func TestFindGnuPGAgentSocketsRunUserDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("GnuPG uses the home directory for sockets on Windows")
+	}
+	t.Setenv("PATH", "")
+	dir := t.TempDir()
+	setRunUserDir(t, dir)
+	userRunDir := filepath.Join(dir, strconv.Itoa(os.Getuid()))
+	assert.NoError(t, os.Mkdir(userRunDir, 0o700))
+
+	t.Setenv("GNUPGHOME", "/tmp/gh1")
+	sockets, err := FindGnuPGAgentSockets()
+	assert.NoError(t, err)
+	assert.Equal(t, GnuPGAgentSockets{
+		Standard: filepath.Join(userRunDir, "gnupg", "d.ftacegjuwrto6c98adm9ay63", "S.gpg-agent"),
+		Extra:    filepath.Join(userRunDir, "gnupg", "d.ftacegjuwrto6c98adm9ay63", "S.gpg-agent.extra"),
+	}, sockets)
+
+	userHomeDir, err := os.UserHomeDir()
+	assert.NoError(t, err)
+	t.Setenv("GNUPGHOME", filepath.Join(userHomeDir, ".gnupg"))
+	sockets, err = FindGnuPGAgentSockets()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(userRunDir, "gnupg", "S.gpg-agent"), sockets.Standard)
+}
+
+func TestGnuPGHomeDirHash(t *testing.T) {
+	assert.Equal(t, "d.ftacegjuwrto6c98adm9ay63", gnuPGHomeDirHash("/tmp/gh1"))
+	assert.Equal(t, "d.dj4gmgwqtfik1wc8hw8rnzui", gnuPGHomeDirHash("/tmp/gh2"))
+}
+
+func setRunUserDir(t *testing.T, dir string) {
+	t.Helper()
+	oldRunUserDir := runUserDir
+	runUserDir = dir
+	t.Cleanup(func() {
+		runUserDir = oldRunUserDir
+	})
+}
+
+func TestSplitShellWords(t *testing.T) {
+	for i, tc := range []struct {
+		s                  string
+		noBackslashEscapes bool
+		expected           []string
+		expectedError      error
+	}{
+		{
+			s: "",
+		},
+		{
+			s:        "/usr/bin/pinentry-curses",
+			expected: []string{"/usr/bin/pinentry-curses"},
+		},
+		{
+			s:        "/usr/bin/pinentry-curses --timeout 30",
+			expected: []string{"/usr/bin/pinentry-curses", "--timeout", "30"},
+		},
+		{
+			s:        "  /usr/bin/pinentry \t --debug  ",
+			expected: []string{"/usr/bin/pinentry", "--debug"},
+		},
+		{
+			s:        `"/Applications/My Pinentry.app/pinentry" --arg 'single quoted' "double \"quoted\""`,
+			expected: []string{"/Applications/My Pinentry.app/pinentry", "--arg", "single quoted", `double "quoted"`},
+		},
+		{
+			s:        `C:\\Program\ Files\\pinentry.exe ""`,
+			expected: []string{`C:\Program Files\pinentry.exe`, ""},
+		},
+		{
+			s:                  `"C:\Program Files (x86)\GnuPG\bin\pinentry-basic.exe" --debug`,
+			noBackslashEscapes: true,
+			expected:           []string{`C:\Program Files (x86)\GnuPG\bin\pinentry-basic.exe`, "--debug"},
+		},
+		{
+			s:                  `C:\GnuPG\bin\pinentry-basic.exe "a\"`,
+			noBackslashEscapes: true,
+			expected:           []string{`C:\GnuPG\bin\pinentry-basic.exe`, `a\`},
+		},
+		{
+			s:        `a"b"'c'd`,
+			expected: []string{"abcd"},
+		},
+		{
+			s:             `"unterminated`,
+			expectedError: errUnterminatedQuote,
+		},
+		{
+			s:             `'unterminated`,
+			expectedError: errUnterminatedQuote,
+		},
+		{
+			s:             `unterminated\`,
+			expectedError: errUnterminatedEscape,
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			actual, err := splitShellWords(tc.s, !tc.noBackslashEscapes)
+			assert.Equal(t, tc.expectedError, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestWithBinaryNameFromGnuPGAgentConf(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".gnupg"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(homeDir, ".gnupg", "gpg-agent.conf"), []byte(""+
+		"default-cache-ttl 600\n"+
+		"pinentry-program /usr/bin/pinentry-curses --timeout 30\n",
+	), 0o600))
+
+	c := &Client{}
+	WithBinaryNameFromGnuPGAgentConf()(c)
+	assert.Equal(t, "/usr/bin/pinentry-curses", c.binaryName)
+	assert.Equal(t, []string{"--timeout", "30"}, c.args)
+
+	binaryName := filepath.Join(homeDir, "My Pinentry", "pinentry")
+	assert.NoError(t, os.Mkdir(filepath.Dir(binaryName), 0o700))
+	assert.NoError(t, os.WriteFile(binaryName, nil, 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(homeDir, ".gnupg", "gpg-agent.conf"), []byte(""+
+		"pinentry-program "+binaryName+"\n",
+	), 0o600))
+
+	c = &Client{}
+	WithBinaryNameFromGnuPGAgentConf()(c)
+	assert.Equal(t, binaryName, c.binaryName)
+	assert.Equal(t, nil, c.args)
+}
+
+func TestWithBinaryNameFromGnuPGAgentConfStrict(t *testing.T) {
+	homeDir := t.TempDir()
+	t.Setenv("HOME", homeDir)
+	gpgAgentConfPath := filepath.Join(homeDir, ".gnupg", "gpg-agent.conf")
+
+	c := &Client{}
+	WithBinaryNameFromGnuPGAgentConfStrict()(c)
+	assert.IsError(t, c.optionErr, fs.ErrNotExist)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(homeDir, ".gnupg"), 0o700))
+	assert.NoError(t, os.WriteFile(gpgAgentConfPath, []byte("default-cache-ttl 600\n"), 0o600))
+	c = &Client{}
+	WithBinaryNameFromGnuPGAgentConfStrict()(c)
+	assert.IsError(t, c.optionErr, ErrNoPinentryProgram)
+
+	assert.NoError(t, os.WriteFile(gpgAgentConfPath, []byte("pinentry-program "+filepath.Join(homeDir, "missing")+"\n"), 0o600))
+	c = &Client{}
+	WithBinaryNameFromGnuPGAgentConfStrict()(c)
+	assert.Error(t, c.optionErr)
+
+	executable, err := os.Executable()
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(gpgAgentConfPath, []byte("pinentry-program '"+executable+"' --timeout 30\n"), 0o600))
+	c = &Client{}
+	WithBinaryNameFromGnuPGAgentConfStrict()(c)
+	assert.NoError(t, c.optionErr)
+	assert.Equal(t, executable, c.binaryName)
+	assert.Equal(t, []string{"--timeout", "30"}, c.args)
+}
+
+func TestGnuPGKeyInfo(t *testing.T) {
+	keyInfo := GnuPGKeyInfo(GnuPGCacheModeNormal, "0123456789abcdef0123456789abcdef01234567")
+	assert.Equal(t, "n/0123456789ABCDEF0123456789ABCDEF01234567", keyInfo)
+
+	cacheMode, keygrip, ok := ParseGnuPGKeyInfo(keyInfo)
+	assert.True(t, ok)
+	assert.Equal(t, GnuPGCacheModeNormal, cacheMode)
+	assert.Equal(t, "0123456789ABCDEF0123456789ABCDEF01234567", keygrip)
+
+	for _, keyInfo := range []string{"", "n", "n/", "x/0123", "nn/0123"} {
+		_, _, ok := ParseGnuPGKeyInfo(keyInfo)
+		assert.False(t, ok, keyInfo)
+	}
+}
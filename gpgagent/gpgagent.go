@@ -0,0 +1,377 @@
+// Package gpgagent provides a client to gpg-agent, allowing passphrases to be
+// requested through and cached by gpg-agent rather than by spawning pinentry
+// directly.
+//
+// See info gnupg, section Agent Protocol.
+package gpgagent
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/internal/assuan"
+)
+
+// Assuan error codes returned by gpg-agent.
+const (
+	// AssuanErrorCodeNoData is the error code returned by GET_PASSPHRASE with
+	// NoAsk set when the passphrase is not cached.
+	AssuanErrorCodeNoData = 67108922
+	// AssuanErrorCodeNotImplemented is the error code returned by
+	// PRESET_PASSPHRASE when gpg-agent does not support the requested
+	// timeout.
+	AssuanErrorCodeNotImplemented = 67108933
+)
+
+// autoLaunchPollInterval is the interval at which the socket is polled while
+// waiting for an automatically launched gpg-agent.
+const autoLaunchPollInterval = 50 * time.Millisecond
+
+// An UnexpectedResponseError is returned when gpg-agent sends an unexpected
+// response.
+type UnexpectedResponseError struct {
+	Line string
+}
+
+func (e *UnexpectedResponseError) Error() string {
+	return fmt.Sprintf("gpgagent: unexpected response: %q", e.Line)
+}
+
+// A Client is a client to gpg-agent.
+type Client struct {
+	autoLaunchTimeout time.Duration
+	assuanConn        *assuan.Conn
+	conn              io.ReadWriteCloser
+	logger            *slog.Logger
+}
+
+// An Option sets an option on a Client.
+type Option func(*Client)
+
+// launchAgent launches gpg-agent. It is a variable so that it can be
+// overridden in tests.
+var launchAgent = func(ctx context.Context) error {
+	return exec.CommandContext(ctx, "gpgconf", "--launch", "gpg-agent").Run()
+}
+
+// WithAutoLaunch makes Dial start gpg-agent with gpgconf --launch gpg-agent,
+// like gpg-connect-agent does, if its socket is missing or stale, and wait up
+// to timeout for it to accept connections. gpgconf launches the gpg-agent for
+// the current GnuPG home directory, so the socket must be that gpg-agent's.
+func WithAutoLaunch(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.autoLaunchTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger used to log commands and responses. Passphrases
+// are redacted.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// A GetPassphraseRequest is a request for a passphrase. CacheID identifies
+// the passphrase in gpg-agent's cache. Empty texts use gpg-agent's defaults.
+type GetPassphraseRequest struct {
+	CacheID      string
+	ErrorMessage string
+	Prompt       string
+	Description  string
+	// Repeat is the number of times the user must repeat the passphrase.
+	Repeat int
+	// Check enables gpg-agent's passphrase constraints checks.
+	Check bool
+	// NoAsk returns an error with code AssuanErrorCodeNoData instead of
+	// asking the user if the passphrase is not cached.
+	NoAsk      bool
+	QualityBar bool
+}
+
+// A KeyInfo is the status of a key known to gpg-agent, as returned by KEYINFO.
+// Fields that gpg-agent reports as unknown are empty.
+type KeyInfo struct {
+	Keygrip string
+	// Type is D for a key stored on disk, T for a key stored on a smartcard,
+	// or X for a key of unknown type.
+	Type string
+	// SerialNo is the serial number of the smartcard holding the key.
+	SerialNo string
+	IDStr    string
+	// Cached is set if the key's passphrase is in gpg-agent's cache.
+	Cached bool
+	// Protection is P if the key is protected with a passphrase, or C if it
+	// is stored in clear text.
+	Protection string
+}
+
+// Dial connects to gpg-agent's socket at socketPath. If socketPath is empty
+// then the standard socket found by pinentry.FindGnuPGAgentSockets is used.
+func Dial(ctx context.Context, socketPath string, options ...Option) (*Client, error) {
+	var dialClient Client
+	for _, option := range options {
+		option(&dialClient)
+	}
+	if socketPath == "" {
+		sockets, err := pinentry.FindGnuPGAgentSockets()
+		if err != nil {
+			return nil, err
+		}
+		socketPath = sockets.Standard
+	}
+	conn, err := assuan.DialContext(ctx, socketPath)
+	if err != nil && dialClient.autoLaunchTimeout > 0 {
+		conn, err = autoLaunch(ctx, socketPath, dialClient.autoLaunchTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewClient(conn, options...)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewClient returns a new Client that communicates with gpg-agent over conn.
+// It reads gpg-agent's greeting.
+func NewClient(conn io.ReadWriteCloser, options ...Option) (*Client, error) {
+	c := &Client{
+		conn: conn,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	c.assuanConn = assuan.NewConn(conn, conn, assuan.WithLogger(c.logger))
+	if _, err := c.readResponse(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ClearPassphrase removes the passphrase with cacheID from gpg-agent's cache.
+func (c *Client) ClearPassphrase(cacheID string) error {
+	_, err := c.transact("CLEAR_PASSPHRASE " + escapePlus(cacheID))
+	return err
+}
+
+// Close closes the connection to gpg-agent.
+func (c *Client) Close() (err error) {
+	defer func() {
+		if closeErr := c.conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	_, err = c.transact("BYE")
+	return
+}
+
+// GetPassphrase returns the passphrase for request, either from gpg-agent's
+// cache or by asking the user with gpg-agent's pinentry. If the user cancels
+// then the returned error can be tested with pinentry.IsCancelled.
+func (c *Client) GetPassphrase(request GetPassphraseRequest) (string, error) {
+	args := []string{"GET_PASSPHRASE", "--data"}
+	if request.Check {
+		args = append(args, "--check")
+	}
+	if request.NoAsk {
+		args = append(args, "--no-ask")
+	}
+	if request.Repeat > 0 {
+		args = append(args, "--repeat="+strconv.Itoa(request.Repeat))
+	}
+	if request.QualityBar {
+		args = append(args, "--qualitybar")
+	}
+	args = append(args, escapePlus(request.CacheID))
+	for _, text := range []string{request.ErrorMessage, request.Prompt, request.Description} {
+		if text == "" {
+			text = "X"
+		} else {
+			text = escapePlus(text)
+		}
+		args = append(args, text)
+	}
+	response, err := c.transact(strings.Join(args, " "))
+	if err != nil {
+		return "", err
+	}
+	return response.DataString()
+}
+
+// KeyInfoList returns the status of all keys known to gpg-agent, for example
+// to show which keys are currently unlocked.
+func (c *Client) KeyInfoList() ([]KeyInfo, error) {
+	response, err := c.transact("KEYINFO --list")
+	if err != nil {
+		return nil, err
+	}
+	var keyInfos []KeyInfo
+	for _, args := range response.StatusArgs("KEYINFO") {
+		keyInfo, err := parseKeyInfo(args)
+		if err != nil {
+			return nil, err
+		}
+		keyInfos = append(keyInfos, keyInfo)
+	}
+	return keyInfos, nil
+}
+
+// Option sets the option name to value, for example ttyname or display. If
+// value is empty then the option is set without a value.
+func (c *Client) Option(name, value string) error {
+	command := "OPTION " + name
+	if value != "" {
+		command += "=" + assuan.Escape(value)
+	}
+	_, err := c.transact(command)
+	return err
+}
+
+// PresetPassphrase stores passphrase in gpg-agent's cache for the key with
+// keygrip. The passphrase is sent hex encoded. gpg-agent must be configured
+// with allow-preset-passphrase.
+//
+// If timeout is negative then the passphrase is cached until it is cleared or
+// gpg-agent exits, which is all that current versions of gpg-agent support.
+// Otherwise, timeout is sent in whole seconds, rounded up, and gpg-agent
+// versions that do not support it return an error with code
+// AssuanErrorCodeNotImplemented.
+func (c *Client) PresetPassphrase(keygrip, passphrase string, timeout time.Duration) error {
+	timeoutSeconds := -1
+	if timeout >= 0 {
+		timeoutSeconds = int((timeout + time.Second - 1) / time.Second)
+	}
+	command := fmt.Sprintf("PRESET_PASSPHRASE %s %d %s", escapePlus(keygrip), timeoutSeconds, strings.ToUpper(hex.EncodeToString([]byte(passphrase))))
+	_, err := c.transact(command)
+	return err
+}
+
+// UpdateStartupTTY makes gpg-agent show its own pinentry on ttyName with
+// ttyType, or on display, for example after reattaching a tmux session or
+// logging in over SSH. The options are set on this connection before
+// UPDATESTARTUPTTY is sent. Empty values are not set.
+func (c *Client) UpdateStartupTTY(ttyName, ttyType, display string) error {
+	for _, option := range []struct {
+		name  string
+		value string
+	}{
+		{name: "ttyname", value: ttyName},
+		{name: "ttytype", value: ttyType},
+		{name: "display", value: display},
+	} {
+		if option.value == "" {
+			continue
+		}
+		if err := c.Option(option.name, option.value); err != nil {
+			return err
+		}
+	}
+	_, err := c.transact("UPDATESTARTUPTTY")
+	return err
+}
+
+// transact writes command and returns the response. Error responses are
+// returned as *pinentry.AssuanErrors.
+func (c *Client) transact(command string) (*assuan.Response, error) {
+	if err := c.assuanConn.WriteLine(command); err != nil {
+		return nil, err
+	}
+	return c.readResponse()
+}
+
+// readResponse reads a response. Inquiries are answered with no data. Error
+// responses are returned as *pinentry.AssuanErrors.
+func (c *Client) readResponse() (*assuan.Response, error) {
+	response, err := c.assuanConn.ReadResponse(func(string, string) error {
+		return c.assuanConn.WriteLine("END")
+	})
+	var unexpectedLineError *assuan.UnexpectedLineError
+	var assuanError *assuan.Error
+	switch {
+	case errors.As(err, &unexpectedLineError):
+		return nil, &UnexpectedResponseError{Line: unexpectedLineError.Line}
+	case err != nil:
+		return nil, err
+	case errors.As(response.Err, &assuanError):
+		return nil, &pinentry.AssuanError{
+			Code:        assuanError.Code,
+			Description: assuanError.Description,
+		}
+	default:
+		return response, nil
+	}
+}
+
+// parseKeyInfo parses the arguments of an S KEYINFO status line.
+func parseKeyInfo(args string) (KeyInfo, error) {
+	fields := strings.Fields(args)
+	if len(fields) < 6 {
+		return KeyInfo{}, &UnexpectedResponseError{Line: "S KEYINFO " + args}
+	}
+	for i, field := range fields {
+		if field == "-" {
+			fields[i] = ""
+		}
+	}
+	return KeyInfo{
+		Keygrip:    fields[0],
+		Type:       fields[1],
+		SerialNo:   fields[2],
+		IDStr:      fields[3],
+		Cached:     fields[4] == "1",
+		Protection: fields[5],
+	}, nil
+}
+
+// autoLaunch launches gpg-agent and connects to its socket at socketPath,
+// polling until it accepts connections or timeout expires.
+func autoLaunch(ctx context.Context, socketPath string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := launchAgent(ctx); err != nil {
+		return nil, fmt.Errorf("gpgagent: launch: %w", err)
+	}
+	ticker := time.NewTicker(autoLaunchPollInterval)
+	defer ticker.Stop()
+	for {
+		conn, err := assuan.DialContext(ctx, socketPath)
+		if err == nil {
+			return conn, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-ticker.C:
+		}
+	}
+}
+
+// escapePlus escapes s using gpg-agent's percent-plus escaping, where spaces
+// are encoded as plus signs.
+func escapePlus(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ':
+			sb.WriteByte('+')
+		case c == '+' || c == '%' || c < 0x20:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,324 @@
+package gpgagent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-pinentry/v4"
+)
+
+// A scriptedAgent is a fake gpg-agent that responds to each expected command
+// with its scripted response lines.
+type scriptedAgent struct {
+	t       *testing.T
+	conn    net.Conn
+	scanner *bufio.Scanner
+}
+
+func newScriptedAgent(t *testing.T) (*scriptedAgent, net.Conn) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() {
+		_ = serverConn.Close()
+	})
+	return &scriptedAgent{
+		t:       t,
+		conn:    serverConn,
+		scanner: bufio.NewScanner(serverConn),
+	}, clientConn
+}
+
+func (a *scriptedAgent) run(script ...string) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, line := range script {
+			if command, ok := strings.CutPrefix(line, "> "); ok {
+				if !a.scanner.Scan() {
+					a.t.Errorf("expected %q, got EOF", command)
+					return
+				}
+				if a.scanner.Text() != command {
+					a.t.Errorf("expected %q, got %q", command, a.scanner.Text())
+					return
+				}
+				continue
+			}
+			if _, err := a.conn.Write([]byte(line + "\n")); err != nil {
+				a.t.Error(err)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+func TestClient(t *testing.T) {
+	agent, conn := newScriptedAgent(t)
+	done := agent.run(
+		"OK Pleased to meet you, process 1234",
+		"> OPTION ttyname=/dev/pts/1",
+		"OK",
+		"> GET_PASSPHRASE --data --repeat=1 cache+id X Pass+phrase: Enter%0Aa+passphrase",
+		"INQUIRE PINENTRY_LAUNCHED 5678 curses 1.2.1",
+		"> END",
+		"S PROGRESS",
+		"D secret%25",
+		"OK",
+		"> GET_PASSPHRASE --data --no-ask cache+id X X X",
+		"ERR 67108922 No data <GPG Agent>",
+		"> GET_PASSPHRASE --data cache+id X X X",
+		"ERR 83886179 Operation cancelled <Pinentry>",
+		"> PRESET_PASSPHRASE 0123 -1 616263",
+		"OK",
+		"> PRESET_PASSPHRASE 0123 2 0A25FF",
+		"OK",
+		"> PRESET_PASSPHRASE 0123 60 616263",
+		"ERR 67108933 Not implemented <GPG Agent>",
+		"> CLEAR_PASSPHRASE cache%2Bid",
+		"OK",
+		"> BYE",
+		"OK closing connection",
+	)
+
+	c, err := NewClient(conn)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Option("ttyname", "/dev/pts/1"))
+
+	passphrase, err := c.GetPassphrase(GetPassphraseRequest{
+		CacheID:     "cache id",
+		Prompt:      "Pass phrase:",
+		Description: "Enter\na passphrase",
+		Repeat:      1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret%", passphrase)
+
+	_, err = c.GetPassphrase(GetPassphraseRequest{
+		CacheID: "cache id",
+		NoAsk:   true,
+	})
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, AssuanErrorCodeNoData, assuanError.Code)
+
+	_, err = c.GetPassphrase(GetPassphraseRequest{
+		CacheID: "cache id",
+	})
+	assert.True(t, pinentry.IsCancelled(err))
+
+	assert.NoError(t, c.PresetPassphrase("0123", "abc", -1))
+	assert.NoError(t, c.PresetPassphrase("0123", "\n%\xff", 1500*time.Millisecond))
+	err = c.PresetPassphrase("0123", "abc", time.Minute)
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, AssuanErrorCodeNotImplemented, assuanError.Code)
+	assert.NoError(t, c.ClearPassphrase("cache+id"))
+	assert.NoError(t, c.Close())
+	<-done
+}
+
+func TestClientLogger(t *testing.T) {
+	agent, conn := newScriptedAgent(t)
+	done := agent.run(
+		"OK Pleased to meet you, process 1234",
+		"> GET_PASSPHRASE --data cache+id X X X",
+		"D secret",
+		"OK",
+		"> PRESET_PASSPHRASE 0123 -1 736563726574",
+		"OK",
+	)
+
+	var log bytes.Buffer
+	c, err := NewClient(conn, WithLogger(slog.New(slog.NewTextHandler(&log, nil))))
+	assert.NoError(t, err)
+
+	passphrase, err := c.GetPassphrase(GetPassphraseRequest{
+		CacheID: "cache id",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", passphrase)
+	assert.NoError(t, c.PresetPassphrase("0123", "secret", -1))
+	<-done
+
+	assert.Contains(t, log.String(), `line="D [REDACTED]"`)
+	assert.Contains(t, log.String(), `line="PRESET_PASSPHRASE 0123 -1 [REDACTED]"`)
+	assert.NotContains(t, log.String(), "secret")
+	assert.NotContains(t, log.String(), "736563726574")
+}
+
+func TestClientKeyInfoList(t *testing.T) {
+	agent, conn := newScriptedAgent(t)
+	done := agent.run(
+		"OK Pleased to meet you",
+		"> KEYINFO --list",
+		"S KEYINFO DFF29459BE567180647D48740570A73CAF7B21EC D - - 1 P - - -",
+		"S KEYINFO 393B0D9979AD497B0019C09002456D4BE44B0C1F T D2760001240100000006123456780000 OPENPGP.1 - - - - -",
+		"OK",
+		"> KEYINFO --list",
+		"S KEYINFO 393B0D9979AD497B0019C09002456D4BE44B0C1F",
+		"OK",
+		"> BYE",
+		"OK closing connection",
+	)
+
+	c, err := NewClient(conn)
+	assert.NoError(t, err)
+
+	keyInfos, err := c.KeyInfoList()
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyInfo{
+		{
+			Keygrip:    "DFF29459BE567180647D48740570A73CAF7B21EC",
+			Type:       "D",
+			Cached:     true,
+			Protection: "P",
+		},
+		{
+			Keygrip:  "393B0D9979AD497B0019C09002456D4BE44B0C1F",
+			Type:     "T",
+			SerialNo: "D2760001240100000006123456780000",
+			IDStr:    "OPENPGP.1",
+		},
+	}, keyInfos)
+
+	_, err = c.KeyInfoList()
+	assert.Equal(t, error(&UnexpectedResponseError{Line: "S KEYINFO 393B0D9979AD497B0019C09002456D4BE44B0C1F"}), err)
+
+	assert.NoError(t, c.Close())
+	<-done
+}
+
+func TestClientUpdateStartupTTY(t *testing.T) {
+	agent, conn := newScriptedAgent(t)
+	done := agent.run(
+		"OK Pleased to meet you",
+		"> OPTION ttyname=/dev/pts/2",
+		"OK",
+		"> OPTION ttytype=xterm-256color",
+		"OK",
+		"> UPDATESTARTUPTTY",
+		"OK",
+		"> BYE",
+		"OK closing connection",
+	)
+
+	c, err := NewClient(conn)
+	assert.NoError(t, err)
+	assert.NoError(t, c.UpdateStartupTTY("/dev/pts/2", "xterm-256color", ""))
+	assert.NoError(t, c.Close())
+	<-done
+}
+
+func TestClientUnexpectedResponse(t *testing.T) {
+	agent, conn := newScriptedAgent(t)
+	agent.run(
+		"garbage",
+	)
+	_, err := NewClient(conn)
+	assert.Equal(t, error(&UnexpectedResponseError{Line: "garbage"}), err)
+}
+
+func TestDial(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("gpg-agent uses Assuan socket emulation on Windows")
+	}
+	socketPath := filepath.Join(t.TempDir(), "S.gpg-agent")
+	listenFakeAgent(t, socketPath)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, socketPath)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+}
+
+func TestDialAutoLaunch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("gpg-agent uses Assuan socket emulation on Windows")
+	}
+	socketPath := filepath.Join(t.TempDir(), "S.gpg-agent")
+	launches := 0
+	setLaunchAgent(t, func(context.Context) error {
+		launches++
+		go func() {
+			time.Sleep(2 * autoLaunchPollInterval)
+			listenFakeAgent(t, socketPath)
+		}()
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err := Dial(ctx, socketPath)
+	assert.Error(t, err)
+	assert.Equal(t, 0, launches)
+
+	c, err := Dial(ctx, socketPath, WithAutoLaunch(5*time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, launches)
+	assert.NoError(t, c.Close())
+}
+
+func TestDialAutoLaunchTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("gpg-agent uses Assuan socket emulation on Windows")
+	}
+	socketPath := filepath.Join(t.TempDir(), "S.gpg-agent")
+	setLaunchAgent(t, func(context.Context) error {
+		return nil
+	})
+
+	_, err := Dial(context.Background(), socketPath, WithAutoLaunch(2*autoLaunchPollInterval))
+	assert.Error(t, err)
+}
+
+// listenFakeAgent listens on socketPath and serves a single connection,
+// responding OK to every command.
+func listenFakeAgent(t *testing.T, socketPath string) {
+	t.Helper()
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("OK Pleased to meet you\n"))
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			_, _ = conn.Write([]byte("OK\n"))
+		}
+	}()
+}
+
+func setLaunchAgent(t *testing.T, f func(context.Context) error) {
+	t.Helper()
+	oldLaunchAgent := launchAgent
+	launchAgent = f
+	t.Cleanup(func() {
+		launchAgent = oldLaunchAgent
+	})
+}
+
+func TestEscapePlus(t *testing.T) {
+	assert.Equal(t, "a+b%2Bc%25d%0A", escapePlus("a b+c%d\n"))
+}
@@ -0,0 +1,70 @@
+package pinentry
+
+// An InquiryHandler handles an inquiry from the pinentry process. It responds
+// by calling inquiry's Write method any number of times followed by its End
+// method, or by calling its Cancel method. If the handler returns without
+// doing either then the inquiry is canceled.
+type InquiryHandler func(inquiry *Inquiry) error
+
+// An Inquiry is an inquiry from the pinentry process.
+type Inquiry struct {
+	Keyword string
+	Args    string
+
+	client   *Client
+	length   int
+	finished bool
+}
+
+// Cancel cancels the inquiry.
+func (i *Inquiry) Cancel() error {
+	if i.finished {
+		return ErrInquiryFinished
+	}
+	i.finished = true
+	return i.client.writeLine("CAN")
+}
+
+// End ends the inquiry, completing the data written with Write.
+func (i *Inquiry) End() error {
+	if i.finished {
+		return ErrInquiryFinished
+	}
+	i.finished = true
+	return i.client.writeLine("END")
+}
+
+// Write writes data as part of the response to the inquiry. If the total
+// length of the data would exceed the maximum length negotiated with INQUIRE
+// MAXLEN then nothing is written and ErrInquiryDataTooLong is returned.
+func (i *Inquiry) Write(data []byte) (int, error) {
+	if i.finished {
+		return 0, ErrInquiryFinished
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if i.client.maxLen > 0 && i.length+len(data) > i.client.maxLen {
+		return 0, ErrInquiryDataTooLong
+	}
+	if err := i.client.writeDataLines(data); err != nil {
+		return 0, err
+	}
+	i.length += len(data)
+	return len(data), nil
+}
+
+// handleInquiry calls handler to handle the inquiry with keyword and args,
+// canceling the inquiry if handler does not finish it.
+func (c *Client) handleInquiry(handler InquiryHandler, keyword, args string) error {
+	inquiry := &Inquiry{
+		Keyword: keyword,
+		Args:    args,
+		client:  c,
+	}
+	err := handler(inquiry)
+	if !inquiry.finished {
+		err = combineErrors(err, inquiry.Cancel())
+	}
+	return err
+}
@@ -0,0 +1,161 @@
+// Package assuan contains helpers for the Assuan protocol shared by the server
+// and gpgagent packages.
+package assuan
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxLineLen is Assuan's limit on the length of a line, including its line
+// ending.
+const MaxLineLen = 1000
+
+// maxDataLen is the maximum length of escaped data in a single data line,
+// leaving room for the D prefix and the line ending.
+const maxDataLen = MaxLineLen - 10
+
+// hexDigits are the digits used in escape sequences.
+const hexDigits = "0123456789ABCDEF"
+
+// ErrLineTooLong is returned by ReadLine when a line is longer than
+// MaxLineLen.
+var ErrLineTooLong = errors.New("line too long")
+
+// Escape escapes s for sending as data or as a command argument.
+func Escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			sb.WriteString("%0A")
+		case '\r':
+			sb.WriteString("%0D")
+		case '%':
+			sb.WriteString("%25")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// AppendEscape appends src to dst, escaped as by Escape, and returns the
+// extended buffer.
+func AppendEscape(dst, src []byte) []byte {
+	for _, c := range src {
+		if mustEscape(c) {
+			dst = append(dst, '%', hexDigits[c>>4], hexDigits[c&0xf])
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return dst
+}
+
+// Unescape unescapes %XX sequences in s.
+func Unescape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				sb.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// ParseError parses the code and description from the error line line, which
+// must start with ERR.
+func ParseError(line string) (int, string, bool) {
+	fields := strings.SplitN(strings.TrimPrefix(line, "ERR "), " ", 2)
+	code, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, "", false
+	}
+	var description string
+	if len(fields) == 2 {
+		description = fields[1]
+	}
+	return code, description, true
+}
+
+// ErrorLine returns the error line for code and description, escaping
+// description.
+func ErrorLine(code int, description string) string {
+	return "ERR " + strconv.Itoa(code) + " " + Escape(description)
+}
+
+// ReadLine reads a line from reader, including its line ending. If the line is
+// longer than MaxLineLen then the rest of the line is discarded and
+// ErrLineTooLong is returned.
+func ReadLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	length := 0
+	for {
+		fragment, err := reader.ReadSlice('\n')
+		length += len(fragment)
+		if length <= MaxLineLen {
+			line = append(line, fragment...)
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case length > MaxLineLen && err == nil:
+			return "", ErrLineTooLong
+		case length > MaxLineLen:
+			return "", err
+		default:
+			return string(line), err
+		}
+	}
+}
+
+// WriteData writes data to w as one or more data lines, escaping data and
+// splitting it so that no line is longer than MaxLineLen. Escape sequences are
+// never split. Each line is written with a single call to w.Write from a buffer
+// that is zeroed before WriteData returns, so data, which may be a secret, is
+// not copied elsewhere.
+func WriteData(w io.Writer, data []byte) error {
+	buffer := make([]byte, 0, maxDataLen+3)
+	defer func() {
+		buffer = buffer[:cap(buffer)]
+		for i := range buffer {
+			buffer[i] = 0
+		}
+	}()
+	writeLine := func() error {
+		_, err := w.Write(append(buffer, '\n'))
+		buffer = buffer[:2]
+		return err
+	}
+	buffer = append(buffer, 'D', ' ')
+	for i := range data {
+		n := 1
+		if mustEscape(data[i]) {
+			n = 3
+		}
+		if len(buffer)-2+n > maxDataLen {
+			if err := writeLine(); err != nil {
+				return err
+			}
+		}
+		buffer = AppendEscape(buffer, data[i:i+1])
+	}
+	if len(buffer) > 2 {
+		return writeLine()
+	}
+	return nil
+}
+
+// mustEscape returns if c must be escaped.
+func mustEscape(c byte) bool {
+	return c == '\n' || c == '\r' || c == '%'
+}
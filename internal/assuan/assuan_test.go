@@ -0,0 +1,122 @@
+package assuan
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestEscapeUnescape(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"abc",
+		"a\nb\rc%d",
+		"100%",
+	} {
+		assert.Equal(t, s, Unescape(Escape(s)))
+	}
+	assert.Equal(t, "a%0Ab%25", Escape("a\nb%"))
+	assert.Equal(t, []byte("x a%0Ab%25"), AppendEscape([]byte("x "), []byte("a\nb%")))
+	assert.Equal(t, "a b%zz%4", Unescape("a%20b%zz%4"))
+}
+
+func TestParseError(t *testing.T) {
+	code, description, ok := ParseError("ERR 83886179 Operation cancelled <Pinentry>")
+	assert.True(t, ok)
+	assert.Equal(t, 83886179, code)
+	assert.Equal(t, "Operation cancelled <Pinentry>", description)
+
+	code, description, ok = ParseError("ERR 1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "", description)
+
+	_, _, ok = ParseError("ERR x")
+	assert.False(t, ok)
+}
+
+func TestErrorLine(t *testing.T) {
+	assert.Equal(t, "ERR 1 a%0Ab%25 <Pinentry>", ErrorLine(1, "a\nb% <Pinentry>"))
+}
+
+func TestReadLine(t *testing.T) {
+	reader := bufio.NewReaderSize(strings.NewReader(""+
+		strings.Repeat("a", MaxLineLen-1)+"\n"+
+		strings.Repeat("b", MaxLineLen)+"\n"+
+		"c\r\n"+
+		strings.Repeat("d", 5000)+"\n"+
+		"e",
+	), 16)
+
+	line, err := ReadLine(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", MaxLineLen-1)+"\n", line)
+
+	_, err = ReadLine(reader)
+	assert.IsError(t, err, ErrLineTooLong)
+
+	line, err = ReadLine(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "c\r\n", line)
+
+	_, err = ReadLine(reader)
+	assert.IsError(t, err, ErrLineTooLong)
+
+	line, err = ReadLine(reader)
+	assert.IsError(t, err, io.EOF)
+	assert.Equal(t, "e", line)
+}
+
+func TestWriteData(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "short",
+			data:     "a\nb%",
+			expected: []string{"D a%0Ab%25"},
+		},
+		{
+			name: "long",
+			data: strings.Repeat("a", maxDataLen+1),
+			expected: []string{
+				"D " + strings.Repeat("a", maxDataLen),
+				"D a",
+			},
+		},
+		{
+			name: "escape_sequence_at_boundary",
+			data: strings.Repeat("a", maxDataLen-2) + "%b",
+			expected: []string{
+				"D " + strings.Repeat("a", maxDataLen-2),
+				"D %25b",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			assert.NoError(t, WriteData(&sb, []byte(tc.data)))
+			var data strings.Builder
+			var lines []string
+			for _, line := range strings.SplitAfter(sb.String(), "\n") {
+				if line == "" {
+					continue
+				}
+				assert.True(t, len(line) <= MaxLineLen)
+				line = strings.TrimSuffix(line, "\n")
+				lines = append(lines, line)
+				data.WriteString(Unescape(strings.TrimPrefix(line, "D ")))
+			}
+			assert.Equal(t, tc.expected, lines)
+			assert.Equal(t, tc.data, data.String())
+		})
+	}
+}
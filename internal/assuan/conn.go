@@ -0,0 +1,120 @@
+package assuan
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// A Conn is one side of an Assuan connection.
+type Conn struct {
+	command string
+	logger  *slog.Logger
+	reader  *bufio.Reader
+	w       io.Writer
+	writer  *bufio.Writer
+}
+
+// NewConn returns a new Conn that reads from r and writes to w.
+func NewConn(r io.Reader, w io.Writer, options ...ConnOption) *Conn {
+	c := &Conn{
+		reader: bufio.NewReader(r),
+		w:      w,
+		writer: bufio.NewWriter(w),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// ReadLine reads a line and returns it without its line ending. If ctx is done
+// first then ctx's error is returned immediately and the read continues in
+// the background. Lines longer than MaxLineLen are discarded and
+// ErrLineTooLong is returned. At the end of the input, the last line may be
+// returned with io.EOF.
+func (c *Conn) ReadLine(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if ctx.Done() == nil {
+		line, err := c.readLine()
+		c.logLine("read", []byte(line), err)
+		return line, err
+	}
+	type lineErr struct {
+		line string
+		err  error
+	}
+	lineErrCh := make(chan lineErr, 1)
+	go func() {
+		line, err := c.readLine()
+		lineErrCh <- lineErr{line: line, err: err}
+	}()
+	select {
+	case lineErr := <-lineErrCh:
+		c.logLine("read", []byte(lineErr.line), lineErr.err)
+		return lineErr.line, lineErr.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// WriteData writes data as one or more data lines. data is written directly to
+// the underlying writer so that no copies of it remain in buffers.
+func (c *Conn) WriteData(data []byte) error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	err := WriteData(c.w, data)
+	if c.logger != nil {
+		if secretResponseCommands[c.command] {
+			c.log("write", "D "+redacted, err)
+		} else {
+			c.log("write", "D "+string(AppendEscape(nil, data)), err)
+		}
+	}
+	return err
+}
+
+// WriteError writes an error response.
+func (c *Conn) WriteError(code int, description string) error {
+	return c.WriteLine(ErrorLine(code, description))
+}
+
+// WriteLine writes line and flushes it.
+func (c *Conn) WriteLine(line string) error {
+	_, err := c.writer.WriteString(line + "\n")
+	if err == nil {
+		err = c.writer.Flush()
+	}
+	c.logLine("write", []byte(line), err)
+	return err
+}
+
+// WriteSecretLine writes line, which must include its line ending and which
+// contains a secret, directly to the underlying writer so that no copies of it
+// remain in buffers.
+func (c *Conn) WriteSecretLine(line []byte) error {
+	if err := c.writer.Flush(); err != nil {
+		return err
+	}
+	_, err := c.w.Write(line)
+	c.logLine("write", line, err)
+	return err
+}
+
+// ReadResponse reads a response like the package-level ReadResponse.
+func (c *Conn) ReadResponse(inquire InquireFunc) (*Response, error) {
+	return readResponse(func() (string, error) {
+		return c.ReadLine(context.Background())
+	}, inquire)
+}
+
+// readLine reads a line and returns it without its line ending.
+func (c *Conn) readLine() (string, error) {
+	line, err := ReadLine(c.reader)
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,54 @@
+package assuan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+)
+
+// nonceLen is the length of the nonce in a socket file.
+const nonceLen = 16
+
+// ErrInvalidSocketFile is returned by DialContext when a socket file used for
+// Assuan socket emulation is invalid.
+var ErrInvalidSocketFile = errors.New("invalid socket file")
+
+// DialContext connects to the Assuan socket at socketPath. On Windows, where
+// libassuan emulates Unix domain sockets, socketPath is a file containing a
+// TCP port on localhost and a nonce which is sent after connecting. ctx bounds
+// the time taken to connect, so a connect timeout is set with
+// context.WithTimeout, and cancels a pending connect when it is done.
+func DialContext(ctx context.Context, socketPath string) (net.Conn, error) {
+	if runtime.GOOS == "windows" {
+		return dialSocketFile(ctx, socketPath)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", socketPath)
+}
+
+// dialSocketFile connects to the emulated socket described by the socket file
+// at socketPath.
+func dialSocketFile(ctx context.Context, socketPath string) (net.Conn, error) {
+	data, err := os.ReadFile(socketPath)
+	if err != nil {
+		return nil, err
+	}
+	port, nonce, ok := bytes.Cut(data, []byte("\n"))
+	if !ok || len(nonce) != nonceLen {
+		return nil, fmt.Errorf("%s: %w", socketPath, ErrInvalidSocketFile)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort("127.0.0.1", string(bytes.TrimSpace(port))))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(nonce); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,78 @@
+package assuan
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestDialContextUnix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Assuan uses socket emulation on Windows")
+	}
+	socketPath := filepath.Join(t.TempDir(), "S.test")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte("OK\n"))
+		_ = conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := DialContext(ctx, socketPath)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK\n", string(data))
+	assert.NoError(t, conn.Close())
+}
+
+func TestDialContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := DialContext(ctx, filepath.Join(t.TempDir(), "S.test"))
+	assert.Error(t, err)
+}
+
+func TestDialSocketFile(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	nonceCh := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		nonce := make([]byte, nonceLen)
+		_, _ = io.ReadFull(conn, nonce)
+		nonceCh <- string(nonce)
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port //nolint:forcetypeassert
+	socketPath := filepath.Join(t.TempDir(), "S.test")
+	assert.NoError(t, os.WriteFile(socketPath, []byte(strconv.Itoa(port)+"\n0123456789abcdef"), 0o600))
+	conn, err := dialSocketFile(context.Background(), socketPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789abcdef", <-nonceCh)
+	assert.NoError(t, conn.Close())
+
+	assert.NoError(t, os.WriteFile(socketPath, []byte(strconv.Itoa(port)+"\nshort"), 0o600))
+	_, err = dialSocketFile(context.Background(), socketPath)
+	assert.IsError(t, err, ErrInvalidSocketFile)
+}
@@ -0,0 +1,105 @@
+package assuan
+
+import (
+	"bytes"
+	"log/slog"
+)
+
+// redacted replaces secrets in logged lines.
+const redacted = "[REDACTED]"
+
+// secretArgCommands maps commands whose arguments contain secrets to the
+// number of leading arguments that are not secret.
+var secretArgCommands = map[string]int{
+	"PRESET_PASSPHRASE": 2,
+}
+
+// secretInquiries maps inquiries whose arguments contain secrets to the
+// number of leading arguments that are not secret.
+var secretInquiries = map[string]int{
+	"QUALITY": 0,
+}
+
+// secretResponseCommands contains the commands whose data and OK responses
+// contain secrets.
+var secretResponseCommands = map[string]bool{
+	"GET_PASSPHRASE": true,
+	"GETPIN":         true,
+}
+
+// responseKeywords contains the keywords of lines that are not commands.
+var responseKeywords = map[string]bool{
+	"":        true,
+	"CAN":     true,
+	"D":       true,
+	"END":     true,
+	"ERR":     true,
+	"INQUIRE": true,
+	"OK":      true,
+	"S":       true,
+}
+
+// A ConnOption sets an option on a Conn.
+type ConnOption func(*Conn)
+
+// WithLogger sets the logger. Every line read or written is logged with
+// secrets redacted: data and OK responses to GETPIN and GET_PASSPHRASE, the
+// passphrase argument of PRESET_PASSPHRASE, and the PIN argument of INQUIRE
+// QUALITY.
+func WithLogger(logger *slog.Logger) ConnOption {
+	return func(c *Conn) {
+		c.logger = logger
+	}
+}
+
+// logLine logs line, which was read or written as described by msg, with its
+// secrets redacted, and tracks the current command.
+func (c *Conn) logLine(msg string, line []byte, err error) {
+	if c.logger == nil {
+		return
+	}
+	keyword, _, _ := bytes.Cut(line, []byte(" "))
+	if !bytes.HasPrefix(keyword, []byte("#")) && !responseKeywords[string(keyword)] {
+		c.command = string(keyword)
+	}
+	c.log(msg, c.redact(line), err)
+}
+
+// log logs line, which must already be redacted.
+func (c *Conn) log(msg, line string, err error) {
+	if err != nil {
+		c.logger.Error(msg, "err", err, "line", line)
+	} else {
+		c.logger.Info(msg, "line", line)
+	}
+}
+
+// redact returns line with its secrets replaced. Only the parts of line that
+// are not secret are copied.
+func (c *Conn) redact(line []byte) string {
+	line = bytes.TrimRight(line, "\r\n")
+	keyword, rest, _ := bytes.Cut(line, []byte(" "))
+	var publicFields int
+	var secret bool
+	switch string(keyword) {
+	case "D", "OK":
+		publicFields, secret = 1, secretResponseCommands[c.command]
+	case "INQUIRE":
+		inquiryKeyword, _, _ := bytes.Cut(rest, []byte(" "))
+		var publicArgs int
+		publicArgs, secret = secretInquiries[string(inquiryKeyword)]
+		publicFields = 2 + publicArgs
+	default:
+		var publicArgs int
+		publicArgs, secret = secretArgCommands[string(keyword)]
+		publicFields = 1 + publicArgs
+	}
+	if !secret {
+		return string(line)
+	}
+	fields := bytes.SplitN(line, []byte(" "), publicFields+1)
+	if len(fields) <= publicFields {
+		return string(line)
+	}
+	return string(bytes.Join(append(fields[:publicFields], []byte(redacted)), []byte(" ")))
+}
@@ -0,0 +1,93 @@
+package assuan
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestConnRedact(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		command  string
+		line     string
+		expected string
+	}{
+		{
+			name:     "command",
+			line:     "SETDESC Enter PIN",
+			expected: "SETDESC Enter PIN",
+		},
+		{
+			name:     "getpin_data",
+			command:  "GETPIN",
+			line:     "D secret",
+			expected: "D [REDACTED]",
+		},
+		{
+			name:     "get_passphrase_ok",
+			command:  "GET_PASSPHRASE",
+			line:     "OK 736563726574",
+			expected: "OK [REDACTED]",
+		},
+		{
+			name:     "get_passphrase_ok_empty",
+			command:  "GET_PASSPHRASE",
+			line:     "OK",
+			expected: "OK",
+		},
+		{
+			name:     "getinfo_data",
+			command:  "GETINFO",
+			line:     "D 1.0.0",
+			expected: "D 1.0.0",
+		},
+		{
+			name:     "preset_passphrase",
+			line:     "PRESET_PASSPHRASE 0123456789ABCDEF -1 736563726574",
+			expected: "PRESET_PASSPHRASE 0123456789ABCDEF -1 [REDACTED]",
+		},
+		{
+			name:     "inquire_quality",
+			line:     "INQUIRE QUALITY secret",
+			expected: "INQUIRE QUALITY [REDACTED]",
+		},
+		{
+			name:     "inquire_other",
+			line:     "INQUIRE NEEDPIN Enter",
+			expected: "INQUIRE NEEDPIN Enter",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Conn{command: tc.command}
+			assert.Equal(t, tc.expected, c.redact([]byte(tc.line)))
+		})
+	}
+}
+
+func TestConnLogger(t *testing.T) {
+	var log bytes.Buffer
+	var output bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, nil))
+	c := NewConn(strings.NewReader("GETPIN\nGETINFO version\n"), &output, WithLogger(logger))
+
+	line, err := c.ReadLine(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "GETPIN", line)
+	assert.NoError(t, c.WriteSecretLine([]byte("INQUIRE QUALITY secret\n")))
+	assert.NoError(t, c.WriteData([]byte("secret")))
+	assert.NoError(t, c.WriteLine("OK"))
+	_, err = c.ReadLine(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, c.WriteData([]byte("1.0.0")))
+
+	assert.Equal(t, "INQUIRE QUALITY secret\nD secret\nOK\nD 1.0.0\n", output.String())
+	assert.NotContains(t, log.String(), "secret")
+	assert.Contains(t, log.String(), `line="INQUIRE QUALITY [REDACTED]"`)
+	assert.Contains(t, log.String(), `line="D [REDACTED]"`)
+	assert.Contains(t, log.String(), `line="D 1.0.0"`)
+}
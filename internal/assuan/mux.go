@@ -0,0 +1,90 @@
+package assuan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Assuan error codes generated by ServeMux.
+const (
+	ErrorCodeLineTooLong    = 536871175
+	ErrorCodeUnknownCommand = 536871187
+)
+
+// A HandlerFunc handles a command with the unescaped arguments args. It may
+// write data and status lines before returning. If it returns nil then OK is
+// sent, otherwise an error response is sent.
+type HandlerFunc func(args string) error
+
+// A ServeMux serves commands by dispatching them to the handlers registered
+// for their keywords.
+type ServeMux struct {
+	handlers      map[string]HandlerFunc
+	errorResponse func(error) *Error
+}
+
+// NewServeMux returns a new ServeMux. errorResponse returns the error response
+// for an error returned by a handler that is not an *Error.
+func NewServeMux(errorResponse func(error) *Error) *ServeMux {
+	return &ServeMux{
+		handlers:      make(map[string]HandlerFunc),
+		errorResponse: errorResponse,
+	}
+}
+
+// Handle registers handler for the command with keyword, which is matched
+// case-insensitively.
+func (m *ServeMux) Handle(keyword string, handler HandlerFunc) {
+	m.handlers[strings.ToUpper(keyword)] = handler
+}
+
+// Serve writes greeting and then serves commands from conn until the client
+// sends BYE, conn reaches the end of its input, or ctx is done. Comments and
+// empty lines are ignored, and overlong lines and unknown commands are
+// answered with error responses. The returned error is nil if the client sends
+// BYE or closes the connection, or the error from reading or writing conn
+// otherwise.
+func (m *ServeMux) Serve(ctx context.Context, conn *Conn, greeting string) error {
+	if err := conn.WriteLine("OK " + greeting); err != nil {
+		return err
+	}
+	for {
+		line, err := conn.ReadLine(ctx)
+		switch {
+		case errors.Is(err, ErrLineTooLong):
+			if err := conn.WriteError(ErrorCodeLineTooLong, "Line too long"); err != nil {
+				return err
+			}
+			continue
+		case errors.Is(err, io.EOF) && line == "":
+			return nil
+		case err != nil && !errors.Is(err, io.EOF):
+			return err
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keyword, args, _ := strings.Cut(line, " ")
+		keyword = strings.ToUpper(keyword)
+		if keyword == "BYE" {
+			return conn.WriteLine("OK closing connection")
+		}
+		handler, ok := m.handlers[keyword]
+		if !ok {
+			err = conn.WriteError(ErrorCodeUnknownCommand, "Unknown IPC command")
+		} else if handlerErr := handler(Unescape(args)); handlerErr != nil {
+			var errorResponse *Error
+			if !errors.As(handlerErr, &errorResponse) {
+				errorResponse = m.errorResponse(handlerErr)
+			}
+			err = conn.WriteError(errorResponse.Code, errorResponse.Description)
+		} else {
+			err = conn.WriteLine("OK")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package assuan
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestServeMux(t *testing.T) {
+	var calls []string
+	mux := NewServeMux(func(err error) *Error {
+		return &Error{Code: 1, Description: err.Error()}
+	})
+	mux.Handle("echo", func(args string) error {
+		calls = append(calls, "ECHO "+args)
+		return nil
+	})
+	mux.Handle("FAIL", func(args string) error {
+		return errors.New("failed\nbadly")
+	})
+	mux.Handle("PARAM", func(args string) error {
+		return &Error{Code: 2, Description: "parameter"}
+	})
+
+	var output strings.Builder
+	conn := NewConn(strings.NewReader(""+
+		"# comment\n"+
+		"\n"+
+		"ECHO a%25b%0Ac\n"+
+		"echo\r\n"+
+		"UNKNOWN\n"+
+		"FAIL\n"+
+		"PARAM\n"+
+		"ECHO "+strings.Repeat("a", MaxLineLen)+"\n"+
+		"BYE\n"+
+		"ECHO unreachable\n",
+	), &output)
+	assert.NoError(t, mux.Serve(context.Background(), conn, "hello"))
+	assert.Equal(t, []string{"ECHO a%b\nc", "ECHO "}, calls)
+	assert.Equal(t, ""+
+		"OK hello\n"+
+		"OK\n"+
+		"OK\n"+
+		"ERR "+strconv.Itoa(ErrorCodeUnknownCommand)+" Unknown IPC command\n"+
+		"ERR 1 failed%0Abadly\n"+
+		"ERR 2 parameter\n"+
+		"ERR "+strconv.Itoa(ErrorCodeLineTooLong)+" Line too long\n"+
+		"OK closing connection\n",
+		output.String())
+}
+
+func TestServeMuxEOF(t *testing.T) {
+	var output strings.Builder
+	mux := NewServeMux(nil)
+	mux.Handle("NOP", func(string) error {
+		return nil
+	})
+	assert.NoError(t, mux.Serve(context.Background(), NewConn(strings.NewReader("NOP"), &output), "hello"))
+	assert.Equal(t, "OK hello\nOK\n", output.String())
+}
+
+func TestConnWriteData(t *testing.T) {
+	var output strings.Builder
+	conn := NewConn(strings.NewReader(""), &output)
+	assert.NoError(t, conn.WriteLine("S STATUS"))
+	assert.NoError(t, conn.WriteData([]byte("a\nb")))
+	assert.NoError(t, conn.WriteSecretLine([]byte("INQUIRE QUALITY x\n")))
+	assert.Equal(t, "S STATUS\nD a%0Ab\nINQUIRE QUALITY x\n", output.String())
+}
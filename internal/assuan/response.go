@@ -0,0 +1,123 @@
+package assuan
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An Error is an error response.
+type Error struct {
+	Code        int
+	Description string
+}
+
+func (e *Error) Error() string {
+	return strconv.Itoa(e.Code) + " " + e.Description
+}
+
+// An UnexpectedLineError is returned by ReadResponse when it reads a line that
+// is not valid in a response.
+type UnexpectedLineError struct {
+	Line string
+}
+
+func (e *UnexpectedLineError) Error() string {
+	return fmt.Sprintf("unexpected line: %q", e.Line)
+}
+
+// A Response is a response to a command. Data is the concatenated, unescaped
+// data from all data lines, Status contains the status lines without their S
+// prefix, and OK is the text after OK. If the response is an error then Err
+// is an *Error.
+type Response struct {
+	Data   []byte
+	Status []string
+	OK     string
+	Err    error
+}
+
+// An InquireFunc responds to an inquiry with keyword and args, for example by
+// writing data lines followed by END.
+type InquireFunc func(keyword, args string) error
+
+// ReadResponse reads a response from reader. Inquiries are passed to inquire.
+// Comments and empty lines are ignored. The returned error is only non-nil if
+// reading fails or reader returns a line that is not valid in a response; an
+// error response is returned in the Response's Err.
+func ReadResponse(reader *bufio.Reader, inquire InquireFunc) (*Response, error) {
+	return readResponse(func() (string, error) {
+		line, err := ReadLine(reader)
+		return strings.TrimRight(line, "\r\n"), err
+	}, inquire)
+}
+
+// readResponse reads a response using readLine, which returns lines without
+// their line endings.
+func readResponse(readLine func() (string, error), inquire InquireFunc) (*Response, error) {
+	response := &Response{}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case line == "OK":
+			return response, nil
+		case strings.HasPrefix(line, "OK "):
+			response.OK = line[3:]
+			return response, nil
+		case strings.HasPrefix(line, "D "):
+			response.Data = append(response.Data, Unescape(line[2:])...)
+		case strings.HasPrefix(line, "S "):
+			response.Status = append(response.Status, line[2:])
+		case strings.HasPrefix(line, "ERR "):
+			code, description, ok := ParseError(line)
+			if !ok {
+				return nil, &UnexpectedLineError{Line: line}
+			}
+			response.Err = &Error{
+				Code:        code,
+				Description: description,
+			}
+			return response, nil
+		case strings.HasPrefix(line, "INQUIRE "):
+			keyword, args, _ := strings.Cut(line[8:], " ")
+			if err := inquire(keyword, args); err != nil {
+				return nil, err
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			return nil, &UnexpectedLineError{Line: line}
+		}
+	}
+}
+
+// DataBytes returns the response's data, or its error.
+func (r *Response) DataBytes() ([]byte, error) {
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.Data, nil
+}
+
+// DataString returns the response's data as a string, or its error.
+func (r *Response) DataString() (string, error) {
+	if r.Err != nil {
+		return "", r.Err
+	}
+	return string(r.Data), nil
+}
+
+// StatusArgs returns the arguments of the response's status lines with
+// keyword.
+func (r *Response) StatusArgs(keyword string) []string {
+	var statusArgs []string
+	for _, status := range r.Status {
+		if args, ok := strings.CutPrefix(status, keyword); ok && (args == "" || args[0] == ' ') {
+			statusArgs = append(statusArgs, strings.TrimPrefix(args, " "))
+		}
+	}
+	return statusArgs
+}
@@ -0,0 +1,110 @@
+package assuan
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestReadResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		input             string
+		expectedResponse  *Response
+		expectedInquiries []string
+		expectedErr       error
+	}{
+		{
+			name:             "ok",
+			input:            "OK\n",
+			expectedResponse: &Response{},
+		},
+		{
+			name:  "data_and_status",
+			input: "# comment\nS PROGRESS 1\nD a%25\n\nD b\nS KEYINFO x y\nOK done\n",
+			expectedResponse: &Response{
+				Data:   []byte("a%b"),
+				Status: []string{"PROGRESS 1", "KEYINFO x y"},
+				OK:     "done",
+			},
+		},
+		{
+			name:             "inquire",
+			input:            "INQUIRE PINENTRY_LAUNCHED 1234 curses\nINQUIRE QUALITY\nOK\n",
+			expectedResponse: &Response{},
+			expectedInquiries: []string{
+				"PINENTRY_LAUNCHED 1234 curses",
+				"QUALITY ",
+			},
+		},
+		{
+			name:  "error",
+			input: "S PROGRESS\nERR 83886179 Operation cancelled <Pinentry>\n",
+			expectedResponse: &Response{
+				Status: []string{"PROGRESS"},
+				Err: &Error{
+					Code:        83886179,
+					Description: "Operation cancelled <Pinentry>",
+				},
+			},
+		},
+		{
+			name:        "invalid_error",
+			input:       "ERR x\n",
+			expectedErr: &UnexpectedLineError{Line: "ERR x"},
+		},
+		{
+			name:        "unexpected",
+			input:       "garbage\n",
+			expectedErr: &UnexpectedLineError{Line: "garbage"},
+		},
+		{
+			name:        "eof",
+			input:       "D a\n",
+			expectedErr: io.EOF,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var inquiries []string
+			response, err := ReadResponse(bufio.NewReader(strings.NewReader(tc.input)), func(keyword, args string) error {
+				inquiries = append(inquiries, keyword+" "+args)
+				return nil
+			})
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedResponse, response)
+			assert.Equal(t, tc.expectedInquiries, inquiries)
+		})
+	}
+}
+
+func TestReadResponseInquireError(t *testing.T) {
+	inquireErr := errors.New("inquire")
+	_, err := ReadResponse(bufio.NewReader(strings.NewReader("INQUIRE X\nOK\n")), func(string, string) error {
+		return inquireErr
+	})
+	assert.Equal(t, inquireErr, err)
+}
+
+func TestResponseHelpers(t *testing.T) {
+	response := &Response{
+		Data:   []byte("data"),
+		Status: []string{"KEYINFO a", "KEYINFOX b", "KEYINFO", "PROGRESS"},
+	}
+	data, err := response.DataBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("data"), data)
+	s, err := response.DataString()
+	assert.NoError(t, err)
+	assert.Equal(t, "data", s)
+	assert.Equal(t, []string{"a", ""}, response.StatusArgs("KEYINFO"))
+
+	response.Err = &Error{Code: 1, Description: "error"}
+	_, err = response.DataBytes()
+	assert.Equal(t, response.Err, err)
+	_, err = response.DataString()
+	assert.Equal(t, response.Err, err)
+}
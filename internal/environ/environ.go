@@ -0,0 +1,21 @@
+// Package environ detects the kind of session from the environment. It is
+// shared by the pinentry package and cmd/pinentry-go so that both choose
+// between terminal and graphical front ends in the same way.
+package environ
+
+// Graphical returns whether the environment indicates a graphical session.
+func Graphical(getenv func(string) string) bool {
+	return getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != ""
+}
+
+// PreferTerminal returns whether a terminal front end should be preferred,
+// which is the case if there is a tty and the session is remote or is not a
+// local graphical session.
+func PreferTerminal(getenv func(string) string, hasTTY bool) bool {
+	return hasTTY && (Remote(getenv) || !Graphical(getenv) || getenv("XDG_SESSION_TYPE") == "tty")
+}
+
+// Remote returns whether the environment indicates an SSH session.
+func Remote(getenv func(string) string) bool {
+	return getenv("SSH_CONNECTION") != ""
+}
@@ -0,0 +1,57 @@
+package environ
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestPreferTerminal(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		hasTTY   bool
+		expected bool
+	}{
+		{
+			name:     "no_display",
+			hasTTY:   true,
+			expected: true,
+		},
+		{
+			name: "no_tty",
+		},
+		{
+			name: "x11",
+			env: map[string]string{
+				"DISPLAY": ":0",
+			},
+			hasTTY: true,
+		},
+		{
+			name: "wayland_ssh",
+			env: map[string]string{
+				"SSH_CONNECTION":  "1.2.3.4 22 5.6.7.8 22",
+				"WAYLAND_DISPLAY": "wayland-0",
+			},
+			hasTTY:   true,
+			expected: true,
+		},
+		{
+			name: "x11_console",
+			env: map[string]string{
+				"DISPLAY":          ":0",
+				"XDG_SESSION_TYPE": "tty",
+			},
+			hasTTY:   true,
+			expected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tc.env[key]
+			}
+			assert.Equal(t, tc.expected, PreferTerminal(getenv, tc.hasTTY))
+		})
+	}
+}
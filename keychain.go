@@ -0,0 +1,149 @@
+package pinentry
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DefaultKeychainService is the Keychain service used by pinentry-mac to save
+// passphrases.
+const DefaultKeychainService = "GnuPG"
+
+// securityErrSecItemNotFound is the exit code of the security command when an
+// item is not found.
+const securityErrSecItemNotFound = 44
+
+var errSecurity = errors.New("security failed")
+
+// A KeychainCache is a CacheBackend that stores passphrases in the macOS
+// Keychain as generic passwords with account keyInfo, like pinentry-mac's
+// "Save in Keychain". It uses the security command, passing passphrases on
+// its standard input rather than its command line. On other systems, its
+// methods return errors.ErrUnsupported.
+type KeychainCache struct {
+	service     string
+	runSecurity func(stdin []byte, args ...string) ([]byte, []byte, error)
+}
+
+// NewKeychainCache returns a new KeychainCache that stores passphrases with
+// service, for example DefaultKeychainService.
+func NewKeychainCache(service string) *KeychainCache {
+	return &KeychainCache{
+		service:     service,
+		runSecurity: runSecurity,
+	}
+}
+
+// Get implements CacheBackend.Get.
+func (kc *KeychainCache) Get(keyInfo string) (*Secret, bool, error) {
+	// Use -g rather than -w as -w prints passphrases that are not printable
+	// ASCII in hex without marking them as such.
+	_, stderr, err := kc.runSecurity(nil, "find-generic-password", "-s", kc.service, "-a", keyInfo, "-g")
+	defer func() {
+		for i := range stderr {
+			stderr[i] = 0
+		}
+	}()
+	switch {
+	case isSecurityItemNotFound(err):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+	passphrase, err := parseSecurityPassword(stderr)
+	if err != nil {
+		return nil, false, err
+	}
+	return NewSecret(passphrase), true, nil
+}
+
+// Invalidate implements CacheBackend.Invalidate.
+func (kc *KeychainCache) Invalidate(keyInfo string) error {
+	_, _, err := kc.runSecurity(nil, "delete-generic-password", "-s", kc.service, "-a", keyInfo)
+	if isSecurityItemNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Put implements CacheBackend.Put.
+func (kc *KeychainCache) Put(keyInfo string, passphrase []byte) error {
+	// Run security interactively so that the passphrase is not visible on
+	// its command line.
+	prefix := "add-generic-password -U" +
+		" -s " + quoteSecurityArg(kc.service) +
+		" -a " + quoteSecurityArg(keyInfo) +
+		" -X "
+	command := make([]byte, len(prefix)+hex.EncodedLen(len(passphrase))+1)
+	copy(command, prefix)
+	hex.Encode(command[len(prefix):], passphrase)
+	command[len(command)-1] = '\n'
+	defer func() {
+		for i := range command {
+			command[i] = 0
+		}
+	}()
+	_, stderr, err := kc.runSecurity(command, "-i")
+	if err == nil && len(stderr) > 0 {
+		err = fmt.Errorf("pinentry: %w: %s", errSecurity, bytes.TrimSpace(stderr))
+	}
+	return err
+}
+
+// isSecurityItemNotFound returns if err is returned by the security command
+// when an item is not found.
+func isSecurityItemNotFound(err error) bool {
+	var exitCoder interface{ ExitCode() int }
+	return errors.As(err, &exitCoder) && exitCoder.ExitCode() == securityErrSecItemNotFound
+}
+
+// parseSecurityPassword returns a copy of the password printed on standard
+// error by security find-generic-password -g, either as a quoted string or, if
+// it is not printable ASCII, as hex prefixed with 0x.
+func parseSecurityPassword(stderr []byte) ([]byte, error) {
+	for _, line := range bytes.Split(stderr, []byte("\n")) {
+		value, ok := bytes.CutPrefix(line, []byte("password:"))
+		if !ok {
+			continue
+		}
+		value = bytes.TrimLeft(value, " ")
+		switch {
+		case len(value) == 0:
+			return []byte{}, nil
+		case bytes.HasPrefix(value, []byte("0x")):
+			hexValue, _, _ := bytes.Cut(value[2:], []byte(" "))
+			password := make([]byte, hex.DecodedLen(len(hexValue)))
+			if _, err := hex.Decode(password, hexValue); err != nil {
+				return nil, err
+			}
+			return password, nil
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			return append([]byte(nil), value[1:len(value)-1]...), nil
+		}
+	}
+	return nil, fmt.Errorf("pinentry: %w: no password", errSecurity)
+}
+
+// quoteSecurityArg quotes s for the security command's interactive mode.
+func quoteSecurityArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// runSecurity runs the security command with args and stdin and returns its
+// standard output and standard error.
+func runSecurity(stdin []byte, args ...string) ([]byte, []byte, error) {
+	if runtime.GOOS != "darwin" {
+		return nil, nil, errors.ErrUnsupported
+	}
+	cmd := exec.Command("/usr/bin/security", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	return output, stderr.Bytes(), err
+}
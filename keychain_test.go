@@ -0,0 +1,109 @@
+package pinentry
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+type testExitCodeError int
+
+func (e testExitCodeError) Error() string {
+	return "exit status " + strconv.Itoa(int(e))
+}
+
+func (e testExitCodeError) ExitCode() int {
+	return int(e)
+}
+
+func TestKeychainCache(t *testing.T) {
+	items := make(map[string][]byte)
+	var stdins []string
+	var lastStdin []byte
+	kc := &KeychainCache{
+		service: `Gnu"PG`,
+		runSecurity: func(stdin []byte, args ...string) ([]byte, []byte, error) {
+			switch args[0] {
+			case "-i":
+				stdins = append(stdins, string(stdin))
+				lastStdin = stdin
+				_, hexPassword, ok := strings.Cut(strings.TrimSuffix(string(stdin), "\n"), " -X ")
+				assert.True(t, ok)
+				password, err := hex.DecodeString(hexPassword)
+				assert.NoError(t, err)
+				items["n/0123"] = password
+				return nil, nil, nil
+			case "find-generic-password":
+				assert.Equal(t, []string{"find-generic-password", "-s", `Gnu"PG`, "-a", "n/0123", "-g"}, args)
+				item, ok := items["n/0123"]
+				if !ok {
+					return nil, []byte("security: SecKeychainSearchCopyNext: The specified item could not be found in the keychain.\n"), testExitCodeError(securityErrSecItemNotFound)
+				}
+				return []byte("keychain: \"login.keychain-db\"\n"), []byte("password: " + formatSecurityPassword(item) + "\n"), nil
+			case "delete-generic-password":
+				assert.Equal(t, []string{"delete-generic-password", "-s", `Gnu"PG`, "-a", "n/0123"}, args)
+				if _, ok := items["n/0123"]; !ok {
+					return nil, nil, testExitCodeError(securityErrSecItemNotFound)
+				}
+				delete(items, "n/0123")
+				return nil, nil, nil
+			default:
+				t.Fatalf("unexpected args %q", args)
+				return nil, nil, nil
+			}
+		},
+	}
+
+	_, ok, err := kc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, kc.Put("n/0123", []byte("abc")))
+	assert.Equal(t, []string{`add-generic-password -U -s "Gnu\"PG" -a "n/0123" -X 616263` + "\n"}, stdins)
+	assert.Equal(t, make([]byte, len(lastStdin)), lastStdin)
+
+	secret, ok, err := kc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("abc"), secret.Bytes())
+
+	for _, passphrase := range []string{"pässwort", "", `a"b`, "616263"} {
+		assert.NoError(t, kc.Put("n/0123", []byte(passphrase)))
+		secret, ok, err := kc.Get("n/0123")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, passphrase, string(secret.Bytes()))
+	}
+
+	assert.NoError(t, kc.Invalidate("n/0123"))
+	assert.NoError(t, kc.Invalidate("n/0123"))
+	_, ok, err = kc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// formatSecurityPassword formats password like security find-generic-password
+// -g.
+func formatSecurityPassword(password []byte) string {
+	if len(password) == 0 {
+		return ""
+	}
+	for _, b := range password {
+		if b < ' ' || b > '~' {
+			var quoted strings.Builder
+			for _, b := range password {
+				if b < ' ' || b > '~' {
+					fmt.Fprintf(&quoted, "\\%03o", b)
+				} else {
+					quoted.WriteByte(b)
+				}
+			}
+			return "0x" + strings.ToUpper(hex.EncodeToString(password)) + "  \"" + quoted.String() + "\""
+		}
+	}
+	return `"` + string(password) + `"`
+}
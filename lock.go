@@ -0,0 +1,61 @@
+package pinentry
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// promptLockPollInterval is the interval between attempts to acquire the
+// prompt lock while it is held by another process.
+const promptLockPollInterval = 50 * time.Millisecond
+
+// A promptLock is an advisory lock on a file, used to serialize pinentry
+// dialogs across processes.
+type promptLock struct {
+	file *os.File
+}
+
+// acquirePromptLock acquires an exclusive lock on filename, blocking until the
+// lock is available or ctx is done, in which case ctx's error is returned.
+func acquirePromptLock(ctx context.Context, filename string) (*promptLock, error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(promptLockPollInterval)
+	defer ticker.Stop()
+	for {
+		switch locked, err := tryLockFile(file); {
+		case err != nil:
+			return nil, combineErrors(err, file.Close())
+		case locked:
+			return &promptLock{
+				file: file,
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, combineErrors(ctx.Err(), file.Close())
+		case <-ticker.C:
+		}
+	}
+}
+
+// release releases l.
+func (l *promptLock) release() error {
+	return combineErrors(unlockFile(l.file), l.file.Close())
+}
+
+// defaultPromptLockFilename returns the default per-user prompt lock filename.
+func defaultPromptLockFilename() string {
+	if runtimeDir, ok := os.LookupEnv("XDG_RUNTIME_DIR"); ok && runtimeDir != "" {
+		return filepath.Join(runtimeDir, "go-pinentry.lock")
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		return filepath.Join(os.TempDir(), "go-pinentry-"+strconv.Itoa(uid)+".lock")
+	}
+	return filepath.Join(os.TempDir(), "go-pinentry.lock")
+}
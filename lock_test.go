@@ -0,0 +1,43 @@
+package pinentry
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestPromptLock(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "lock")
+
+	lock1, err := acquirePromptLock(context.Background(), filename)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	assert.NoError(t, err)
+
+	acquired := make(chan *promptLock)
+	go func() {
+		lock2, err := acquirePromptLock(context.Background(), filename)
+		assert.NoError(t, err)
+		acquired <- lock2
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while first lock held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = acquirePromptLock(ctx, filename)
+	assert.IsError(t, err, context.DeadlineExceeded)
+
+	assert.NoError(t, lock1.release())
+	lock2 := <-acquired
+	assert.NoError(t, lock2.release())
+}
@@ -0,0 +1,41 @@
+package pinentry
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	errorLockViolation      = syscall.Errno(33)
+	lockfileExclusiveLock   = 0x2
+	lockfileFailImmediately = 0x1
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+func tryLockFile(file *os.File) (bool, error) {
+	var overlapped syscall.Overlapped
+	r1, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	switch {
+	case r1 != 0:
+		return true, nil
+	case err == errorLockViolation: //nolint:errorlint
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func unlockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	r1, _, err := procUnlockFileEx.Call(file.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r1 == 0 {
+		return err
+	}
+	return nil
+}
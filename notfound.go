@@ -0,0 +1,84 @@
+package pinentry
+
+import (
+	"errors"
+	"io/fs"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// A PinentryNotFoundError is returned when no pinentry binary could be found.
+// BinaryNames are the names or paths that were tried and Suggestions are
+// pinentry flavors, with how to install them, for the current platform.
+type PinentryNotFoundError struct {
+	BinaryNames []string
+	Suggestions []string
+	Err         error
+}
+
+func (e *PinentryNotFoundError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("pinentry: pinentry not found (tried ")
+	sb.WriteString(strings.Join(e.BinaryNames, ", "))
+	sb.WriteString(")")
+	if len(e.Suggestions) > 0 {
+		sb.WriteString(", try installing ")
+		sb.WriteString(strings.Join(e.Suggestions, " or "))
+	}
+	return sb.String()
+}
+
+func (e *PinentryNotFoundError) Is(target error) bool {
+	return target == ErrPinentryNotFound
+}
+
+func (e *PinentryNotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// wrapNotFound returns errs, the errors from starting each of binaryNames,
+// combined. If every error indicates that its binary was not found then the
+// combined error is wrapped in a *PinentryNotFoundError.
+func wrapNotFound(binaryNames []string, errs ...error) error {
+	err := combineErrors(errs...)
+	if err == nil {
+		return nil
+	}
+	for _, startErr := range errs {
+		if !isNotFound(startErr) {
+			return err
+		}
+	}
+	return &PinentryNotFoundError{
+		BinaryNames: binaryNames,
+		Suggestions: pinentrySuggestions(runtime.GOOS),
+		Err:         err,
+	}
+}
+
+// isNotFound returns if err indicates that a binary does not exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+// pinentrySuggestions returns the suggested pinentry flavors for goos.
+func pinentrySuggestions(goos string) []string {
+	switch goos {
+	case "darwin":
+		return []string{
+			"pinentry-mac (brew install pinentry-mac)",
+			"pinentry-curses (brew install pinentry)",
+		}
+	case "windows":
+		return []string{
+			"pinentry-w32 (from Gpg4win, https://gpg4win.org)",
+		}
+	default:
+		return []string{
+			"pinentry-gnome3",
+			"pinentry-qt",
+			"pinentry-curses",
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pinentry
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// SendDesktopNotification sends a desktop notification with the given summary
+// and body. It uses notify-send on Linux and BSD systems and osascript on
+// macOS. On other systems it returns errors.ErrUnsupported.
+func SendDesktopNotification(summary, body string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + appleScriptQuote(body) + " with title " + appleScriptQuote(summary)
+		return exec.Command("osascript", "-e", script).Run()
+	case "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+		return exec.Command("notify-send", "--", summary, body).Run()
+	default:
+		return errors.ErrUnsupported
+	}
+}
+
+// WithDesktopNotification sends a desktop notification with the given summary
+// and body if the pinentry process cannot be started or exits without greeting
+// the client, so that the user is not left unaware that a passphrase is being
+// requested. Errors sending the notification are logged.
+func WithDesktopNotification(summary, body string) ClientOption {
+	return func(c *Client) {
+		c.desktopNotificationFunc = func() {
+			err := SendDesktopNotification(summary, body)
+			logErrorOrInfo(c.logger, "SendDesktopNotification", err)
+		}
+	}
+}
+
+// appleScriptQuote returns s as a quoted AppleScript string.
+func appleScriptQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
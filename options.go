@@ -0,0 +1,115 @@
+package pinentry
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An OptionFormat is the format of an option's value.
+type OptionFormat int
+
+// Option formats.
+const (
+	OptionFormatFlag OptionFormat = iota
+	OptionFormatString
+	OptionFormatInteger
+)
+
+// An InvalidOptionError is returned by NewClient when an option set with
+// WithOption or WithOptions is unknown or has a value in the wrong format.
+type InvalidOptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *InvalidOptionError) Error() string {
+	return fmt.Sprintf("pinentry: %s: invalid option: %s", e.Option, e.Reason)
+}
+
+// KnownOptions maps the names of the options known to pinentry to the format
+// of their values.
+var KnownOptions = map[string]OptionFormat{
+	OptionAllowExternalPasswordCache: OptionFormatFlag,
+	OptionAllowPinentryNotify:        OptionFormatFlag,
+	"allow-emacs-prompt":             OptionFormatFlag,
+	OptionConstraintsEnforce:         OptionFormatFlag,
+	OptionConstraintsErrorTitle:      OptionFormatString,
+	OptionConstraintsHintLong:        OptionFormatString,
+	OptionConstraintsHintShort:       OptionFormatString,
+	"debug-wait":                     OptionFormatInteger,
+	OptionDefaultCancel:              OptionFormatString,
+	"default-capshint":               OptionFormatString,
+	"default-cf-visi":                OptionFormatString,
+	OptionDefaultOK:                  OptionFormatString,
+	OptionDefaultPrompt:              OptionFormatString,
+	OptionDefaultPwmngr:              OptionFormatString,
+	"default-tt-hide":                OptionFormatString,
+	"default-tt-visi":                OptionFormatString,
+	OptionDisplay:                    OptionFormatString,
+	OptionFormattedPassphrase:        OptionFormatFlag,
+	OptionFormattedPassphraseHint:    OptionFormatString,
+	"grab":                           OptionFormatFlag,
+	"invisible-char":                 OptionFormatString,
+	OptionLCCType:                    OptionFormatString,
+	"lc-messages":                    OptionFormatString,
+	"no-grab":                        OptionFormatFlag,
+	"owner":                          OptionFormatString,
+	"parent-wid":                     OptionFormatInteger,
+	"putenv":                         OptionFormatString,
+	"touch-file":                     OptionFormatString,
+	OptionTTYName:                    OptionFormatString,
+	OptionTTYType:                    OptionFormatString,
+	"xauthority":                     OptionFormatString,
+}
+
+// OptionNames returns the sorted names of the known options, for example for
+// command line completion.
+func OptionNames() []string {
+	names := make([]string, 0, len(KnownOptions))
+	for name := range KnownOptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidateOption returns an *InvalidOptionError if option, of the form name
+// or name=value, is not a known option or its value is in the wrong format.
+func ValidateOption(option string) error {
+	name, value, hasValue := strings.Cut(option, "=")
+	format, ok := KnownOptions[name]
+	if !ok {
+		return &InvalidOptionError{
+			Option: option,
+			Reason: "unknown option",
+		}
+	}
+	switch {
+	case format == OptionFormatFlag && hasValue:
+		return &InvalidOptionError{
+			Option: option,
+			Reason: "unexpected value",
+		}
+	case format != OptionFormatFlag && !hasValue:
+		return &InvalidOptionError{
+			Option: option,
+			Reason: "missing value",
+		}
+	case format == OptionFormatInteger:
+		if _, err := strconv.Atoi(value); err != nil {
+			return &InvalidOptionError{
+				Option: option,
+				Reason: "value is not an integer",
+			}
+		}
+	}
+	return nil
+}
+
+// WithUncheckedOption sets an option without checking that it is known, for
+// options added by newer or patched pinentry flavors.
+func WithUncheckedOption(option string) ClientOption {
+	return WithCommandf("OPTION %s", escape(option))
+}
@@ -0,0 +1,38 @@
+package pinentry
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestValidateOption(t *testing.T) {
+	for _, tc := range []struct {
+		option         string
+		expectedReason string
+	}{
+		{option: "no-grab"},
+		{option: "default-ok=_OK"},
+		{option: "default-ok="},
+		{option: "parent-wid=123"},
+		{option: "unknown", expectedReason: "unknown option"},
+		{option: "no-grab=1", expectedReason: "unexpected value"},
+		{option: "ttyname", expectedReason: "missing value"},
+		{option: "parent-wid=abc", expectedReason: "value is not an integer"},
+	} {
+		t.Run(tc.option, func(t *testing.T) {
+			err := ValidateOption(tc.option)
+			if tc.expectedReason == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, error(&InvalidOptionError{Option: tc.option, Reason: tc.expectedReason}), err)
+			}
+		})
+	}
+}
+
+func TestOptionNames(t *testing.T) {
+	optionNames := OptionNames()
+	assert.Equal(t, len(KnownOptions), len(optionNames))
+	assert.Equal(t, "allow-emacs-prompt", optionNames[0])
+}
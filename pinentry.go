@@ -6,34 +6,70 @@
 package pinentry
 
 // FIXME add secure logging mode to avoid logging PIN
-// FIXME add GETINFO support
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"os/exec"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 // Options.
 const (
 	OptionAllowExternalPasswordCache = "allow-external-password-cache"
+	OptionAllowPinentryNotify        = "allow-pinentry-notify"
+	OptionConstraintsEnforce         = "constraints-enforce"
+	OptionConstraintsErrorTitle      = "constraints-error-title"
+	OptionConstraintsHintLong        = "constraints-hint-long"
+	OptionConstraintsHintShort       = "constraints-hint-short"
 	OptionDefaultOK                  = "default-ok"
 	OptionDefaultCancel              = "default-cancel"
 	OptionDefaultPrompt              = "default-prompt"
+	OptionDefaultPwmngr              = "default-pwmngr"
+	OptionDisplay                    = "display"
+	OptionFormattedPassphrase        = "formatted-passphrase"
+	OptionFormattedPassphraseHint    = "formatted-passphrase-hint"
 	OptionTTYName                    = "ttyname"
 	OptionTTYType                    = "ttytype"
 	OptionLCCType                    = "lc-ctype"
 )
 
+const (
+	defaultBinaryName = "pinentry"
+
+	// drainTimeout and maxDrainLines bound how long Close reads lines written
+	// by the pinentry process after it acknowledges BYE.
+	drainTimeout  = time.Second
+	maxDrainLines = 100
+
+	// maxDataLineLength is the maximum length of the escaped data in a data
+	// line, keeping the whole line within Assuan's limit of 1000 bytes.
+	maxDataLineLength = 990
+)
+
 // Error codes.
 const (
-	AssuanErrorCodeCancelled = 83886179
+	AssuanErrorCodeCancelled     = 83886179
+	AssuanErrorCodeNotConfirmed  = 83886194
+	AssuanErrorCodePinentryError = 83886166
 )
 
+// keyboardGrabErrorLocation is the error location that pinentry reports with
+// AssuanErrorCodePinentryError when it cannot grab the keyboard.
+const keyboardGrabErrorLocation = "grab"
+
 // An AssuanError is returned when an error is sent over the Assuan protocol.
 type AssuanError struct {
 	Code        int
@@ -44,6 +80,31 @@ func (e *AssuanError) Error() string {
 	return e.Description
 }
 
+// A KeyboardGrabError is returned when pinentry reports that it could not grab
+// the keyboard.
+type KeyboardGrabError struct {
+	Err *AssuanError
+}
+
+func (e *KeyboardGrabError) Error() string {
+	return "pinentry: cannot grab keyboard: " + e.Err.Error()
+}
+
+func (e *KeyboardGrabError) Unwrap() error {
+	return e.Err
+}
+
+// An InvalidDataError is returned when received data contains a NUL byte or a
+// control character and input validation is enabled. The data itself is not
+// included as it may contain a secret.
+type InvalidDataError struct {
+	Char byte
+}
+
+func (e *InvalidDataError) Error() string {
+	return fmt.Sprintf("pinentry: invalid character %#02x in received data", e.Char)
+}
+
 // An UnexpectedResponseError is returned when an unexpected response is
 // received.
 type UnexpectedResponseError struct {
@@ -68,19 +129,169 @@ var errorRx = regexp.MustCompile(`\AERR (\d+) (.*)\z`)
 // indicates whether the quality is valid.
 type QualityFunc func(string) (int, bool)
 
-// A Client is a pinentry client.
+// A QualityEvent describes an evaluation of the quality of a candidate PIN. It
+// never contains the candidate PIN itself. Length is the length of the
+// candidate PIN in characters. Quality is the quality, clamped to between
+// -100 and 100, and OK is whether the quality is valid.
+type QualityEvent struct {
+	Length  int
+	Quality int
+	OK      bool
+}
+
+// A HeartbeatFunc is called periodically while a prompt is pending with the
+// time elapsed since the prompt was shown.
+type HeartbeatFunc func(elapsed time.Duration)
+
+// A Client is a pinentry client. A Client is safe for concurrent use, but only
+// one operation is performed at a time: an operation requested while another
+// is in progress returns ErrBusy.
 type Client struct {
-	binaryName  string
-	args        []string
-	commands    []string
-	process     Process
-	qualityFunc QualityFunc
-	logger      *slog.Logger
+	binaryName       string
+	binaryNames      []string
+	args             []string
+	env              []string
+	envAllowlist     []string
+	chroot           string
+	sysProcAttr      *syscall.SysProcAttr
+	commands         []string
+	process          Process
+	transcript       io.Writer
+	qualityFunc      QualityFunc
+	qualityEventFunc func(QualityEvent)
+	logger           *slog.Logger
+	timeout          *time.Duration
+	timeoutScale     time.Duration
+	pinentryTimeout  time.Duration
+	confirmTimeout   *time.Duration
+	getPINTimeout    *time.Duration
+	messageTimeout   *time.Duration
+
+	heartbeatInterval time.Duration
+	heartbeatFunc     HeartbeatFunc
+
+	promptLockFilename string
+	promptLock         *promptLock
+
+	startErrorFunc          func(error)
+	desktopNotificationFunc func()
+
+	noGlobalGrabRetry bool
+
+	inputValidation bool
+
+	maxLen int
+
+	unexpectedResponseFunc func(line string)
+
+	inquiryHandlers map[string]InquiryHandler
+
+	sandbox              bool
+	sandboxWritablePaths []string
+
+	resourceLimits []resourceLimit
+
+	binaryAllowlist []string
+	binarySHA256s   []string
+
+	elevatedPrivilegesPolicy ElevatedPrivilegesPolicy
+
+	greetingTimeout time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+
+	keyInfo           string
+	optionKeyInfo     string
+	pinRetryLimit     int
+	wrongPINErrorText string
+
+	buttonLabels [3]string
+
+	optionErr error
+
+	texts map[string]string
+
+	passphraseCache CacheBackend
+
+	constraints *Constraints
+
+	formattedPassphrase     bool
+	formattedPassphraseHint string
+
+	cursesFallback bool
+
+	established bool
+	killed      atomic.Bool
+	cancelled   atomic.Bool
+
+	mu sync.Mutex
+}
+
+// A CursesColor is a pinentry-curses color.
+type CursesColor string
+
+// Curses colors.
+const (
+	CursesColorDefault CursesColor = "default"
+	CursesColorBlack   CursesColor = "black"
+	CursesColorRed     CursesColor = "red"
+	CursesColorGreen   CursesColor = "green"
+	CursesColorYellow  CursesColor = "yellow"
+	CursesColorBlue    CursesColor = "blue"
+	CursesColorMagenta CursesColor = "magenta"
+	CursesColorCyan    CursesColor = "cyan"
+	CursesColorWhite   CursesColor = "white"
+)
+
+// Bright returns the bright variant of c.
+func (c CursesColor) Bright() CursesColor {
+	return "bright-" + c
+}
+
+// CursesColors contains the colors used by pinentry-curses. Empty colors are
+// treated as CursesColorDefault.
+type CursesColors struct {
+	Foreground CursesColor
+	Background CursesColor
+	StandOut   CursesColor
+}
+
+// A TTYAlert is the way pinentry-curses alerts the user when a prompt is
+// shown.
+type TTYAlert string
+
+// TTY alerts.
+const (
+	TTYAlertBeep  TTYAlert = "beep"
+	TTYAlertFlash TTYAlert = "flash"
+	TTYAlertNone  TTYAlert = "none"
+)
+
+// TTYSettings contains the terminal and display settings passed to pinentry.
+type TTYSettings struct {
+	TTYName string
+	TTYType string
+	LCCType string
+	Display string
 }
 
 // A ClientOption sets an option on a Client.
 type ClientOption func(*Client)
 
+// A ClientOptionE sets an option on a Client and returns an error if the
+// option cannot be set.
+type ClientOptionE func(*Client) error
+
+// WithClientOptionE returns a ClientOption that sets option. If option returns
+// an error then NewClient returns it.
+func WithClientOptionE(option ClientOptionE) ClientOption {
+	return func(c *Client) {
+		if err := option(c); err != nil {
+			c.optionErr = combineErrors(c.optionErr, err)
+		}
+	}
+}
+
 // WithArgs appends extra arguments to the pinentry command.
 func WithArgs(args []string) ClientOption {
 	return func(c *Client) {
@@ -88,11 +299,23 @@ func WithArgs(args []string) ClientOption {
 	}
 }
 
-// WithBinaryName sets the name of the pinentry binary name. The default is
-// pinentry.
+// WithBinaryName sets the name of the pinentry binary name, replacing any
+// candidate names set by WithBinaryNames. The default is pinentry.
 func WithBinaryName(binaryName string) ClientOption {
 	return func(c *Client) {
 		c.binaryName = binaryName
+		c.binaryNames = nil
+	}
+}
+
+// WithBinaryNames sets the candidate names of the pinentry binary. Each is
+// tried in order and the first that starts successfully is used.
+func WithBinaryNames(binaryNames []string) ClientOption {
+	return func(c *Client) {
+		c.binaryNames = slices.Clone(binaryNames)
+		if len(binaryNames) > 0 {
+			c.binaryName = binaryNames[0]
+		}
 	}
 }
 
@@ -101,6 +324,20 @@ func WithCancel(cancel string) ClientOption {
 	return WithCommandf("SETCANCEL %s", escape(cancel))
 }
 
+// WithChroot runs the pinentry process with its root directory changed to
+// dir. The pinentry binary, its libraries, and any other files it needs must
+// exist inside dir, and the binary name must be an absolute path inside dir.
+// Changing the root directory usually requires elevated privileges. On systems
+// that do not support it, starting the pinentry process fails with
+// errors.ErrUnsupported. It cannot be combined with WithBinaryAllowlist or
+// WithBinarySHA256, in which case starting the pinentry process fails with
+// ErrChrootVerification.
+func WithChroot(dir string) ClientOption {
+	return func(c *Client) {
+		c.chroot = dir
+	}
+}
+
 // WithCommand appends an Assuan command that is sent when the connection is
 // established.
 func WithCommand(command string) ClientOption {
@@ -116,6 +353,27 @@ func WithCommandf(format string, args ...interface{}) ClientOption {
 	return WithCommand(command)
 }
 
+// WithConfirmTimeout sets the timeout that is applied before each call to
+// Client.Confirm.
+func WithConfirmTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.confirmTimeout = &timeout
+	}
+}
+
+// WithCursesColors sets the colors used by pinentry-curses.
+func WithCursesColors(colors CursesColors) ClientOption {
+	cursesColors := []CursesColor{colors.Foreground, colors.Background, colors.StandOut}
+	colorStrs := make([]string, 0, len(cursesColors))
+	for _, color := range cursesColors {
+		if color == "" {
+			color = CursesColorDefault
+		}
+		colorStrs = append(colorStrs, string(color))
+	}
+	return WithArgs([]string{"--colors", strings.Join(colorStrs, ",")})
+}
+
 // WithDebug tells the pinentry command to print debug messages.
 func WithDebug() ClientOption {
 	return func(c *Client) {
@@ -123,9 +381,37 @@ func WithDebug() ClientOption {
 	}
 }
 
+// WithDefaultCancel sets the label of the cancel button used when it is not
+// set with WithCancel.
+func WithDefaultCancel(cancel string) ClientOption {
+	return WithCommandf("OPTION %s=%s", OptionDefaultCancel, escape(cancel))
+}
+
+// WithDefaultOK sets the label of the OK button used when it is not set with
+// WithOK.
+func WithDefaultOK(ok string) ClientOption {
+	return WithCommandf("OPTION %s=%s", OptionDefaultOK, escape(ok))
+}
+
+// WithDefaultPasswordManager sets the label of the checkbox that saves the
+// passphrase in the password manager.
+func WithDefaultPasswordManager(label string) ClientOption {
+	return WithCommandf("OPTION %s=%s", OptionDefaultPwmngr, escape(label))
+}
+
+// WithDefaultPrompt sets the prompt used when it is not set with WithPrompt.
+func WithDefaultPrompt(prompt string) ClientOption {
+	return WithCommandf("OPTION %s=%s", OptionDefaultPrompt, escape(prompt))
+}
+
 // WithDesc sets the description text.
 func WithDesc(desc string) ClientOption {
-	return WithCommandf("SETDESC %s", escape(desc))
+	return WithCommandf("SETDESC %s", escapeText(desc))
+}
+
+// WithDisplayArg sets the X display with the --display command line argument.
+func WithDisplayArg(display string) ClientOption {
+	return WithArgs([]string{"--display", display})
 }
 
 // WithError sets the error text.
@@ -133,19 +419,118 @@ func WithError(err string) ClientOption {
 	return WithCommandf("SETERROR %s", escape(err))
 }
 
+// WithFormattedPassphrase asks the pinentry to display passphrases in groups
+// of characters, which makes generated passphrases easier to read and
+// transcribe. If hint is not empty then it is shown to explain the grouping.
+// Pinentries that do not support formatted passphrases reject the options,
+// which is ignored.
+func WithFormattedPassphrase(hint string) ClientOption {
+	return func(c *Client) {
+		c.formattedPassphrase = true
+		c.formattedPassphraseHint = hint
+	}
+}
+
 // WithGenPIN sets the label to be used for a generate action.
 func WithGenPIN(genPIN string) ClientOption {
 	return WithCommandf("SETGENPIN %s", escape(genPIN))
 }
 
+// WithGenPINFunc sets the function that is called to generate a passphrase
+// when the user selects the generate action, which is enabled with WithGenPIN.
+// If genPINFunc returns false, or the passphrase is longer than the maximum
+// length negotiated with INQUIRE MAXLEN, then the generate action is cancelled.
+func WithGenPINFunc(genPINFunc func() (string, bool)) ClientOption {
+	return WithInquiryHandler("GENPIN", func(inquiry *Inquiry) error {
+		pin, ok := genPINFunc()
+		if !ok {
+			return inquiry.Cancel()
+		}
+		switch _, err := inquiry.Write([]byte(pin)); {
+		case errors.Is(err, ErrInquiryDataTooLong):
+			return inquiry.Cancel()
+		case err != nil:
+			return err
+		}
+		return inquiry.End()
+	})
+}
+
 // WithGenPINToolTip sets the tooltip to be used for a generate action.
 func WithGenPINToolTip(genPINTT string) ClientOption {
 	return WithCommandf("SETGENPIN_TT %s", escape(genPINTT))
 }
 
+// WithGetPINTimeout sets the timeout that is applied before each call to
+// Client.GetPIN.
+func WithGetPINTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.getPINTimeout = &timeout
+	}
+}
+
+// WithGreetingTimeout sets the maximum time to wait for the pinentry process
+// to greet the client after it is started. Starting graphical pinentry flavors
+// can take much longer than subsequent protocol exchanges. If the timeout
+// expires then the pinentry process is killed and NewClient returns a
+// *TimeoutError. The default is to wait indefinitely.
+func WithGreetingTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.greetingTimeout = timeout
+	}
+}
+
+// WithGlobalGrab instructs pinentry to grab the keyboard globally, for
+// distributions where the default is not to grab.
+func WithGlobalGrab() ClientOption {
+	return func(c *Client) {
+		c.args = append(c.args, "--grab")
+	}
+}
+
+// WithHeartbeat sets a function that is called every interval while a
+// GetPIN, Confirm, or Message call is waiting for the user.
+func WithHeartbeat(interval time.Duration, heartbeatFunc HeartbeatFunc) ClientOption {
+	return func(c *Client) {
+		c.heartbeatInterval = interval
+		c.heartbeatFunc = heartbeatFunc
+	}
+}
+
+// WithInputValidation rejects data, status, and inquiry lines received from
+// pinentry that contain NUL bytes or control characters, after unescaping,
+// with an *InvalidDataError. This protects callers that pass the PIN to C
+// libraries or write it to a terminal.
+func WithInputValidation() ClientOption {
+	return func(c *Client) {
+		c.inputValidation = true
+	}
+}
+
+// WithInquiryHandler sets the handler for inquiries with keyword, overriding
+// any built-in handling of the keyword.
+func WithInquiryHandler(keyword string, handler InquiryHandler) ClientOption {
+	return func(c *Client) {
+		if c.inquiryHandlers == nil {
+			c.inquiryHandlers = make(map[string]InquiryHandler)
+		}
+		c.inquiryHandlers[keyword] = handler
+	}
+}
+
 // WithKeyInfo sets a stable key identifier for use with password caching.
 func WithKeyInfo(keyInfo string) ClientOption {
-	return WithCommandf("SETKEYINFO %s", escape(keyInfo))
+	return func(c *Client) {
+		c.keyInfo = keyInfo
+		c.optionKeyInfo = keyInfo
+		WithCommandf("SETKEYINFO %s", escape(keyInfo))(c)
+	}
+}
+
+// WithLCCTypeArg sets the LC_CTYPE locale with the --lc-ctype command line
+// argument.
+func WithLCCTypeArg(lcCType string) ClientOption {
+	return WithArgs([]string{"--lc-ctype", lcCType})
 }
 
 // WithLogger sets the logger.
@@ -155,6 +540,14 @@ func WithLogger(logger *slog.Logger) ClientOption {
 	}
 }
 
+// WithMessageTimeout sets the timeout that is applied before each call to
+// Client.Message.
+func WithMessageTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.messageTimeout = &timeout
+	}
+}
+
 // WithNoGlobalGrab instructs pinentry to only grab the password when the window
 // is focused.
 func WithNoGlobalGrab() ClientOption {
@@ -163,6 +556,16 @@ func WithNoGlobalGrab() ClientOption {
 	}
 }
 
+// WithNoGlobalGrabRetry instructs the client to restart pinentry with
+// --no-global-grab and retry the GetPIN, Confirm, or Message call if pinentry
+// reports that it could not grab the keyboard, which is common under Wayland
+// and when screen recording tools are running.
+func WithNoGlobalGrabRetry() ClientOption {
+	return func(c *Client) {
+		c.noGlobalGrabRetry = true
+	}
+}
+
 // WithNotOK sets the text of the non-affirmative response button.
 func WithNotOK(notOK string) ClientOption {
 	return WithCommandf("SETNOTOK %s", escape(notOK))
@@ -173,21 +576,38 @@ func WithOK(ok string) ClientOption {
 	return WithCommandf("SETOK %s", escape(ok))
 }
 
-// WithOption sets an option.
+// WithOption sets an option. NewClient returns an *InvalidOptionError if the
+// option is not valid according to ValidateOption. Use WithUncheckedOption to
+// set other options.
 func WithOption(option string) ClientOption {
-	return WithCommandf("OPTION %s", escape(option))
+	return func(c *Client) {
+		c.optionErr = combineErrors(c.optionErr, ValidateOption(option))
+		WithUncheckedOption(option)(c)
+	}
 }
 
-// WithOptions sets multiple options.
+// WithOptions sets multiple options, as WithOption.
 func WithOptions(options []string) ClientOption {
 	return func(c *Client) {
 		for _, option := range options {
-			command := fmt.Sprintf("OPTION %s", escape(option))
-			c.commands = append(c.commands, command)
+			WithOption(option)(c)
 		}
 	}
 }
 
+// WithPinentryNotify asks the pinentry process to notify the client when it
+// has launched, in the same way as it notifies gpg-agent, and calls
+// pinentryLaunchedFunc with the notification.
+func WithPinentryNotify(pinentryLaunchedFunc func(PinentryLaunched)) ClientOption {
+	return func(c *Client) {
+		WithOption(OptionAllowPinentryNotify)(c)
+		WithInquiryHandler("PINENTRY_LAUNCHED", func(inquiry *Inquiry) error {
+			pinentryLaunchedFunc(parsePinentryLaunched(inquiry.Args))
+			return inquiry.End()
+		})(c)
+	}
+}
+
 // WithProcess sets the process.
 func WithProcess(process Process) ClientOption {
 	return func(c *Client) {
@@ -197,7 +617,23 @@ func WithProcess(process Process) ClientOption {
 
 // WithPrompt sets the prompt.
 func WithPrompt(prompt string) ClientOption {
-	return WithCommandf("SETPROMPT %s", escape(prompt))
+	return WithCommandf("SETPROMPT %s", escapeText(prompt))
+}
+
+// WithPromptLock serializes pinentry dialogs across all processes using this
+// library by holding an advisory lock on a per-user file for the lifetime of
+// the Client. NewClient blocks until the lock is acquired, and NewClientContext
+// until the lock is acquired or its context is done.
+func WithPromptLock() ClientOption {
+	return WithPromptLockFile(defaultPromptLockFilename())
+}
+
+// WithPromptLockFile is like WithPromptLock but uses filename as the lock
+// file.
+func WithPromptLockFile(filename string) ClientOption {
+	return func(c *Client) {
+		c.promptLockFilename = filename
+	}
 }
 
 // WithQualityBar enables the quality bar.
@@ -213,6 +649,23 @@ func WithQualityBarToolTip(qualityBarTT string) ClientOption {
 	return WithCommandf("SETQUALITYBAR_TT %s", escape(qualityBarTT))
 }
 
+// WithQualityEventFunc sets a function that is called each time the quality
+// of a candidate PIN is evaluated for the quality bar, so that applications can
+// show the same feedback in their own user interfaces.
+func WithQualityEventFunc(qualityEventFunc func(QualityEvent)) ClientOption {
+	return func(c *Client) {
+		c.qualityEventFunc = qualityEventFunc
+	}
+}
+
+// WithRecording records a transcript of the session with the pinentry process
+// to transcript with a RecordingProcess.
+func WithRecording(transcript io.Writer) ClientOption {
+	return func(c *Client) {
+		c.transcript = transcript
+	}
+}
+
 // WithRepeat sets the repeat passphrase.
 func WithRepeat(repeat string) ClientOption {
 	return WithCommandf("SETREPEAT %s", escape(repeat))
@@ -228,32 +681,152 @@ func WithRepeatOK(repeatOK string) ClientOption {
 	return WithCommandf("SETREPEATOK %s", escape(repeatOK))
 }
 
+// WithSysProcAttr sets the OS-specific attributes of the pinentry process, for
+// example to create a new process group, start a new session with Setsid, or
+// set CREATE_NO_WINDOW in CreationFlags on Windows. A copy of sysProcAttr is
+// used each time the pinentry process is started. Attributes set by other
+// options, for example WithChroot, take precedence. On Linux, Pdeathsig
+// defaults to SIGTERM if it is not set.
+func WithSysProcAttr(sysProcAttr *syscall.SysProcAttr) ClientOption {
+	return func(c *Client) {
+		c.sysProcAttr = sysProcAttr
+	}
+}
+
 // WithTimeout sets the timeout.
 func WithTimeout(timeout time.Duration) ClientOption {
-	return WithCommandf("SETTIMEOUT %d", timeout/time.Second)
+	return func(c *Client) {
+		c.timeout = &timeout
+	}
 }
 
 // WithTitle sets the title.
 func WithTitle(title string) ClientOption {
-	return WithCommandf("SETTITLE %s", escape(title))
+	return WithCommandf("SETTITLE %s", escapeText(title))
+}
+
+// WithStartErrorFunc sets a function that is called if the pinentry process
+// cannot be started or exits without greeting the client, for example because
+// it cannot open the display.
+func WithStartErrorFunc(startErrorFunc func(error)) ClientOption {
+	return func(c *Client) {
+		c.startErrorFunc = startErrorFunc
+	}
+}
+
+// WithTTYAlert sets how pinentry-curses alerts the user when a prompt is
+// shown.
+func WithTTYAlert(ttyAlert TTYAlert) ClientOption {
+	return WithArgs([]string{"--ttyalert", string(ttyAlert)})
+}
+
+// WithTTYNameArg sets the tty with the --ttyname command line argument.
+func WithTTYNameArg(ttyName string) ClientOption {
+	return WithArgs([]string{"--ttyname", ttyName})
+}
+
+// WithTTYSettings sets the non-empty fields of settings both as command line
+// arguments and with OPTION commands, for pinentry flavors that only honor one
+// or the other.
+func WithTTYSettings(settings TTYSettings) ClientOption {
+	return func(c *Client) {
+		for _, setting := range []struct {
+			name  string
+			value string
+		}{
+			{name: OptionTTYName, value: settings.TTYName},
+			{name: OptionTTYType, value: settings.TTYType},
+			{name: OptionLCCType, value: settings.LCCType},
+			{name: OptionDisplay, value: settings.Display},
+		} {
+			if setting.value == "" {
+				continue
+			}
+			c.args = append(c.args, "--"+setting.name, setting.value)
+			c.commands = append(c.commands, fmt.Sprintf("OPTION %s=%s", setting.name, escape(setting.value)))
+		}
+	}
+}
+
+// WithTTYTypeArg sets the terminal type with the --ttytype command line
+// argument.
+func WithTTYTypeArg(ttyType string) ClientOption {
+	return WithArgs([]string{"--ttytype", ttyType})
+}
+
+// WithUnexpectedResponseFunc sets a function that is called with any response
+// line that the client does not expect. The line is then ignored instead of
+// an UnexpectedResponseError being returned, which allows chatty or slightly
+// non-conforming pinentry implementations to be used. Unexpected inquiries are
+// canceled.
+func WithUnexpectedResponseFunc(unexpectedResponseFunc func(line string)) ClientOption {
+	return func(c *Client) {
+		c.unexpectedResponseFunc = unexpectedResponseFunc
+	}
+}
+
+// CheckAvailable checks that the pinentry binary configured by options exists,
+// is executable, starts, and greets the client, and then shuts it down. No
+// dialog is shown, so it can be used by applications to validate their
+// configuration at startup.
+func CheckAvailable(options ...ClientOption) (err error) {
+	c := newClient(options)
+
+	if _, ok := c.execProcess(); ok && c.binaryNames == nil {
+		if _, err = exec.LookPath(c.binaryName); err != nil {
+			err = wrapNotFound([]string{c.binaryName}, err)
+			return
+		}
+	}
+
+	if err = c.start(); err != nil {
+		return
+	}
+	defer combineErrorFunc(&err, c.closeProcess)
+
+	err = c.readOK()
+	return
 }
 
 // NewClient returns a new Client with the given options.
-func NewClient(options ...ClientOption) (c *Client, err error) {
-	c = &Client{
-		binaryName:  "pinentry",
-		process:     &execProcess{},
-		qualityFunc: func(string) (int, bool) { return 0, false },
+func NewClient(options ...ClientOption) (*Client, error) {
+	return NewClientContext(context.Background(), options...)
+}
+
+// NewClientContext returns a new Client with the given options. If ctx is done
+// while waiting for the prompt lock set with WithPromptLock, or before the
+// pinentry process completes its handshake, then the pinentry process, if
+// started, is killed and ctx's error is returned.
+func NewClientContext(ctx context.Context, options ...ClientOption) (c *Client, err error) {
+	if err = ctx.Err(); err != nil {
+		return
 	}
 
-	for _, option := range options {
-		if option != nil {
-			option(c)
+	c = newClient(options)
+
+	if err = c.checkElevatedPrivileges(); err != nil {
+		return
+	}
+
+	if err = ValidateAccelerators(c.buttonLabels[:]...); err != nil {
+		return
+	}
+
+	if c.optionErr != nil {
+		err = c.optionErr
+		return
+	}
+
+	if c.promptLockFilename != "" {
+		if c.promptLock, err = acquirePromptLock(ctx, c.promptLockFilename); err != nil {
+			return
 		}
 	}
 
-	err = c.process.Start(c.binaryName, c.args)
+	err = c.start()
 	if err != nil {
+		c.startError(err)
+		err = combineErrors(err, c.releasePromptLock())
 		return
 	}
 
@@ -263,152 +836,714 @@ func NewClient(options ...ClientOption) (c *Client, err error) {
 		}
 	}()
 
-	var line []byte
-	line, err = c.readLine()
+	_, err = withContext(ctx, c, func() (struct{}, error) {
+		if err := c.handshake(); err != nil {
+			return struct{}{}, c.fallBackToCurses(err)
+		}
+		return struct{}{}, nil
+	})
 	if err != nil {
 		return
 	}
-	if !isOK(line) {
-		err = newUnexpectedResponseError(line)
-		return
+
+	return c, nil
+}
+
+// newClient returns a new Client with the given options, without starting the
+// pinentry process.
+func newClient(options []ClientOption) *Client {
+	c := &Client{
+		binaryName:   defaultBinaryName,
+		process:      &execProcess{},
+		qualityFunc:  func(string) (int, bool) { return 0, false },
+		timeoutScale: 1,
+
+		pinRetryLimit:     defaultPINRetryLimit,
+		wrongPINErrorText: defaultWrongPINErrorText,
 	}
 
-	for _, command := range c.commands {
-		if err = c.command(command); err != nil {
-			return
+	for _, option := range options {
+		if option != nil {
+			option(c)
 		}
 	}
 
-	return c, nil
+	if p, ok := c.process.(*execProcess); ok {
+		p.env = c.env
+		p.envAllowlist = c.envAllowlist
+		p.chroot = c.chroot
+		p.sysProcAttr = c.sysProcAttr
+		p.sandbox = c.sandbox
+		p.sandboxWritablePaths = c.sandboxWritablePaths
+		p.resourceLimits = c.resourceLimits
+		p.binaryAllowlist = c.binaryAllowlist
+		p.binarySHA256s = c.binarySHA256s
+	}
+
+	if c.transcript != nil {
+		c.process = NewRecordingProcess(c.process, c.transcript)
+	}
+
+	return c
 }
 
-// Close closes the connection to the pinentry process.
+// Close closes the connection to the pinentry process. Any lines written by
+// the pinentry process after it acknowledges BYE are read and logged so that
+// they do not interfere with the process exiting. If another operation is in
+// progress then Close waits for it to complete. Use Cancel to abort it.
 func (c *Client) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	defer combineErrorFunc(&err, c.releasePromptLock)
+	return c.closeProcess()
+}
+
+// closeProcess closes the connection to the pinentry process.
+func (c *Client) closeProcess() (err error) {
 	defer combineErrorFunc(&err, c.process.Close)
+	c.established = false
+	if c.killed.Load() {
+		return
+	}
 	if err = c.writeLine("BYE"); err != nil {
 		return
 	}
-	err = c.readOK()
+	if err = c.readOK(); err != nil {
+		return
+	}
+	c.drain()
 	return
 }
 
 // ClearPassphrase clears the cached passphrase associated with the key
 // identified by cacheID.
 func (c *Client) ClearPassphrase(cacheID string) error {
-	command := "CLEARPASSPHRASE " + escape(cacheID)
-	if err := c.writeLine(command); err != nil {
+	if err := c.tryLock(); err != nil {
 		return err
 	}
-	switch line, err := c.readLine(); {
-	case err != nil:
+	defer c.mu.Unlock()
+	if err := c.invalidateCachedPIN(cacheID); err != nil {
+		return err
+	}
+	command := "CLEARPASSPHRASE " + escape(cacheID)
+	if err := c.writeLine(command); err != nil {
 		return err
-	case isOK(line):
-		return nil
-	default:
-		return newUnexpectedResponseError(line)
 	}
+	return c.readOK()
 }
 
 // Confirm asks the user for confirmation.
 func (c *Client) Confirm(option string) (bool, error) {
-	command := "CONFIRM"
-	if option != "" {
-		command += " " + option
-	}
-	if err := c.writeLine(command); err != nil {
-		return false, err
-	}
-	switch line, err := c.readLine(); {
-	case err != nil:
-		return false, err
-	case isOK(line):
-		return true, nil
-	case bytes.Equal(line, []byte("ASSUAN_Not_Confirmed")):
-		return false, nil
-	default:
-		return false, newUnexpectedResponseError(line)
-	}
+	result, err := c.ConfirmWithResult(option)
+	return result.Confirmed, err
 }
 
-// A GetPINResult is the result of a call to Client.GetPIN.
+// ConfirmOneButton shows the user a dialog with a single button, which must be
+// acknowledged but cannot be declined. If the user cancels, an error is
+// returned which can be tested with IsCancelled.
+func (c *Client) ConfirmOneButton() error {
+	_, err := c.Confirm("--one-button")
+	return err
+}
+
+// ConfirmContext asks the user for confirmation. If ctx is done before the
+// user responds then the pinentry process is killed and ctx's error is
+// returned.
+func (c *Client) ConfirmContext(ctx context.Context, option string) (bool, error) {
+	if err := c.tryLock(); err != nil {
+		return false, err
+	}
+	defer c.mu.Unlock()
+	result, err := withContext(ctx, c, func() (ConfirmResult, error) {
+		return c.confirmWithRetry(option)
+	})
+	return result.Confirmed, err
+}
+
+// A ConfirmResult is the result of a call to Client.ConfirmWithResult.
+type ConfirmResult struct {
+	Confirmed bool
+	Duration  time.Duration
+}
+
+// ConfirmWithResult asks the user for confirmation and returns the user's
+// response and how long the user took to respond. If the user cancels, the
+// returned result contains the duration and an error is returned which can be
+// tested with IsCancelled.
+func (c *Client) ConfirmWithResult(option string) (ConfirmResult, error) {
+	if err := c.tryLock(); err != nil {
+		return ConfirmResult{}, err
+	}
+	defer c.mu.Unlock()
+	return c.confirmWithRetry(option)
+}
+
+// confirmWithRetry asks the user for confirmation, retrying without a global
+// keyboard grab if configured.
+func (c *Client) confirmWithRetry(option string) (ConfirmResult, error) {
+	return withNoGlobalGrabRetry(c, func() (ConfirmResult, error) {
+		return c.confirm(option)
+	})
+}
+
+// confirm asks the user for confirmation.
+func (c *Client) confirm(option string) (ConfirmResult, error) {
+	if err := c.setTimeout(c.confirmTimeout); err != nil {
+		return ConfirmResult{}, err
+	}
+	command := "CONFIRM"
+	if option != "" {
+		command += " " + option
+	}
+	if err := c.writeLine(command); err != nil {
+		return ConfirmResult{}, err
+	}
+	defer c.startHeartbeat()()
+	start := time.Now()
+	for {
+		switch line, err := c.readLine(); {
+		case IsCancelled(err):
+			return ConfirmResult{Duration: time.Since(start)}, err
+		case err != nil:
+			return ConfirmResult{}, err
+		case isOK(line):
+			return ConfirmResult{Confirmed: true, Duration: time.Since(start)}, nil
+		case bytes.Equal(line, []byte("ASSUAN_Not_Confirmed")):
+			return ConfirmResult{Duration: time.Since(start)}, nil
+		default:
+			if err := c.unexpectedResponse(line); err != nil {
+				return ConfirmResult{}, err
+			}
+		}
+	}
+}
+
+// ConfirmOptions are per-call options for Client.ConfirmWithOptions. Empty
+// texts are left unchanged.
+type ConfirmOptions struct {
+	Desc      string
+	OK        string
+	NotOK     string
+	Cancel    string
+	OneButton bool
+}
+
+// ConfirmWithOptions asks the user for confirmation with the texts in options,
+// restoring the previous texts afterwards. If the user does not confirm, the
+// returned result is not confirmed and no error is returned. If the user
+// cancels, the returned result contains the duration and an error is returned
+// which can be tested with IsCancelled.
+func (c *Client) ConfirmWithOptions(ctx context.Context, options ConfirmOptions) (result ConfirmResult, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	if err = c.tryLock(); err != nil {
+		return
+	}
+	defer c.mu.Unlock()
+
+	for _, text := range []struct {
+		keyword string
+		value   string
+	}{
+		{keyword: "SETDESC", value: escapeText(options.Desc)},
+		{keyword: "SETOK", value: escape(options.OK)},
+		{keyword: "SETNOTOK", value: escape(options.NotOK)},
+		{keyword: "SETCANCEL", value: escape(options.Cancel)},
+	} {
+		if text.value == "" {
+			continue
+		}
+		previousValue := c.texts[text.keyword]
+		defer func(keyword string) {
+			if c.killed.Load() {
+				return
+			}
+			command := keyword
+			if previousValue != "" {
+				command += " " + previousValue
+			}
+			combineErrorFunc(&err, func() error {
+				return c.command(command)
+			})
+		}(text.keyword)
+		if err = c.command(text.keyword + " " + text.value); err != nil {
+			return
+		}
+	}
+
+	option := ""
+	if options.OneButton {
+		option = "--one-button"
+	}
+	result, err = withContext(ctx, c, func() (ConfirmResult, error) {
+		return c.confirmWithRetry(option)
+	})
+	if isNotConfirmed(err) {
+		err = nil
+	}
+	return
+}
+
+// A GetPINResult is the result of a call to Client.GetPIN. Status maps the
+// keyword of each status line received to its unescaped arguments. If a
+// keyword is received more than once then the last arguments are used.
 type GetPINResult struct {
 	PIN               string
 	PasswordFromCache bool
 	PINRepeated       bool
+	Duration          time.Duration
+	Status            map[string][]string
 }
 
-// GetPIN gets a PIN from the user. If the user cancels, an error is returned
-// which can be tested with IsCancelled.
+// GetPIN gets a PIN from the user. If the user cancels, the returned result
+// contains only the duration and an error is returned which can be tested with
+// IsCancelled.
 func (c *Client) GetPIN() (GetPINResult, error) {
-	if err := c.writeLine("GETPIN"); err != nil {
+	if err := c.tryLock(); err != nil {
+		return GetPINResult{}, err
+	}
+	defer c.mu.Unlock()
+	return c.getPINWithRetry()
+}
+
+// getPINWithRetry gets a PIN from the user, retrying without a global keyboard
+// grab if configured.
+func (c *Client) getPINWithRetry() (GetPINResult, error) {
+	secretResult, err := c.getPINSecret()
+	return secretResult.pinResult(err)
+}
+
+// getPINSecret gets a PIN from the passphrase cache, if any, or from the user,
+// retrying without a global keyboard grab if configured.
+func (c *Client) getPINSecret() (secretResult, error) {
+	if secret, ok := c.cachedPIN(); ok {
+		return secretResult{
+			secret: secret,
+			result: GetPINResult{
+				PasswordFromCache: true,
+			},
+		}, nil
+	}
+	secretResult, err := withNoGlobalGrabRetry(c, c.getPINWithConstraints)
+	if err == nil {
+		c.cachePIN(secretResult.secret.Bytes())
+	}
+	return secretResult, err
+}
+
+// GetPINSecret gets a PIN from the user like GetPIN, except that the PIN is
+// returned as a Secret, which the caller should close when it is no longer
+// needed, and the returned result's PIN is empty. If there is an error then
+// the returned Secret is nil.
+func (c *Client) GetPINSecret() (*Secret, GetPINResult, error) {
+	if err := c.tryLock(); err != nil {
+		return nil, GetPINResult{}, err
+	}
+	defer c.mu.Unlock()
+	secretResult, err := c.getPINSecret()
+	return secretResult.secret, secretResult.result, err
+}
+
+// A secretResult is a Secret and a GetPINResult.
+type secretResult struct {
+	secret *Secret
+	result GetPINResult
+}
+
+// pinResult returns the GetPINResult in r with its PIN set from r's Secret,
+// which is zeroed.
+func (r secretResult) pinResult(err error) (GetPINResult, error) {
+	result := r.result
+	if r.secret != nil {
+		result.PIN = string(r.secret.Bytes())
+		r.secret.Zero()
+	}
+	return result, err
+}
+
+// GetPINContext gets a PIN from the user. If ctx is done before the user
+// responds then the pinentry process is killed and ctx's error is returned.
+func (c *Client) GetPINContext(ctx context.Context) (GetPINResult, error) {
+	if err := c.tryLock(); err != nil {
 		return GetPINResult{}, err
 	}
+	defer c.mu.Unlock()
+	return withContext(ctx, c, c.getPINWithRetry)
+}
+
+// getPIN gets a PIN from the user.
+func (c *Client) getPIN() (secretResult, error) {
+	if err := c.setTimeout(c.getPINTimeout); err != nil {
+		return secretResult{}, err
+	}
+	if err := c.writeLine("GETPIN"); err != nil {
+		return secretResult{}, err
+	}
+	defer c.startHeartbeat()()
+	start := time.Now()
 	var result GetPINResult
+	secret := &Secret{}
+	defer func() {
+		if secret != nil {
+			secret.Zero()
+		}
+	}()
 	for {
 		switch line, err := c.readLine(); {
+		case IsCancelled(err):
+			return secretResult{result: GetPINResult{Duration: time.Since(start)}}, err
 		case err != nil:
-			return GetPINResult{}, err
+			return secretResult{}, err
 		case isOK(line):
-			return result, nil
+			result.Duration = time.Since(start)
+			secretResult := secretResult{secret: secret, result: result}
+			secret = nil
+			return secretResult, nil
 		case isData(line):
-			result.PIN = getPIN(line[2:])
-		case bytes.Equal(line, []byte("S PASSWORD_FROM_CACHE")):
-			result.PasswordFromCache = true
-		case bytes.Equal(line, []byte("S PIN_REPEATED")):
-			result.PINRepeated = true
-		case bytes.HasPrefix(line, []byte("INQUIRE QUALITY ")):
-			pin := getPIN(line[16:])
-			if quality, ok := c.qualityFunc(pin); ok {
-				if quality < -100 {
-					quality = -100
-				} else if quality > 100 {
-					quality = 100
-				}
-				if err := c.writeLine(fmt.Sprintf("D %d", quality)); err != nil {
-					return GetPINResult{}, err
-				}
-				if err := c.writeLine("END"); err != nil {
-					return GetPINResult{}, err
-				}
-			} else {
-				if err := c.writeLine("CAN"); err != nil {
-					return GetPINResult{}, err
-				}
+			secret.Zero()
+			secret.data = unescape(line[2:])
+		case isStatus(line):
+			keyword, args := parseStatus(line)
+			switch keyword {
+			case "PASSWORD_FROM_CACHE":
+				result.PasswordFromCache = true
+			case "PIN_REPEATED":
+				result.PINRepeated = true
+			}
+			if result.Status == nil {
+				result.Status = make(map[string][]string)
+			}
+			result.Status[keyword] = args
+		case isInquire(line):
+			if err := c.inquire(line); err != nil {
+				return secretResult{}, err
 			}
 		default:
-			return GetPINResult{}, newUnexpectedResponseError(line)
+			if err := c.unexpectedResponse(line); err != nil {
+				return secretResult{}, err
+			}
 		}
 	}
 }
 
+// MaxLen returns the maximum length of inquiry data negotiated by the server
+// with INQUIRE MAXLEN, or zero if no maximum length has been negotiated.
+func (c *Client) MaxLen() int {
+	return c.maxLen
+}
+
 // Message shows the user a message.
 func (c *Client) Message() error {
+	if err := c.tryLock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.messageWithRetry()
+}
+
+// MessageContext shows the user a message. If ctx is done before the user
+// responds then the pinentry process is killed and ctx's error is returned.
+func (c *Client) MessageContext(ctx context.Context) error {
+	if err := c.tryLock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	_, err := withContext(ctx, c, func() (struct{}, error) {
+		return struct{}{}, c.messageWithRetry()
+	})
+	return err
+}
+
+// messageWithRetry shows the user a message, retrying without a global
+// keyboard grab if configured.
+func (c *Client) messageWithRetry() error {
+	_, err := withNoGlobalGrabRetry(c, func() (struct{}, error) {
+		return struct{}{}, c.message()
+	})
+	return err
+}
+
+// message shows the user a message.
+func (c *Client) message() error {
+	if err := c.setTimeout(c.messageTimeout); err != nil {
+		return err
+	}
 	command := "MESSAGE"
 	if err := c.writeLine(command); err != nil {
 		return err
 	}
-	switch line, err := c.readLine(); {
-	case err != nil:
+	defer c.startHeartbeat()()
+	return c.readOK()
+}
+
+// A PinentryLaunched is a notification that the pinentry process has
+// launched, sent when OptionAllowPinentryNotify is set. Args contains any
+// further arguments, such as the tty name, terminal type, and display.
+type PinentryLaunched struct {
+	PID     int
+	Flavor  string
+	Version string
+	Args    []string
+}
+
+// A Response is a complete response from the pinentry process.
+type Response struct {
+	Data   []byte
+	Status []string
+	OK     string
+}
+
+// StatusMap returns a map of the keyword of each of r's status lines to its
+// unescaped arguments. If a keyword occurs more than once then the last
+// arguments are used.
+func (r Response) StatusMap() map[string][]string {
+	statusMap := make(map[string][]string, len(r.Status))
+	for _, status := range r.Status {
+		keyword, args := parseStatus([]byte("S " + status))
+		statusMap[keyword] = args
+	}
+	return statusMap
+}
+
+// ReadResponse reads exactly one complete response, consisting of any data and
+// status lines followed by an OK or ERR line. Data lines are unescaped and
+// concatenated. An ERR line is returned as an *AssuanError. It is intended for
+// reading the response to a command written with WriteCommand.
+func (c *Client) ReadResponse() (Response, error) {
+	if err := c.tryLock(); err != nil {
+		return Response{}, err
+	}
+	defer c.mu.Unlock()
+	return c.readResponse()
+}
+
+// readResponse reads exactly one complete response.
+func (c *Client) readResponse() (Response, error) {
+	var response Response
+	for {
+		switch line, err := c.readLine(); {
+		case err != nil:
+			return Response{}, err
+		case isOK(line):
+			response.OK = string(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("OK")), []byte(" ")))
+			return response, nil
+		case isData(line):
+			response.Data = append(response.Data, unescape(line[2:])...)
+		case isStatus(line):
+			response.Status = append(response.Status, string(line[2:]))
+		case isInquire(line):
+			if err := c.inquire(line); err != nil {
+				return Response{}, err
+			}
+		default:
+			if err := c.unexpectedResponse(line); err != nil {
+				return Response{}, err
+			}
+		}
+	}
+}
+
+// WriteCommand writes a raw Assuan command. The response should be read with
+// ReadResponse.
+func (c *Client) WriteCommand(command string) error {
+	if err := c.tryLock(); err != nil {
 		return err
-	case isOK(line):
-		return nil
-	default:
-		return newUnexpectedResponseError(line)
 	}
+	defer c.mu.Unlock()
+	return c.writeLine(command)
 }
 
-// command writes a command and reads an OK response.
+// command writes a command and reads an OK response. The arguments of
+// successful text setting commands are recorded so that they can be restored.
 func (c *Client) command(command string) error {
 	if err := c.writeLine(command); err != nil {
 		return err
 	}
-	return c.readOK()
+	if err := c.readOK(); err != nil {
+		return err
+	}
+	keyword, args, _ := strings.Cut(command, " ")
+	switch keyword {
+	case "SETCANCEL", "SETDESC", "SETNOTOK", "SETOK":
+		if c.texts == nil {
+			c.texts = make(map[string]string)
+		}
+		c.texts[keyword] = args
+	}
+	return nil
+}
+
+// lockedCommand locks c and then sends command like command.
+func (c *Client) lockedCommand(command string) error {
+	if err := c.tryLock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	return c.command(command)
+}
+
+// drain reads and logs lines until the pinentry process closes its output. Only
+// processes started by the client are drained, as only they are expected to
+// exit. The process is killed if it writes more than maxDrainLines lines or
+// does not exit within drainTimeout.
+func (c *Client) drain() {
+	if _, ok := c.execProcess(); !ok {
+		return
+	}
+	deadline := time.Now().Add(drainTimeout)
+	for i := 0; i < maxDrainLines; i++ {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			break
+		}
+		line, err := withTimeout(c, "drain", timeout, func() ([]byte, error) {
+			line, _, err := c.process.ReadLine()
+			return line, err
+		})
+		if err != nil {
+			return
+		}
+		if c.logger != nil {
+			c.logger.Warn("drain", "line", line)
+		}
+	}
+	c.kill()
+}
+
+// handshake reads the greeting from the pinentry process and sends the initial
+// commands.
+func (c *Client) handshake() error {
+	if err := c.readGreeting(); err != nil {
+		c.startError(err)
+		return err
+	}
+	c.established = true
+	c.pinentryTimeout = 0
+	return c.sendInitialCommands()
+}
+
+// sendInitialCommands sends the timeout and the commands set by options.
+func (c *Client) sendInitialCommands() error {
+	switch {
+	case c.timeout != nil:
+		if err := c.sendTimeout(c.timeoutScale * *c.timeout / time.Second); err != nil {
+			return err
+		}
+	case c.pinentryTimeout != 0:
+		if err := c.sendTimeout(0); err != nil {
+			return err
+		}
+	}
+
+	for _, command := range append(slices.Clip(c.commands), c.buttonLabelCommands()...) {
+		if err := c.command(command); err != nil {
+			return err
+		}
+	}
+
+	if err := c.setFormattedPassphraseOptions(); err != nil {
+		return err
+	}
+
+	return c.setConstraintsOptions()
+}
+
+// setFormattedPassphraseOptions asks the pinentry process to format
+// passphrases, if configured, unless it does not support formatted
+// passphrases.
+func (c *Client) setFormattedPassphraseOptions() error {
+	if !c.formattedPassphrase {
+		return nil
+	}
+	if ok, err := c.optionalCommand("OPTION " + OptionFormattedPassphrase); !ok || err != nil {
+		return err
+	}
+	if c.formattedPassphraseHint == "" {
+		return nil
+	}
+	_, err := c.optionalCommand("OPTION " + OptionFormattedPassphraseHint + "=" + escape(c.formattedPassphraseHint))
+	return err
+}
+
+// optionalCommand sends command, returning false without an error if the
+// pinentry process rejects it, for example because it does not support an
+// option.
+func (c *Client) optionalCommand(command string) (bool, error) {
+	var assuanError *AssuanError
+	switch err := c.command(command); {
+	case errors.As(err, &assuanError):
+		return false, nil
+	case err != nil:
+		return false, err
+	default:
+		return true, nil
+	}
+}
+
+// inquire responds to the inquiry in line.
+func (c *Client) inquire(line []byte) error {
+	keyword, args, _ := bytes.Cut(line[8:], []byte(" "))
+	if handler, ok := c.inquiryHandlers[string(keyword)]; ok {
+		err := c.handleInquiry(handler, string(keyword), string(args))
+		if err != nil && c.established {
+			// The command continues after the inquiry, so read the rest of
+			// its response so that the next command reads its own response.
+			if discardErr := c.discardResponse(); discardErr != nil {
+				c.established = false
+				logErrorOrInfo(c.logger, "discardResponse", discardErr)
+			}
+		}
+		return err
+	}
+	switch string(keyword) {
+	case "MAXLEN":
+		maxLen, err := strconv.Atoi(string(args))
+		if err != nil || maxLen < 0 {
+			return c.writeLine("CAN")
+		}
+		c.maxLen = maxLen
+		return c.writeLine("END")
+	case "QUALITY":
+		pin := getPIN(args)
+		quality, ok := c.qualityFunc(pin)
+		if quality < -100 {
+			quality = -100
+		} else if quality > 100 {
+			quality = 100
+		}
+		if c.qualityEventFunc != nil {
+			c.qualityEventFunc(QualityEvent{
+				Length:  utf8.RuneCountInString(pin),
+				Quality: quality,
+				OK:      ok,
+			})
+		}
+		if !ok {
+			return c.writeLine("CAN")
+		}
+		return c.writeInquiryData([]byte(strconv.Itoa(quality)))
+	default:
+		if err := c.writeLine("CAN"); err != nil {
+			return err
+		}
+		return c.unexpectedResponse(line)
+	}
+}
+
+// readGreeting reads the greeting from the pinentry process, killing it if the
+// greeting timeout expires.
+func (c *Client) readGreeting() error {
+	_, err := withTimeout(c, "greeting", c.greetingTimeout, func() (struct{}, error) {
+		return struct{}{}, c.readOK()
+	})
+	return err
 }
 
 // readLine reads a line, ignoring blank lines and comments.
 func (c *Client) readLine() ([]byte, error) {
 	for {
-		line, _, err := c.process.ReadLine()
+		line, err := c.readProcessLine()
 		logErrorOrInfo(c.logger, "readLine", err, "line", line)
 		if err != nil {
 			return nil, err
@@ -418,6 +1553,11 @@ func (c *Client) readLine() ([]byte, error) {
 		case isComment(line):
 		case isError(line):
 			return nil, newError(line)
+		case c.inputValidation && (isData(line) || isStatus(line) || isInquire(line)):
+			if err := validateData(unescape(line)); err != nil {
+				return nil, err
+			}
+			return line, nil
 		default:
 			return line, err
 		}
@@ -426,23 +1566,284 @@ func (c *Client) readLine() ([]byte, error) {
 
 // readOK reads an OK response.
 func (c *Client) readOK() error {
-	switch line, err := c.readLine(); {
-	case err != nil:
+	for {
+		switch line, err := c.readLine(); {
+		case err != nil:
+			return err
+		case isOK(line):
+			return nil
+		default:
+			if err := c.unexpectedResponse(line); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// startHeartbeat starts calling the heartbeat function, if any, and returns a
+// function that stops it.
+func (c *Client) startHeartbeat() func() {
+	if c.heartbeatFunc == nil || c.heartbeatInterval <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	ticker := time.NewTicker(c.heartbeatInterval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				c.heartbeatFunc(now.Sub(start))
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-stopped
+	}
+}
+
+// kill kills the pinentry process, if the process supports it, so that it is
+// not asked to exit gracefully when the connection is closed.
+func (c *Client) kill() {
+	c.killed.Store(true)
+	if killer, ok := c.process.(interface{ Kill() error }); ok {
+		err := killer.Kill()
+		logErrorOrInfo(c.logger, "kill", err)
+	}
+}
+
+// execProcess returns c's process if it executes a pinentry process, unwrapping
+// any RecordingProcess.
+func (c *Client) execProcess() (*execProcess, bool) {
+	process := c.process
+	if recordingProcess, ok := process.(*RecordingProcess); ok {
+		process = recordingProcess.process
+	}
+	p, ok := process.(*execProcess)
+	return p, ok
+}
+
+// tryLock locks c for a single operation. It returns ErrBusy if another
+// operation is in progress.
+func (c *Client) tryLock() error {
+	if !c.mu.TryLock() {
+		return ErrBusy
+	}
+	return nil
+}
+
+// releasePromptLock releases the prompt lock, if any.
+func (c *Client) releasePromptLock() error {
+	if c.promptLock == nil {
+		return nil
+	}
+	err := c.promptLock.release()
+	c.promptLock = nil
+	return err
+}
+
+// startError calls the start error function and sends the desktop
+// notification, if any.
+func (c *Client) startError(err error) {
+	if c.startErrorFunc != nil {
+		c.startErrorFunc(err)
+	}
+	if c.desktopNotificationFunc != nil {
+		c.desktopNotificationFunc()
+	}
+}
+
+// start starts the pinentry process. If candidate binary names are set then
+// each is tried in turn and c.binaryName is set to the first that starts.
+func (c *Client) start() error {
+	if len(c.binaryNames) == 0 {
+		return wrapNotFound([]string{c.binaryName}, c.process.Start(c.binaryName, c.args))
+	}
+	errs := make([]error, 0, len(c.binaryNames))
+	for _, binaryName := range c.binaryNames {
+		err := c.process.Start(binaryName, c.args)
+		if err == nil {
+			c.binaryName = binaryName
+			return nil
+		}
+		logErrorOrInfo(c.logger, "start", err, "binaryName", binaryName)
+		errs = append(errs, err)
+	}
+	return wrapNotFound(c.binaryNames, errs...)
+}
+
+// ResetState resets the pinentry process's state with RESET, clearing the
+// error, repeat, and any other texts set since the client was created, and
+// then resends the options and texts set when the client was created. This
+// allows one pinentry process to be reused for several independent prompts.
+func (c *Client) ResetState() error {
+	if err := c.tryLock(); err != nil {
 		return err
-	case isOK(line):
+	}
+	defer c.mu.Unlock()
+	if err := c.command("RESET"); err != nil {
+		return err
+	}
+	c.texts = nil
+	c.keyInfo = c.optionKeyInfo
+	return c.sendInitialCommands()
+}
+
+// restartWithoutGlobalGrab restarts the pinentry process with
+// --no-global-grab.
+func (c *Client) restartWithoutGlobalGrab() error {
+	if err := c.closeProcess(); err != nil {
+		return err
+	}
+	c.args = append(c.args, "--no-global-grab")
+	if err := c.process.Start(c.binaryName, c.args); err != nil {
+		c.startError(err)
+		return err
+	}
+	return c.handshake()
+}
+
+// setTimeout sets the pinentry process's timeout to timeout or, if timeout is
+// nil, to the timeout set with WithTimeout, if any. pinentry keeps its timeout
+// between commands, so this restores the base timeout after a command with its
+// own timeout. Nothing is sent if the pinentry process already has the
+// timeout.
+func (c *Client) setTimeout(timeout *time.Duration) error {
+	if timeout == nil {
+		timeout = c.timeout
+	}
+	var seconds time.Duration
+	if timeout != nil {
+		seconds = c.timeoutScale * *timeout / time.Second
+	}
+	if seconds == c.pinentryTimeout {
 		return nil
-	default:
-		return newUnexpectedResponseError(line)
 	}
+	return c.sendTimeout(seconds)
+}
+
+// sendTimeout sends SETTIMEOUT with seconds.
+func (c *Client) sendTimeout(seconds time.Duration) error {
+	if err := c.command(fmt.Sprintf("SETTIMEOUT %d", seconds)); err != nil {
+		return err
+	}
+	c.pinentryTimeout = seconds
+	return nil
+}
+
+// writeInquiryData writes data in response to an inquiry, followed by END. The
+// data is escaped and split across multiple lines if necessary. If the data is
+// longer than the maximum length negotiated by the server, the inquiry is
+// cancelled instead.
+func (c *Client) writeInquiryData(data []byte) error {
+	if c.maxLen > 0 && len(data) > c.maxLen {
+		return c.writeLine("CAN")
+	}
+	if err := c.writeDataLines(data); err != nil {
+		return err
+	}
+	return c.writeLine("END")
+}
+
+// writeDataLines writes data as escaped data lines, splitting it so that no
+// line exceeds the maximum line length.
+func (c *Client) writeDataLines(data []byte) error {
+	escapedData := escape(string(data))
+	for len(escapedData) > maxDataLineLength {
+		n := maxDataLineLength
+		// Do not split escape sequences.
+		if i := strings.LastIndexByte(escapedData[n-2:n], '%'); i != -1 {
+			n = n - 2 + i
+		}
+		if err := c.writeLine("D " + escapedData[:n]); err != nil {
+			return err
+		}
+		escapedData = escapedData[n:]
+	}
+	return c.writeLine("D " + escapedData)
 }
 
 // writeLine writes a single line.
 func (c *Client) writeLine(line string) error {
-	_, err := c.process.Write([]byte(line + "\n"))
+	err := c.writeProcess([]byte(line + "\n"))
 	logErrorOrInfo(c.logger, "write", err, "line", line)
 	return err
 }
 
+// unexpectedResponse handles the unexpected response line. If an unexpected
+// response function is set then it is called with line and nil is returned.
+// Otherwise, an UnexpectedResponseError is returned after attempting to
+// resynchronize with the pinentry process so that the client can still be
+// used.
+func (c *Client) unexpectedResponse(line []byte) error {
+	if c.unexpectedResponseFunc != nil {
+		c.unexpectedResponseFunc(string(line))
+		return nil
+	}
+	err := newUnexpectedResponseError(line)
+	if c.established {
+		if resyncErr := c.resync(); resyncErr != nil {
+			c.established = false
+			logErrorOrInfo(c.logger, "resync", resyncErr)
+		}
+	}
+	return err
+}
+
+// resync resynchronizes with the pinentry process after an unexpected
+// response. It discards lines until the final OK or ERR of the command in
+// progress, cancelling any inquiries, and then sends a NOP command and reads
+// its OK, so that the next command reads its own response. Discarded lines are
+// not logged as they may contain the PIN.
+func (c *Client) resync() error {
+	if err := c.discardResponse(); err != nil {
+		return err
+	}
+	if err := c.writeLine("NOP"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readProcessLine()
+		switch {
+		case err != nil:
+			return err
+		case isBlank(line) || isComment(line):
+		case isOK(line):
+			return nil
+		case isError(line):
+			return newError(line)
+		default:
+			return newUnexpectedResponseError(line)
+		}
+	}
+}
+
+// discardResponse discards lines until the final OK or ERR of the command in
+// progress, cancelling any inquiries. Discarded lines are not logged as they
+// may contain the PIN.
+func (c *Client) discardResponse() error {
+	for {
+		line, err := c.readProcessLine()
+		if err != nil {
+			return err
+		}
+		if isOK(line) || isError(line) {
+			return nil
+		}
+		if isInquire(line) {
+			if err := c.writeLine("CAN"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
 // IsCancelled returns if the error is operation cancelled.
 func IsCancelled(err error) bool {
 	var assuanError *AssuanError
@@ -452,6 +1853,57 @@ func IsCancelled(err error) bool {
 	return assuanError.Code == AssuanErrorCodeCancelled
 }
 
+// isNotConfirmed returns if err indicates that the user did not confirm.
+func isNotConfirmed(err error) bool {
+	var assuanError *AssuanError
+	return errors.As(err, &assuanError) && assuanError.Code == AssuanErrorCodeNotConfirmed
+}
+
+// withContext calls f and, if ctx is done before f returns, kills the pinentry
+// process, waits for f to return, and returns ctx's error.
+func withContext[T any](ctx context.Context, c *Client, f func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if ctx.Done() == nil {
+		return f()
+	}
+	type resultErr struct {
+		result T
+		err    error
+	}
+	resultErrCh := make(chan resultErr, 1)
+	go func() {
+		result, err := f()
+		resultErrCh <- resultErr{result: result, err: err}
+	}()
+	select {
+	case resultErr := <-resultErrCh:
+		return resultErr.result, resultErr.err
+	case <-ctx.Done():
+		c.kill()
+		<-resultErrCh
+		return zero, ctx.Err()
+	}
+}
+
+// withNoGlobalGrabRetry calls f and, if f fails because pinentry could not grab
+// the keyboard and c is configured to retry, restarts pinentry with
+// --no-global-grab and calls f again.
+func withNoGlobalGrabRetry[T any](c *Client, f func() (T, error)) (T, error) {
+	result, err := f()
+	var keyboardGrabError *KeyboardGrabError
+	if !c.noGlobalGrabRetry || !errors.As(err, &keyboardGrabError) || slices.Contains(c.args, "--no-global-grab") {
+		return result, err
+	}
+	if err := c.restartWithoutGlobalGrab(); err != nil {
+		var zero T
+		return zero, err
+	}
+	return f()
+}
+
 func escape(s string) string {
 	bytes := []byte(s)
 	escapedBytes := make([]byte, 0, len(bytes))
@@ -495,11 +1947,21 @@ func isError(line []byte) bool {
 	return bytes.HasPrefix(line, []byte("ERR "))
 }
 
+// isInquire returns if line is an inquiry.
+func isInquire(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("INQUIRE "))
+}
+
 // isOK returns if the line is an OK response.
 func isOK(line []byte) bool {
 	return bytes.HasPrefix(line, []byte("OK"))
 }
 
+// isStatus returns if line is a status line.
+func isStatus(line []byte) bool {
+	return bytes.HasPrefix(line, []byte("S "))
+}
+
 // isUppercaseHexDigit returns if c is an uppercase hexadecimal digit.
 func isUppercaseHexDigit(c byte) bool {
 	switch {
@@ -519,10 +1981,78 @@ func newError(line []byte) error {
 		return newUnexpectedResponseError(line)
 	}
 	code, _ := strconv.Atoi(string(match[1]))
-	return &AssuanError{
+	assuanError := &AssuanError{
 		Code:        code,
 		Description: string(match[2]),
 	}
+	if isKeyboardGrabError(assuanError) {
+		return &KeyboardGrabError{
+			Err: assuanError,
+		}
+	}
+	return assuanError
+}
+
+// isKeyboardGrabError returns if assuanError is the error that pinentry reports
+// when it cannot grab the keyboard, which is a pinentry error with the grab
+// location, written either as the error text or as its source.
+func isKeyboardGrabError(assuanError *AssuanError) bool {
+	if assuanError.Code != AssuanErrorCodePinentryError {
+		return false
+	}
+	for _, field := range strings.Fields(assuanError.Description) {
+		if strings.Trim(field, "<>") == keyboardGrabErrorLocation {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStatus parses the keyword and unescaped arguments from the status line
+// line.
+func parseStatus(line []byte) (string, []string) {
+	fields := bytes.Fields(line[2:])
+	if len(fields) == 0 {
+		return "", nil
+	}
+	var args []string
+	for _, field := range fields[1:] {
+		args = append(args, string(unescape(field)))
+	}
+	return string(fields[0]), args
+}
+
+// parsePinentryLaunched parses the arguments of a PINENTRY_LAUNCHED inquiry.
+func parsePinentryLaunched(args string) PinentryLaunched {
+	var pinentryLaunched PinentryLaunched
+	fields := strings.Fields(args)
+	for i, field := range fields {
+		field = string(unescape([]byte(field)))
+		switch i {
+		case 0:
+			pinentryLaunched.PID, _ = strconv.Atoi(field)
+		case 1:
+			pinentryLaunched.Flavor = field
+		case 2:
+			pinentryLaunched.Version = field
+		default:
+			pinentryLaunched.Args = append(pinentryLaunched.Args, field)
+		}
+	}
+	return pinentryLaunched
+}
+
+// validateData returns an error if data contains a NUL byte or a control
+// character.
+func validateData(data []byte) error {
+	for _, b := range data {
+		if b < ' ' || b == 0x7f {
+			return &InvalidDataError{
+				Char: b,
+			}
+		}
+	}
+	return nil
 }
 
 // unescape unescapes data, interpreting invalid escape sequences literally
@@ -74,3 +74,43 @@ func TestUnescape(t *testing.T) {
 		})
 	}
 }
+
+func TestAppleScriptQuote(t *testing.T) {
+	assert.Equal(t, `"a \"quoted\" \\ string"`, appleScriptQuote(`a "quoted" \ string`))
+}
+
+func TestSanitizeText(t *testing.T) {
+	for i, tc := range []struct {
+		s        string
+		expected string
+	}{
+		{
+			s:        "",
+			expected: "",
+		},
+		{
+			s:        "line 1\nline 2",
+			expected: "line 1\nline 2",
+		},
+		{
+			s:        "a\tb\r\x00\x1b[31mc\x7f\u0085",
+			expected: "a b[31mc",
+		},
+		{
+			s:        "invoice\u202efdp.exe",
+			expected: "invoicefdp.exe",
+		},
+		{
+			s:        "\u2066isolated\u2069",
+			expected: "isolated",
+		},
+		{
+			s:        "invalid \xff",
+			expected: "invalid �",
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tc.expected, SanitizeText(tc.s))
+		})
+	}
+}
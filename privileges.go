@@ -0,0 +1,66 @@
+package pinentry
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// An ElevatedPrivilegesPolicy determines what happens when a graphical
+// pinentry is launched with elevated privileges.
+type ElevatedPrivilegesPolicy int
+
+// Elevated privileges policies.
+const (
+	ElevatedPrivilegesAllow ElevatedPrivilegesPolicy = iota
+	ElevatedPrivilegesWarn
+	ElevatedPrivilegesRefuse
+)
+
+// An ElevatedPrivilegesError is returned when a graphical pinentry would be
+// launched with elevated privileges and the policy is ElevatedPrivilegesRefuse.
+type ElevatedPrivilegesError struct {
+	BinaryName string
+}
+
+func (e *ElevatedPrivilegesError) Error() string {
+	return "pinentry: " + e.BinaryName + ": refusing to launch graphical pinentry with elevated privileges"
+}
+
+// WithElevatedPrivilegesPolicy sets the policy for launching a graphical
+// pinentry as root or, on Windows, as SYSTEM, which commonly misbehaves and is
+// usually a configuration mistake. Warnings are logged. The default is
+// ElevatedPrivilegesAllow.
+func WithElevatedPrivilegesPolicy(policy ElevatedPrivilegesPolicy) ClientOption {
+	return func(c *Client) {
+		c.elevatedPrivilegesPolicy = policy
+	}
+}
+
+// checkElevatedPrivileges applies the elevated privileges policy.
+func (c *Client) checkElevatedPrivileges() error {
+	if c.elevatedPrivilegesPolicy == ElevatedPrivilegesAllow || !graphicalBinaryName(c.binaryName) || !elevatedPrivileges() {
+		return nil
+	}
+	err := &ElevatedPrivilegesError{
+		BinaryName: c.binaryName,
+	}
+	if c.elevatedPrivilegesPolicy == ElevatedPrivilegesRefuse {
+		return err
+	}
+	if c.logger != nil {
+		c.logger.Warn("checkElevatedPrivileges", "err", err)
+	}
+	return nil
+}
+
+// graphicalBinaryName returns whether binaryName might be a graphical
+// pinentry.
+func graphicalBinaryName(binaryName string) bool {
+	base := strings.TrimSuffix(filepath.Base(binaryName), ".exe")
+	switch base {
+	case "pinentry-curses", "pinentry-tty":
+		return false
+	default:
+		return true
+	}
+}
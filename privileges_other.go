@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package pinentry
+
+// elevatedPrivileges returns false.
+func elevatedPrivileges() bool {
+	return false
+}
@@ -0,0 +1,42 @@
+package pinentry
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestGraphicalBinaryName(t *testing.T) {
+	for _, tc := range []struct {
+		binaryName string
+		expected   bool
+	}{
+		{binaryName: "pinentry", expected: true},
+		{binaryName: "/usr/bin/pinentry-curses", expected: false},
+		{binaryName: "pinentry-gnome3", expected: true},
+		{binaryName: "pinentry-qt.exe", expected: true},
+		{binaryName: "pinentry-tty", expected: false},
+	} {
+		t.Run(tc.binaryName, func(t *testing.T) {
+			assert.Equal(t, tc.expected, graphicalBinaryName(tc.binaryName))
+		})
+	}
+}
+
+func TestCheckElevatedPrivileges(t *testing.T) {
+	c := newClient([]ClientOption{
+		WithBinaryName("pinentry-qt"),
+		WithElevatedPrivilegesPolicy(ElevatedPrivilegesRefuse),
+	})
+	if elevatedPrivileges() {
+		assert.Equal(t, error(&ElevatedPrivilegesError{BinaryName: "pinentry-qt"}), c.checkElevatedPrivileges())
+	} else {
+		assert.NoError(t, c.checkElevatedPrivileges())
+	}
+
+	c = newClient([]ClientOption{
+		WithBinaryName("pinentry-curses"),
+		WithElevatedPrivilegesPolicy(ElevatedPrivilegesRefuse),
+	})
+	assert.NoError(t, c.checkElevatedPrivileges())
+}
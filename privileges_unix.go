@@ -0,0 +1,10 @@
+//go:build unix
+
+package pinentry
+
+import "os"
+
+// elevatedPrivileges returns whether the current process is running as root.
+func elevatedPrivileges() bool {
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,22 @@
+package pinentry
+
+import "syscall"
+
+// localSystemSID is the security identifier of the SYSTEM account.
+const localSystemSID = "S-1-5-18"
+
+// elevatedPrivileges returns whether the current process is running as
+// SYSTEM.
+func elevatedPrivileges() bool {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return false
+	}
+	defer token.Close() //nolint:errcheck
+	tokenUser, err := token.GetTokenUser()
+	if err != nil {
+		return false
+	}
+	sid, err := tokenUser.User.Sid.String()
+	return err == nil && sid == localSystemSID
+}
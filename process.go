@@ -2,8 +2,25 @@ package pinentry
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"io"
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// exitedReadTimeout is how long reads may continue after the process
+	// exits.
+	exitedReadTimeout = 100 * time.Millisecond
+
+	// maxStderrLen is the maximum length of the captured standard error.
+	maxStderrLen = 4096
 )
 
 // A Process abstracts the interface to a pinentry Process.
@@ -13,39 +30,244 @@ type Process interface {
 	Start(string, []string) error
 }
 
+// A ProcessExitedError is returned when the pinentry process exits while a
+// response is expected. ExitCode is -1 if the process was terminated by a
+// signal. Stderr contains the start of the process's standard error. It
+// matches ErrPinentryTerminated.
+type ProcessExitedError struct {
+	ExitCode int
+	Stderr   string
+	Err      error
+}
+
+func (e *ProcessExitedError) Error() string {
+	msg := "pinentry: pinentry terminated with exit status " + strconv.Itoa(e.ExitCode)
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += ": " + stderr
+	}
+	return msg
+}
+
+func (e *ProcessExitedError) Is(target error) bool {
+	return target == ErrPinentryTerminated
+}
+
+func (e *ProcessExitedError) Unwrap() error {
+	return e.Err
+}
+
 // A execProcess executes a pinentry process.
 type execProcess struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout *bufio.Reader
+	env          []string
+	envAllowlist []string
+	chroot       string
+	sysProcAttr  *syscall.SysProcAttr
+
+	sandbox              bool
+	sandboxWritablePaths []string
+	resourceLimits       []resourceLimit
+	binaryAllowlist      []string
+	binarySHA256s        []string
+
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	stdoutFile *os.File
+	stdout     *bufio.Reader
+	stderrFile *os.File
+	stderr     *stderrBuffer
+	detach     func() error
+	killed     atomic.Bool
+	exited     chan struct{}
+	waitErr    error
+
+	stderrCopied chan struct{}
 }
 
 func (p *execProcess) Close() (err error) {
-	defer combineErrorFunc(&err, p.cmd.Wait)
-	err = p.stdin.Close()
+	defer combineErrorFunc(&err, p.detach)
+	defer combineErrorFunc(&err, p.stdoutFile.Close)
+	defer combineErrorFunc(&err, p.closeStderr)
+	defer combineErrorFunc(&err, p.wait)
+	// stdin is already closed if the process has exited.
+	if err = p.stdin.Close(); errors.Is(err, os.ErrClosed) {
+		err = nil
+	}
 	return
 }
 
+// ExitStatus waits for the process to exit and returns its exit code and the
+// start of its standard error.
+func (p *execProcess) ExitStatus() (int, []byte) {
+	_ = p.wait()
+	<-p.stderrCopied
+	return p.cmd.ProcessState.ExitCode(), p.stderr.Bytes()
+}
+
+// Kill kills the process. Its exit status is then ignored by Close.
+func (p *execProcess) Kill() error {
+	p.killed.Store(true)
+	return p.cmd.Process.Kill()
+}
+
+// ReadLine reads a line. If the process exits then any blocked read returns
+// immediately with a *ProcessExitedError.
 func (p *execProcess) ReadLine() ([]byte, bool, error) {
-	return p.stdout.ReadLine()
+	line, isPrefix, err := p.stdout.ReadLine()
+	if err == nil {
+		return line, isPrefix, nil
+	}
+	if errors.Is(err, io.EOF) {
+		<-p.exited
+	}
+	select {
+	case <-p.exited:
+		<-p.stderrCopied
+		err = &ProcessExitedError{
+			ExitCode: p.cmd.ProcessState.ExitCode(),
+			Stderr:   p.stderr.String(),
+			Err:      err,
+		}
+	default:
+	}
+	return line, isPrefix, err
 }
 
 func (p *execProcess) Start(name string, args []string) (err error) {
+	if len(p.resourceLimits) > 0 && !resourceLimitsSupported {
+		return errors.ErrUnsupported
+	}
+	if p.chroot != "" && (p.binaryAllowlist != nil || p.binarySHA256s != nil) {
+		return ErrChrootVerification
+	}
+	if p.binaryAllowlist != nil {
+		if name, err = verifyBinary(name, p.binaryAllowlist); err != nil {
+			return
+		}
+	}
+	if p.binarySHA256s != nil {
+		if name, err = verifyBinarySHA256(name, p.binarySHA256s); err != nil {
+			return
+		}
+	}
+	env := p.env
+	if p.sandbox {
+		var sandboxEnv []string
+		if name, sandboxEnv, err = sandboxCommand(name, p.sandboxWritablePaths); err != nil {
+			return
+		}
+		env = append(append([]string{}, env...), sandboxEnv...)
+	}
 	p.cmd = exec.Command(name, args...)
+	p.killed.Store(false)
+	if p.sysProcAttr != nil {
+		sysProcAttr := *p.sysProcAttr
+		p.cmd.SysProcAttr = &sysProcAttr
+	}
+	configureCmd(p.cmd)
+	if p.chroot != "" {
+		if err = setChroot(p.cmd, p.chroot); err != nil {
+			return
+		}
+	}
+	if env != nil || p.envAllowlist != nil {
+		p.cmd.Env = append(baseEnviron(p.envAllowlist), env...)
+	}
 	p.stdin, err = p.cmd.StdinPipe()
 	if err != nil {
 		return
 	}
-	var stdoutPipe io.ReadCloser
-	stdoutPipe, err = p.cmd.StdoutPipe()
+	// Use os.Pipes rather than cmd.StdoutPipe and cmd.Stderr so that the read
+	// ends are not closed, discarding unread output, when the process exits,
+	// and so that cmd.Wait does not wait for descendants of the process that
+	// hold them open.
+	var stdoutWriter, stderrWriter *os.File
+	if p.stdoutFile, stdoutWriter, err = os.Pipe(); err != nil {
+		return
+	}
+	if p.stderrFile, stderrWriter, err = os.Pipe(); err != nil {
+		err = combineErrors(err, p.stdoutFile.Close(), stdoutWriter.Close())
+		return
+	}
+	closePipes := func() error {
+		return combineErrors(p.stdoutFile.Close(), p.stderrFile.Close())
+	}
+	p.cmd.Stdout = stdoutWriter
+	p.cmd.Stderr = stderrWriter
+	p.stdout = bufio.NewReader(p.stdoutFile)
+	releaseThread, err := startCmd(p.cmd)
+	err = combineErrors(err, stdoutWriter.Close(), stderrWriter.Close())
 	if err != nil {
+		err = combineErrors(err, closePipes())
+		return
+	}
+	if p.detach, err = attachToParent(p.cmd.Process); err != nil {
+		err = combineErrors(err, p.cmd.Process.Kill(), p.cmd.Wait(), closePipes())
+		releaseThread()
+		return
+	}
+	if err = setResourceLimits(p.cmd.Process.Pid, p.resourceLimits); err != nil {
+		err = combineErrors(err, p.detach(), p.cmd.Process.Kill(), p.cmd.Wait(), closePipes())
+		releaseThread()
 		return
 	}
-	p.stdout = bufio.NewReader(stdoutPipe)
-	err = p.cmd.Start()
+	p.stderr = &stderrBuffer{}
+	p.stderrCopied = make(chan struct{})
+	go func(stderr *stderrBuffer, stderrFile *os.File, stderrCopied chan<- struct{}) {
+		_, _ = io.Copy(stderr, stderrFile)
+		close(stderrCopied)
+	}(p.stderr, p.stderrFile, p.stderrCopied)
+	p.exited = make(chan struct{})
+	go p.watch(p.cmd, p.stdoutFile, p.stderrFile, p.exited, releaseThread)
 	return
 }
 
+// watch waits for cmd to exit, calls releaseThread, and then closes exited.
+// Reads from stdout and stderr are given exitedReadTimeout to consume any
+// remaining output, after which blocked reads return, even if a descendant of
+// the process still holds stdout or stderr open.
+func (p *execProcess) watch(cmd *exec.Cmd, stdout, stderr *os.File, exited chan<- struct{}, releaseThread func()) {
+	p.waitErr = cmd.Wait()
+	releaseThread()
+	close(exited)
+	deadline := time.Now().Add(exitedReadTimeout)
+	_ = stdout.SetReadDeadline(deadline)
+	_ = stderr.SetReadDeadline(deadline)
+}
+
 func (p *execProcess) Write(data []byte) (int, error) {
 	return p.stdin.Write(data)
 }
+
+// closeStderr waits for the process's standard error to be captured and then
+// closes it.
+func (p *execProcess) closeStderr() error {
+	<-p.stderrCopied
+	return p.stderrFile.Close()
+}
+
+// wait waits for the process to exit. It can be called more than once.
+func (p *execProcess) wait() error {
+	<-p.exited
+	err := p.waitErr
+	var exitError *exec.ExitError
+	if p.killed.Load() && errors.As(err, &exitError) {
+		return nil
+	}
+	return err
+}
+
+// A stderrBuffer captures the first maxStderrLen bytes written to it.
+type stderrBuffer struct {
+	bytes.Buffer
+}
+
+func (b *stderrBuffer) Write(data []byte) (int, error) {
+	n := maxStderrLen - b.Len()
+	if n > len(data) {
+		n = len(data)
+	}
+	if n > 0 {
+		b.Buffer.Write(data[:n])
+	}
+	return len(data), nil
+}
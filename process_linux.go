@@ -0,0 +1,49 @@
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+// configureCmd configures cmd so that the pinentry process is sent SIGTERM if
+// the calling process dies, unless another signal is already configured. The
+// signal is sent when the OS thread that started the process exits, so cmd
+// must be started with startCmd.
+func configureCmd(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	if cmd.SysProcAttr.Pdeathsig == 0 {
+		cmd.SysProcAttr.Pdeathsig = syscall.SIGTERM
+	}
+}
+
+// startCmd starts cmd from a goroutine that is locked to its OS thread until
+// the returned function is called, which must be done once the process has
+// exited. Otherwise, the Go runtime could terminate the thread while the
+// process is running, which would send it its parent death signal.
+func startCmd(cmd *exec.Cmd) (func(), error) {
+	errCh := make(chan error, 1)
+	release := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		err := cmd.Start()
+		errCh <- err
+		if err == nil {
+			<-release
+		}
+	}()
+	if err := <-errCh; err != nil {
+		return func() {}, err
+	}
+	return func() { close(release) }, nil
+}
+
+// attachToParent does nothing as the pinentry process's lifetime is already
+// tied to the calling process by configureCmd.
+func attachToParent(*os.Process) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,61 @@
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestConfigureCmd(t *testing.T) {
+	cmd := exec.Command("pinentry")
+	configureCmd(cmd)
+	assert.Equal(t, syscall.SIGTERM, cmd.SysProcAttr.Pdeathsig)
+}
+
+func TestConfigureCmdPdeathsig(t *testing.T) {
+	cmd := exec.Command("pinentry")
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Pdeathsig: syscall.SIGKILL,
+	}
+	configureCmd(cmd)
+	assert.Equal(t, syscall.SIGKILL, cmd.SysProcAttr.Pdeathsig)
+}
+
+func TestExecProcessSysProcAttr(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"read -r _ _ _ _ pgid _ < /proc/$$/stat\n"+
+		"echo \"D $$ $pgid\"\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo OK\n",
+	), 0o700))
+
+	sysProcAttr := &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+		WithSysProcAttr(sysProcAttr),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, syscall.Signal(0), sysProcAttr.Pdeathsig)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	pid, pgid, ok := strings.Cut(result.PIN, " ")
+	assert.True(t, ok)
+	assert.Equal(t, pid, pgid)
+	assert.NotEqual(t, strconv.Itoa(os.Getpid()), pgid)
+
+	assert.NoError(t, c.Close())
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+)
+
+// configureCmd does nothing.
+func configureCmd(*exec.Cmd) {}
+
+// startCmd starts cmd. The returned function does nothing.
+func startCmd(cmd *exec.Cmd) (func(), error) {
+	return func() {}, cmd.Start()
+}
+
+// attachToParent does nothing.
+func attachToParent(*os.Process) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,249 @@
+//go:build unix
+
+package pinentry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestExecProcessExitWatchdog(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"sleep 10 &\n"+
+		"echo crashed >&2\n"+
+		"exit 1\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+	)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = c.GetPIN()
+	assert.IsError(t, err, ErrPinentryTerminated)
+	assert.Equal(t, "pinentry: pinentry terminated with exit status 1: crashed", err.Error())
+	var processExitedErr *ProcessExitedError
+	assert.True(t, errors.As(err, &processExitedErr))
+	assert.Equal(t, 1, processExitedErr.ExitCode)
+	assert.Equal(t, "crashed\n", processExitedErr.Stderr)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	assert.Error(t, c.Close())
+}
+
+func TestExecProcessContextKill(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"exec sleep 10\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+	)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = c.GetPINContext(ctx)
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	assert.NoError(t, c.Close())
+}
+
+func TestExecProcessEnv(t *testing.T) {
+	t.Setenv("PINENTRY_TEST_A", "parent")
+	t.Setenv("PINENTRY_TEST_B", "parent")
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo \"D $PINENTRY_TEST_A $PINENTRY_TEST_B $PINENTRY_TEST_C\"\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo OK\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+		WithEnv(map[string]string{
+			"PINENTRY_TEST_A": "a",
+		}),
+		WithEnviron([]string{
+			"PINENTRY_TEST_C=b",
+			"PINENTRY_TEST_C=c",
+		}),
+	)
+	assert.NoError(t, err)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "a parent c", result.PIN)
+	assert.Equal(t, "parent", os.Getenv("PINENTRY_TEST_A"))
+
+	assert.NoError(t, c.Close())
+}
+
+func TestExecProcessScrubbedEnvironment(t *testing.T) {
+	t.Setenv("PINENTRY_TEST_A", "a")
+	t.Setenv("PINENTRY_TEST_B", "b")
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo \"D $PINENTRY_TEST_A-$PINENTRY_TEST_B-$PINENTRY_TEST_C-$HOME\"\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo OK\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+		WithEnv(map[string]string{
+			"PINENTRY_TEST_C": "c",
+		}),
+		WithScrubbedEnvironment("PINENTRY_TEST_A"),
+	)
+	assert.NoError(t, err)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "a--c-", result.PIN)
+
+	assert.NoError(t, c.Close())
+}
+
+func TestExecProcessWithPINRetryContext(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"exec sleep 10\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+	)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = c.WithPINRetry(ctx, func(string) error {
+		t.Fatal("unexpected attempt")
+		return nil
+	})
+	assert.IsError(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	assert.NoError(t, c.Close())
+}
+
+func TestExecProcessCancel(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"exec sleep 10\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+	)
+	assert.NoError(t, err)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		assert.NoError(t, c.Cancel())
+	})
+	start := time.Now()
+	_, err = c.GetPIN()
+	assert.True(t, IsCancelled(err))
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	_, err = c.Confirm("")
+	assert.True(t, IsCancelled(err))
+
+	assert.NoError(t, c.Close())
+}
+
+func TestExecProcessCancelIdle(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"exec sleep 10\n",
+	), 0o700))
+
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Cancel())
+	start := time.Now()
+	assert.NoError(t, c.Close())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestExecProcessCloseDrain(t *testing.T) {
+	binaryName := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+		"#!/bin/sh\n"+
+		"echo OK\n"+
+		"read -r line\n"+
+		"echo 'OK closing connection'\n"+
+		"echo 'stray output'\n",
+	), 0o700))
+
+	var buffer bytes.Buffer
+	c, err := NewClient(
+		WithBinaryName(binaryName),
+		WithLogger(slog.New(slog.NewTextHandler(&buffer, nil))),
+	)
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.Close())
+	assert.Contains(t, buffer.String(), `msg=drain line="stray output"`)
+}
+
+func TestExecProcessCloseDrainLimit(t *testing.T) {
+	for _, command := range []string{"yes", "sleep 10"} {
+		t.Run(command, func(t *testing.T) {
+			binaryName := filepath.Join(t.TempDir(), "pinentry")
+			assert.NoError(t, os.WriteFile(binaryName, []byte(""+
+				"#!/bin/sh\n"+
+				"echo OK\n"+
+				"read -r line\n"+
+				"echo 'OK closing connection'\n"+
+				"exec "+command+"\n",
+			), 0o700))
+
+			c, err := NewClient(
+				WithBinaryName(binaryName),
+			)
+			assert.NoError(t, err)
+
+			start := time.Now()
+			assert.NoError(t, c.Close())
+			assert.True(t, time.Since(start) < 5*time.Second)
+		})
+	}
+}
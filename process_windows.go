@@ -0,0 +1,93 @@
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	jobObjectExtendedLimitInformationClass = 9
+	jobObjectLimitKillOnJobClose           = 0x2000
+	processSetQuota                        = 0x0100
+	processTerminate                       = 0x0001
+)
+
+var (
+	procAssignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+	procCreateJobObjectW         = kernel32.NewProc("CreateJobObjectW")
+	procSetInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+)
+
+type jobObjectBasicLimitInformation struct {
+	PerProcessUserTimeLimit int64
+	PerJobUserTimeLimit     int64
+	LimitFlags              uint32
+	MinimumWorkingSetSize   uintptr
+	MaximumWorkingSetSize   uintptr
+	ActiveProcessLimit      uint32
+	Affinity                uintptr
+	PriorityClass           uint32
+	SchedulingClass         uint32
+}
+
+type ioCounters struct {
+	ReadOperationCount  uint64
+	WriteOperationCount uint64
+	OtherOperationCount uint64
+	ReadTransferCount   uint64
+	WriteTransferCount  uint64
+	OtherTransferCount  uint64
+}
+
+type jobObjectExtendedLimitInformation struct {
+	BasicLimitInformation jobObjectBasicLimitInformation
+	IoInfo                ioCounters
+	ProcessMemoryLimit    uintptr
+	JobMemoryLimit        uintptr
+	PeakProcessMemoryUsed uintptr
+	PeakJobMemoryUsed     uintptr
+}
+
+// configureCmd does nothing.
+func configureCmd(*exec.Cmd) {}
+
+// startCmd starts cmd. The returned function does nothing.
+func startCmd(cmd *exec.Cmd) (func(), error) {
+	return func() {}, cmd.Start()
+}
+
+// attachToParent assigns process to a new Job Object that kills it when the
+// last handle to the Job Object is closed, which happens automatically if the
+// calling process dies. It returns a function that closes the handle.
+func attachToParent(process *os.Process) (func() error, error) {
+	job, _, err := procCreateJobObjectW.Call(0, 0)
+	if job == 0 {
+		return nil, err
+	}
+	closeJob := func() error {
+		return syscall.CloseHandle(syscall.Handle(job))
+	}
+
+	info := jobObjectExtendedLimitInformation{
+		BasicLimitInformation: jobObjectBasicLimitInformation{
+			LimitFlags: jobObjectLimitKillOnJobClose,
+		},
+	}
+	if r1, _, err := procSetInformationJobObject.Call(job, jobObjectExtendedLimitInformationClass, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); r1 == 0 {
+		return nil, combineErrors(err, closeJob())
+	}
+
+	handle, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(process.Pid))
+	if err != nil {
+		return nil, combineErrors(err, closeJob())
+	}
+	defer syscall.CloseHandle(handle) //nolint:errcheck
+
+	if r1, _, err := procAssignProcessToJobObject.Call(job, uintptr(handle)); r1 == 0 {
+		return nil, combineErrors(err, closeJob())
+	}
+
+	return closeJob, nil
+}
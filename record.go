@@ -0,0 +1,116 @@
+package pinentry
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A RecordingProcess is a Process that records a transcript of the session
+// with another Process, which can be replayed with ReplayProcess, for example
+// to turn a bug report into a regression test. Each line written by the client
+// is recorded as "> " followed by the line, and each line read from the
+// pinentry as "< " followed by the line. A line "@ " followed by a duration,
+// for example "@ 1.5s", records how long the pinentry took to respond. The
+// transcript includes the PINs entered by the user.
+type RecordingProcess struct {
+	process     Process
+	transcript  io.Writer
+	now         func() time.Time
+	mutex       sync.Mutex
+	lastTime    time.Time
+	partialLine []byte
+	err         error
+}
+
+// NewRecordingProcess returns a new RecordingProcess that records its session
+// with process to transcript.
+func NewRecordingProcess(process Process, transcript io.Writer) *RecordingProcess {
+	return &RecordingProcess{
+		process:    process,
+		transcript: transcript,
+		now:        time.Now,
+	}
+}
+
+// Close closes the underlying process. It also returns the first error from
+// writing the transcript, if any.
+func (p *RecordingProcess) Close() error {
+	err := p.process.Close()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return combineErrors(err, p.err)
+}
+
+// ExitStatus returns the exit status of the underlying process, if it reports
+// one.
+func (p *RecordingProcess) ExitStatus() (int, []byte) {
+	if exiter, ok := p.process.(interface{ ExitStatus() (int, []byte) }); ok {
+		return exiter.ExitStatus()
+	}
+	return 0, nil
+}
+
+// Kill kills the underlying process. It returns errors.ErrUnsupported if the
+// underlying process cannot be killed.
+func (p *RecordingProcess) Kill() error {
+	if killer, ok := p.process.(interface{ Kill() error }); ok {
+		return killer.Kill()
+	}
+	return errors.ErrUnsupported
+}
+
+// ReadLine reads a line from the underlying process and records it.
+func (p *RecordingProcess) ReadLine() ([]byte, bool, error) {
+	line, isPrefix, err := p.process.ReadLine()
+	if err != nil {
+		return line, isPrefix, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if isPrefix {
+		p.partialLine = append(p.partialLine, line...)
+		return line, isPrefix, err
+	}
+	now := p.now()
+	if delay := now.Sub(p.lastTime).Round(time.Millisecond); delay > 0 {
+		p.record("@ " + delay.String())
+	}
+	p.lastTime = now
+	p.record("< " + string(p.partialLine) + string(line))
+	p.partialLine = nil
+	return line, isPrefix, err
+}
+
+// Start starts the underlying process.
+func (p *RecordingProcess) Start(name string, args []string) error {
+	p.mutex.Lock()
+	p.lastTime = p.now()
+	p.mutex.Unlock()
+	return p.process.Start(name, args)
+}
+
+// Write writes data to the underlying process and records the lines written.
+func (p *RecordingProcess) Write(data []byte) (int, error) {
+	n, err := p.process.Write(data)
+	if n == 0 {
+		return n, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.lastTime = p.now()
+	for _, line := range strings.Split(strings.TrimSuffix(string(data[:n]), "\n"), "\n") {
+		p.record("> " + line)
+	}
+	return n, err
+}
+
+// record writes line to the transcript, keeping the first error.
+func (p *RecordingProcess) record(line string) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = io.WriteString(p.transcript, line+"\n")
+}
@@ -0,0 +1,177 @@
+package pinentry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ReplayError is returned by ReplayProcess when the session diverges from
+// the transcript. Expected is the next line of the transcript, or empty at the
+// end of the transcript. Actual is the line written by the client prefixed
+// with "> ", "<" if the client read a line, or empty if the client closed the
+// process.
+type ReplayError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ReplayError) Error() string {
+	expected := "end of transcript"
+	if e.Expected != "" {
+		expected = strconv.Quote(e.Expected)
+	}
+	actual := "close"
+	if e.Actual != "" {
+		actual = strconv.Quote(e.Actual)
+	}
+	return "pinentry: replay: expected " + expected + ", got " + actual
+}
+
+// A ReplayProcess is a Process that replays a transcript recorded by
+// RecordingProcess, including the time that the pinentry took to respond, so
+// that recorded sessions can be used as deterministic tests. Lines written by
+// the client must match the transcript, otherwise a *ReplayError is returned.
+type ReplayProcess struct {
+	mutex     sync.Mutex
+	entries   []transcriptEntry
+	timeScale float64
+}
+
+// A ReplayOption sets an option on a ReplayProcess.
+type ReplayOption func(*ReplayProcess)
+
+// A transcriptEntry is a line of a transcript. If read is set then the line is
+// read by the client after delay. Otherwise, it is written by the client.
+type transcriptEntry struct {
+	read  bool
+	line  string
+	delay time.Duration
+}
+
+// WithReplayTimeScale scales the recorded response times by scale. A scale of
+// zero replays the transcript without delays. The default is one.
+func WithReplayTimeScale(scale float64) ReplayOption {
+	return func(p *ReplayProcess) {
+		p.timeScale = scale
+	}
+}
+
+// NewReplayProcess returns a new ReplayProcess that replays the transcript
+// read from r.
+func NewReplayProcess(r io.Reader, options ...ReplayOption) (*ReplayProcess, error) {
+	entries, err := parseTranscript(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &ReplayProcess{
+		entries:   entries,
+		timeScale: 1,
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p, nil
+}
+
+// Close returns a *ReplayError if the transcript has not been replayed
+// completely.
+func (p *ReplayProcess) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.entries) != 0 {
+		return &ReplayError{Expected: p.entries[0].String()}
+	}
+	return nil
+}
+
+// ReadLine returns the next line read in the transcript after its recorded
+// delay. It returns io.EOF at the end of the transcript.
+func (p *ReplayProcess) ReadLine() ([]byte, bool, error) {
+	p.mutex.Lock()
+	if len(p.entries) == 0 {
+		p.mutex.Unlock()
+		return nil, false, io.EOF
+	}
+	entry := p.entries[0]
+	if !entry.read {
+		p.mutex.Unlock()
+		return nil, false, &ReplayError{Expected: entry.String(), Actual: "<"}
+	}
+	p.entries = p.entries[1:]
+	p.mutex.Unlock()
+	time.Sleep(time.Duration(float64(entry.delay) * p.timeScale))
+	return []byte(entry.line), false, nil
+}
+
+// Start does nothing.
+func (p *ReplayProcess) Start(string, []string) error {
+	return nil
+}
+
+// Write checks that the lines in data are the next lines written in the
+// transcript.
+func (p *ReplayProcess) Write(data []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if len(p.entries) == 0 || p.entries[0].read || p.entries[0].line != line {
+			replayError := &ReplayError{Actual: "> " + line}
+			if len(p.entries) != 0 {
+				replayError.Expected = p.entries[0].String()
+			}
+			return 0, replayError
+		}
+		p.entries = p.entries[1:]
+	}
+	return len(data), nil
+}
+
+func (e transcriptEntry) String() string {
+	if e.read {
+		return "< " + e.line
+	}
+	return "> " + e.line
+}
+
+// parseTranscript parses a transcript recorded by RecordingProcess. Empty lines
+// are ignored.
+func parseTranscript(r io.Reader) ([]transcriptEntry, error) {
+	var entries []transcriptEntry
+	var delay time.Duration
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := scanner.Text()
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "> ") && delay == 0:
+			entries = append(entries, transcriptEntry{
+				line: text[2:],
+			})
+		case strings.HasPrefix(text, "< "):
+			entries = append(entries, transcriptEntry{
+				read:  true,
+				line:  text[2:],
+				delay: delay,
+			})
+			delay = 0
+		case strings.HasPrefix(text, "@ "):
+			var err error
+			if delay, err = time.ParseDuration(text[2:]); err != nil || delay < 0 {
+				return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidTranscript, lineNumber, text)
+			}
+		default:
+			return nil, fmt.Errorf("%w: line %d: %s", ErrInvalidTranscript, lineNumber, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+package pinentry
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+const testTranscript = `< OK Pleased to meet you
+> GETPIN
+@ 250ms
+< D abc
+< OK
+> BYE
+< OK closing connection
+`
+
+func TestReplayProcess(t *testing.T) {
+	p, err := NewReplayProcess(strings.NewReader(testTranscript), WithReplayTimeScale(0.1))
+	assert.NoError(t, err)
+	c, err := NewClient(WithProcess(p))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.True(t, time.Since(start) >= 25*time.Millisecond)
+	assert.NoError(t, c.Close())
+}
+
+func TestReplayProcessMismatch(t *testing.T) {
+	p, err := NewReplayProcess(strings.NewReader(testTranscript), WithReplayTimeScale(0))
+	assert.NoError(t, err)
+	c, err := NewClient(WithProcess(p))
+	assert.NoError(t, err)
+
+	_, err = c.Confirm("")
+	var replayError *ReplayError
+	assert.True(t, errors.As(err, &replayError))
+	assert.Equal(t, ReplayError{Expected: "> GETPIN", Actual: "> CONFIRM"}, *replayError)
+	assert.Error(t, c.Close())
+}
+
+func TestReplayProcessInvalidTranscript(t *testing.T) {
+	for _, transcript := range []string{
+		"OK",
+		"@ 1x\n< OK",
+		"@ -1s\n< OK",
+		"@ 1s\n> GETPIN",
+	} {
+		_, err := NewReplayProcess(strings.NewReader(transcript))
+		assert.IsError(t, err, ErrInvalidTranscript)
+	}
+}
+
+func TestRecordingProcess(t *testing.T) {
+	replayProcess, err := NewReplayProcess(strings.NewReader(testTranscript), WithReplayTimeScale(0))
+	assert.NoError(t, err)
+	var transcript strings.Builder
+	p := NewRecordingProcess(replayProcess, &transcript)
+	var now time.Time
+	p.now = func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+	c, err := NewClient(WithProcess(p))
+	assert.NoError(t, err)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, strings.Join([]string{
+		"@ 250ms",
+		"< OK Pleased to meet you",
+		"> GETPIN",
+		"@ 250ms",
+		"< D abc",
+		"@ 250ms",
+		"< OK",
+		"> BYE",
+		"@ 250ms",
+		"< OK closing connection",
+	}, "\n")+"\n", transcript.String())
+}
+
+func TestClientWithRecording(t *testing.T) {
+	p, err := NewReplayProcess(strings.NewReader(testTranscript), WithReplayTimeScale(0))
+	assert.NoError(t, err)
+	var transcript strings.Builder
+	c, err := NewClient(WithProcess(p), WithRecording(&transcript))
+	assert.NoError(t, err)
+
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.NoError(t, c.Close())
+	assert.Contains(t, transcript.String(), "> GETPIN\n")
+	assert.Contains(t, transcript.String(), "< D abc\n")
+}
@@ -0,0 +1,69 @@
+package pinentry
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	defaultPINRetryLimit     = 3
+	defaultWrongPINErrorText = "Wrong passphrase"
+)
+
+// WithPINRetryLimit sets the maximum number of times that Client.WithPINRetry
+// prompts for a PIN. The default is 3. If limit is less than one then NewClient
+// returns ErrInvalidPINRetryLimit.
+func WithPINRetryLimit(limit int) ClientOption {
+	return WithClientOptionE(func(c *Client) error {
+		if limit < 1 {
+			return ErrInvalidPINRetryLimit
+		}
+		c.pinRetryLimit = limit
+		return nil
+	})
+}
+
+// WithWrongPINErrorText sets the error shown by Client.WithPINRetry when the
+// user is prompted again after entering a wrong PIN. The default is "Wrong
+// passphrase".
+func WithWrongPINErrorText(errorText string) ClientOption {
+	return func(c *Client) {
+		c.wrongPINErrorText = errorText
+	}
+}
+
+// WithPINRetry gets a PIN from the user and calls attempt with it, for example
+// to decrypt a private key. If attempt returns an error wrapping ErrWrongPIN
+// then any cached passphrase for the key set with WithKeyInfo is cleared, the
+// wrong PIN error text is shown, and the user is prompted again, up to the
+// PIN retry limit. Otherwise, the error returned by attempt is returned. If
+// the retry limit is reached then the last error returned by attempt is
+// returned. If the user cancels, an error is returned which can be tested with
+// IsCancelled. If ctx is done before the user responds then the pinentry
+// process is killed and ctx's error is returned.
+func (c *Client) WithPINRetry(ctx context.Context, attempt func(pin string) error) error {
+	var err error
+	for i := 0; i < c.pinRetryLimit; i++ {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if i > 0 {
+			if c.keyInfo != "" {
+				if err := c.ClearPassphrase(c.keyInfo); err != nil {
+					return err
+				}
+			}
+			if err := c.lockedCommand("SETERROR " + escape(c.wrongPINErrorText)); err != nil {
+				return err
+			}
+		}
+		var result GetPINResult
+		if result, err = c.GetPINContext(ctx); err != nil {
+			return err
+		}
+		if err = attempt(result.PIN); !errors.Is(err, ErrWrongPIN) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,52 @@
+package pinentry
+
+import "time"
+
+// A resource is a resource whose usage by the pinentry process can be
+// limited.
+type resource int
+
+const (
+	resourceAddressSpace resource = iota
+	resourceCoreFileSize
+	resourceCPUTime
+)
+
+// A resourceLimit is a limit on a resource.
+type resourceLimit struct {
+	resource resource
+	limit    uint64
+}
+
+// WithCoreDumpsDisabled prevents the pinentry process from writing a core
+// file, which might contain the passphrase typed by the user, if it crashes.
+// It is only supported on Linux. On other systems, starting the pinentry
+// process fails with errors.ErrUnsupported.
+func WithCoreDumpsDisabled() ClientOption {
+	return withResourceLimit(resourceCoreFileSize, 0)
+}
+
+// WithCPUTimeLimit limits the CPU time used by the pinentry process to
+// cpuTime, rounded up to the nearest second. It is only supported on Linux. On
+// other systems, starting the pinentry process fails with
+// errors.ErrUnsupported.
+func WithCPUTimeLimit(cpuTime time.Duration) ClientOption {
+	return withResourceLimit(resourceCPUTime, uint64((cpuTime+time.Second-1)/time.Second))
+}
+
+// WithMemoryLimit limits the virtual memory of the pinentry process to bytes.
+// It is only supported on Linux. On other systems, starting the pinentry
+// process fails with errors.ErrUnsupported.
+func WithMemoryLimit(bytes uint64) ClientOption {
+	return withResourceLimit(resourceAddressSpace, bytes)
+}
+
+// withResourceLimit limits the pinentry process's usage of resource to limit.
+func withResourceLimit(resource resource, limit uint64) ClientOption {
+	return func(c *Client) {
+		c.resourceLimits = append(c.resourceLimits, resourceLimit{
+			resource: resource,
+			limit:    limit,
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package pinentry
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const resourceLimitsSupported = true
+
+var rlimitResources = map[resource]int{
+	resourceAddressSpace: syscall.RLIMIT_AS,
+	resourceCoreFileSize: syscall.RLIMIT_CORE,
+	resourceCPUTime:      syscall.RLIMIT_CPU,
+}
+
+// setResourceLimits applies resourceLimits to the process with pid.
+func setResourceLimits(pid int, resourceLimits []resourceLimit) error {
+	for _, resourceLimit := range resourceLimits {
+		rlimit := struct {
+			Cur uint64
+			Max uint64
+		}{
+			Cur: resourceLimit.limit,
+			Max: resourceLimit.limit,
+		}
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(rlimitResources[resourceLimit.resource]), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package pinentry
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSetResourceLimits(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	assert.NoError(t, cmd.Start())
+	defer func() {
+		assert.NoError(t, cmd.Process.Kill())
+		_ = cmd.Wait()
+	}()
+
+	assert.NoError(t, setResourceLimits(cmd.Process.Pid, []resourceLimit{
+		{resource: resourceCoreFileSize, limit: 0},
+		{resource: resourceCPUTime, limit: 10},
+	}))
+
+	limits, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "limits"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(limits), "Max core file size        0                    0                    bytes")
+	assert.Contains(t, string(limits), "Max cpu time              10                   10                   seconds")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package pinentry
+
+import "errors"
+
+const resourceLimitsSupported = false
+
+func setResourceLimits(_ int, resourceLimits []resourceLimit) error {
+	if len(resourceLimits) == 0 {
+		return nil
+	}
+	return errors.ErrUnsupported
+}
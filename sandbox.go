@@ -0,0 +1,89 @@
+package pinentry
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables used to pass the sandbox configuration to the sandbox
+// shim.
+const (
+	sandboxBinaryEnvVar        = "GO_PINENTRY_SANDBOX_BINARY"
+	sandboxWritablePathsEnvVar = "GO_PINENTRY_SANDBOX_WRITABLE_PATHS"
+)
+
+// defaultSandboxWritablePaths are the paths beneath which the sandboxed
+// pinentry process can always write, so that it can use the terminal.
+var defaultSandboxWritablePaths = []string{"/dev"}
+
+// RunSandboxShim runs the sandbox shim if the current process was started as
+// one by a Client created with WithSandbox, in which case it applies the
+// sandbox to the current process and then executes the pinentry binary,
+// never returning. Otherwise, it returns immediately. Programs that use
+// WithSandbox must call RunSandboxShim at the start of main.
+func RunSandboxShim() {
+	binary, ok := os.LookupEnv(sandboxBinaryEnvVar)
+	if !ok {
+		return
+	}
+	// The sandbox only restricts the thread that applies it, so stay on that
+	// thread until the pinentry binary is executed from it.
+	runtime.LockOSThread()
+	writablePaths := filepath.SplitList(os.Getenv(sandboxWritablePathsEnvVar))
+	os.Unsetenv(sandboxBinaryEnvVar)        //nolint:errcheck
+	os.Unsetenv(sandboxWritablePathsEnvVar) //nolint:errcheck
+
+	if err := applySandbox(writablePaths); err != nil {
+		fmt.Fprintf(os.Stderr, "pinentry: sandbox: %v\n", err)
+		os.Exit(1)
+	}
+	err := sandboxExec(binary, append([]string{binary}, os.Args[1:]...))
+	fmt.Fprintf(os.Stderr, "pinentry: sandbox: %s: %v\n", binary, err)
+	os.Exit(1)
+}
+
+// WithSandbox runs the pinentry process in a sandbox in which it cannot make
+// TCP connections and can only write to files beneath /dev and
+// writablePaths. The sandbox is applied by re-executing the current program as
+// a shim, so the program must call RunSandboxShim at the start of main. The
+// sandbox uses Landlock and so requires Linux 5.13 or later, and restricting
+// TCP requires Linux 6.7 or later. On other systems, starting the pinentry
+// process fails with errors.ErrUnsupported. WithSandbox cannot be combined
+// with WithChroot.
+func WithSandbox(writablePaths ...string) ClientOption {
+	return func(c *Client) {
+		c.sandbox = true
+		c.sandboxWritablePaths = append(c.sandboxWritablePaths, writablePaths...)
+	}
+}
+
+// sandboxCommand returns the name of the sandbox shim and the environment
+// variables that instruct it to execute the pinentry binary name with
+// writablePaths.
+func sandboxCommand(name string, writablePaths []string) (string, []string, error) {
+	if !sandboxSupported {
+		return "", nil, fmt.Errorf("sandbox: %w", errors.ErrUnsupported)
+	}
+	binary, err := exec.LookPath(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if binary, err = filepath.Abs(binary); err != nil {
+		return "", nil, err
+	}
+	shim, err := os.Executable()
+	if err != nil {
+		return "", nil, err
+	}
+	allWritablePaths := append(append([]string{}, defaultSandboxWritablePaths...), writablePaths...)
+	env := []string{
+		sandboxBinaryEnvVar + "=" + binary,
+		sandboxWritablePathsEnvVar + "=" + strings.Join(allWritablePaths, string(os.PathListSeparator)),
+	}
+	return shim, env, nil
+}
@@ -0,0 +1,132 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package pinentry
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const sandboxSupported = true
+
+// Landlock constants, from linux/landlock.h.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+
+	landlockRulePathBeneath = 1
+
+	landlockAccessFSWriteFile  = 1 << 1
+	landlockAccessFSRemoveDir  = 1 << 4
+	landlockAccessFSRemoveFile = 1 << 5
+	landlockAccessFSMakeChar   = 1 << 6
+	landlockAccessFSMakeDir    = 1 << 7
+	landlockAccessFSMakeReg    = 1 << 8
+	landlockAccessFSMakeSock   = 1 << 9
+	landlockAccessFSMakeFifo   = 1 << 10
+	landlockAccessFSMakeBlock  = 1 << 11
+	landlockAccessFSMakeSym    = 1 << 12
+	landlockAccessFSRefer      = 1 << 13
+	landlockAccessFSTruncate   = 1 << 14
+
+	landlockAccessNetBindTCP    = 1 << 0
+	landlockAccessNetConnectTCP = 1 << 1
+)
+
+const (
+	oPath             = 0x200000
+	prSetNoNewPrivs   = 38
+	sizeofRulesetAttr = 16
+)
+
+type landlockRulesetAttr struct {
+	HandledAccessFS  uint64
+	HandledAccessNet uint64
+}
+
+type landlockPathBeneathAttr struct {
+	AllowedAccess uint64
+	ParentFD      int32
+}
+
+// sandboxExec executes binary with argv, replacing the current process.
+func sandboxExec(binary string, argv []string) error {
+	return syscall.Exec(binary, argv, os.Environ())
+}
+
+// applySandbox restricts the current process with Landlock so that it cannot
+// make TCP connections and can only write beneath writablePaths.
+func applySandbox(writablePaths []string) error {
+	abi, _, errno := syscall.RawSyscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		return errno
+	}
+
+	attr := landlockRulesetAttr{
+		HandledAccessFS: landlockAccessFSWriteFile |
+			landlockAccessFSRemoveDir |
+			landlockAccessFSRemoveFile |
+			landlockAccessFSMakeChar |
+			landlockAccessFSMakeDir |
+			landlockAccessFSMakeReg |
+			landlockAccessFSMakeSock |
+			landlockAccessFSMakeFifo |
+			landlockAccessFSMakeBlock |
+			landlockAccessFSMakeSym,
+	}
+	attrSize := uintptr(8)
+	if abi >= 2 {
+		attr.HandledAccessFS |= landlockAccessFSRefer
+	}
+	if abi >= 3 {
+		attr.HandledAccessFS |= landlockAccessFSTruncate
+	}
+	if abi >= 4 {
+		attr.HandledAccessNet = landlockAccessNetBindTCP | landlockAccessNetConnectTCP
+		attrSize = sizeofRulesetAttr
+	}
+	rulesetFD, _, errno := syscall.RawSyscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr)), attrSize, 0)
+	if errno != 0 {
+		return errno
+	}
+	defer syscall.Close(int(rulesetFD)) //nolint:errcheck
+
+	for _, path := range writablePaths {
+		if err := addLandlockPathBeneathRule(int(rulesetFD), path, attr.HandledAccessFS); err != nil {
+			if errors.Is(err, syscall.ENOENT) {
+				continue
+			}
+			return err
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return errno
+	}
+	if _, _, errno := syscall.RawSyscall(sysLandlockRestrictSelf, rulesetFD, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// addLandlockPathBeneathRule allows allowedAccess beneath path.
+func addLandlockPathBeneathRule(rulesetFD int, path string, allowedAccess uint64) error {
+	fd, err := syscall.Open(path, oPath|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd) //nolint:errcheck
+	attr := landlockPathBeneathAttr{
+		AllowedAccess: allowedAccess,
+		ParentFD:      int32(fd),
+	}
+	if _, _, errno := syscall.RawSyscall6(sysLandlockAddRule, uintptr(rulesetFD), landlockRulePathBeneath, uintptr(unsafe.Pointer(&attr)), 0, 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build linux && (386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package pinentry
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+const (
+	sandboxTestAllowedDirEnvVar = "GO_PINENTRY_TEST_SANDBOX_ALLOWED_DIR"
+	sandboxTestDeniedDirEnvVar  = "GO_PINENTRY_TEST_SANDBOX_DENIED_DIR"
+)
+
+func TestSandboxShim(t *testing.T) {
+	if _, _, errno := syscall.RawSyscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion); errno != 0 {
+		t.Skipf("Landlock not available: %v", errno)
+	}
+
+	executable, err := os.Executable()
+	assert.NoError(t, err)
+	allowedDir := t.TempDir()
+	deniedDir := t.TempDir()
+	cmd := exec.Command(executable, "-test.run=^TestSandboxShimHelper$", "-test.v")
+	cmd.Env = append(os.Environ(),
+		sandboxBinaryEnvVar+"="+executable,
+		sandboxWritablePathsEnvVar+"="+allowedDir,
+		sandboxTestAllowedDirEnvVar+"="+allowedDir,
+		sandboxTestDeniedDirEnvVar+"="+deniedDir,
+	)
+	output, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(output))
+
+	_, err = os.Stat(filepath.Join(allowedDir, "file"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(deniedDir, "file"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+// TestSandboxShimHelper is run by TestSandboxShim as the sandbox shim, which
+// executes it again inside the sandbox.
+func TestSandboxShimHelper(t *testing.T) {
+	allowedDir, ok := os.LookupEnv(sandboxTestAllowedDirEnvVar)
+	if !ok {
+		t.Skip("only run by TestSandboxShim")
+	}
+	RunSandboxShim()
+
+	assert.NoError(t, os.WriteFile(filepath.Join(allowedDir, "file"), nil, 0o600))
+	err := os.WriteFile(filepath.Join(os.Getenv(sandboxTestDeniedDirEnvVar), "file"), nil, 0o600)
+	assert.True(t, errors.Is(err, os.ErrPermission), "%v", err)
+}
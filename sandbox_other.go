@@ -0,0 +1,15 @@
+//go:build !linux || !(386 || amd64 || arm || arm64 || loong64 || ppc64 || ppc64le || riscv64 || s390x)
+
+package pinentry
+
+import "errors"
+
+const sandboxSupported = false
+
+func sandboxExec(string, []string) error {
+	return errors.ErrUnsupported
+}
+
+func applySandbox([]string) error {
+	return errors.ErrUnsupported
+}
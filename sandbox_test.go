@@ -0,0 +1,33 @@
+package pinentry
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSandboxCommand(t *testing.T) {
+	shim, env, err := sandboxCommand("go", []string{"/tmp", "/var/tmp"})
+	if !sandboxSupported {
+		assert.True(t, errors.Is(err, errors.ErrUnsupported))
+		return
+	}
+	assert.NoError(t, err)
+
+	expectedShim, err := os.Executable()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedShim, shim)
+
+	expectedBinary, err := exec.LookPath("go")
+	assert.NoError(t, err)
+	expectedBinary, err = filepath.Abs(expectedBinary)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		sandboxBinaryEnvVar + "=" + expectedBinary,
+		sandboxWritablePathsEnvVar + "=/dev:/tmp:/var/tmp",
+	}, env)
+}
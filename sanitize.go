@@ -0,0 +1,34 @@
+package pinentry
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SanitizeText returns s with invalid UTF-8 replaced, tabs replaced with
+// spaces, and control characters other than newlines and Unicode bidirectional
+// formatting characters removed, so that untrusted strings, such as filenames
+// and user IDs, interpolated into a description, prompt, or title cannot spoof
+// or garble the dialog. Descriptions, prompts, and titles set by this package
+// are sanitized automatically.
+func SanitizeText(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n':
+			return r
+		case r == '\t':
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		case unicode.Is(unicode.Bidi_Control, r):
+			return -1
+		default:
+			return r
+		}
+	}, strings.ToValidUTF8(s, "�"))
+}
+
+// escapeText returns s sanitized and escaped.
+func escapeText(s string) string {
+	return escape(SanitizeText(s))
+}
@@ -0,0 +1,59 @@
+package pinentry
+
+import "fmt"
+
+// redacted replaces secrets when formatted.
+const redacted = "[redacted]"
+
+// A Secret is a secret, such as a PIN, held in a byte slice that can be
+// explicitly zeroed. Formatting a Secret always returns a redacted value.
+type Secret struct {
+	data []byte
+}
+
+// NewSecret returns a new Secret that takes ownership of data.
+func NewSecret(data []byte) *Secret {
+	return &Secret{
+		data: data,
+	}
+}
+
+// Bytes returns the secret's bytes. The returned slice is zeroed when s is
+// zeroed.
+func (s *Secret) Bytes() []byte {
+	return s.data
+}
+
+// Close zeroes s.
+func (s *Secret) Close() error {
+	s.Zero()
+	return nil
+}
+
+// Format implements fmt.Formatter.
+func (s *Secret) Format(f fmt.State, _ rune) {
+	_, _ = f.Write([]byte(redacted))
+}
+
+// Len returns the length of the secret in bytes.
+func (s *Secret) Len() int {
+	return len(s.data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (s *Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// String implements fmt.Stringer.
+func (s *Secret) String() string {
+	return redacted
+}
+
+// Zero overwrites the secret's bytes with zeros and releases them.
+func (s *Secret) Zero() {
+	for i := range s.data {
+		s.data[i] = 0
+	}
+	s.data = nil
+}
@@ -0,0 +1,27 @@
+package pinentry
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSecret(t *testing.T) {
+	data := []byte("abc")
+	s := NewSecret(data)
+	assert.Equal(t, []byte("abc"), s.Bytes())
+	assert.Equal(t, 3, s.Len())
+
+	for _, format := range []string{"%s", "%v", "%+v", "%#v", "%q", "%x"} {
+		assert.Equal(t, redacted, fmt.Sprintf(format, s), format)
+	}
+	jsonData, err := json.Marshal(struct{ PIN *Secret }{PIN: s})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"PIN":"[redacted]"}`, string(jsonData))
+
+	assert.NoError(t, s.Close())
+	assert.Equal(t, []byte{0, 0, 0}, data)
+	assert.Equal(t, 0, s.Len())
+}
@@ -0,0 +1,79 @@
+package pinentry
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+)
+
+// errSecretToolNoItem is returned by runSecretTool when secret-tool exits with
+// status 1 without reporting an error, which it does when no item matches.
+var errSecretToolNoItem = errors.New("no matching item")
+
+// A SecretServiceCache is a CacheBackend that stores passphrases with the
+// freedesktop Secret Service, for example GNOME Keyring or KWallet, with the
+// attributes application and keyinfo. It uses the secret-tool command from
+// libsecret, passing passphrases on its standard input rather than its command
+// line.
+type SecretServiceCache struct {
+	application   string
+	runSecretTool func(stdin []byte, args ...string) ([]byte, error)
+}
+
+// NewSecretServiceCache returns a new SecretServiceCache that stores
+// passphrases with the application attribute set to application.
+func NewSecretServiceCache(application string) *SecretServiceCache {
+	return &SecretServiceCache{
+		application:   application,
+		runSecretTool: runSecretTool,
+	}
+}
+
+// Get implements CacheBackend.Get.
+func (sc *SecretServiceCache) Get(keyInfo string) (*Secret, bool, error) {
+	output, err := sc.runSecretTool(nil, append([]string{"lookup"}, sc.attributes(keyInfo)...)...)
+	switch {
+	case errors.Is(err, errSecretToolNoItem):
+		return nil, false, nil
+	case err != nil:
+		return nil, false, err
+	}
+	return NewSecret(output), true, nil
+}
+
+// Invalidate implements CacheBackend.Invalidate.
+func (sc *SecretServiceCache) Invalidate(keyInfo string) error {
+	_, err := sc.runSecretTool(nil, append([]string{"clear"}, sc.attributes(keyInfo)...)...)
+	if errors.Is(err, errSecretToolNoItem) {
+		return nil
+	}
+	return err
+}
+
+// Put implements CacheBackend.Put.
+func (sc *SecretServiceCache) Put(keyInfo string, passphrase []byte) error {
+	args := append([]string{"store", "--label=Passphrase for " + keyInfo}, sc.attributes(keyInfo)...)
+	_, err := sc.runSecretTool(passphrase, args...)
+	return err
+}
+
+// attributes returns the secret-tool attributes for keyInfo.
+func (sc *SecretServiceCache) attributes(keyInfo string) []string {
+	return []string{
+		"application", sc.application,
+		"keyinfo", keyInfo,
+	}
+}
+
+// runSecretTool runs secret-tool with args and stdin and returns its standard
+// output.
+func runSecretTool(stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	output, err := cmd.Output()
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) && exitError.ExitCode() == 1 && len(exitError.Stderr) == 0 {
+		return nil, errSecretToolNoItem
+	}
+	return output, err
+}
@@ -0,0 +1,59 @@
+package pinentry
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSecretServiceCache(t *testing.T) {
+	items := make(map[string]string)
+	attributes := []string{"application", "test", "keyinfo", "n/0123"}
+	sc := &SecretServiceCache{
+		application: "test",
+		runSecretTool: func(stdin []byte, args ...string) ([]byte, error) {
+			switch args[0] {
+			case "store":
+				assert.Equal(t, append([]string{"store", "--label=Passphrase for n/0123"}, attributes...), args)
+				items["n/0123"] = string(stdin)
+				return nil, nil
+			case "lookup":
+				assert.Equal(t, append([]string{"lookup"}, attributes...), args)
+				if item, ok := items["n/0123"]; ok {
+					return []byte(item), nil
+				}
+				return nil, errSecretToolNoItem
+			case "clear":
+				assert.Equal(t, append([]string{"clear"}, attributes...), args)
+				delete(items, "n/0123")
+				return nil, nil
+			default:
+				t.Fatalf("unexpected args %q", args)
+				return nil, nil
+			}
+		},
+	}
+
+	_, ok, err := sc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, sc.Put("n/0123", []byte("abc")))
+	assert.Equal(t, map[string]string{"n/0123": "abc"}, items)
+
+	secret, ok, err := sc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("abc"), secret.Bytes())
+
+	assert.NoError(t, sc.Put("n/0123", []byte("abc\n")))
+	secret, ok, err = sc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("abc\n"), secret.Bytes())
+
+	assert.NoError(t, sc.Invalidate("n/0123"))
+	_, ok, err = sc.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,719 @@
+// Package server implements the server side of the pinentry protocol, allowing
+// Go programs to act as a pinentry.
+//
+// See info pinentry.
+// See https://www.gnupg.org/documentation/manuals/assuan.pdf.
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/internal/assuan"
+)
+
+// Assuan error codes.
+const (
+	AssuanErrorCodeGeneral        = 83886081
+	AssuanErrorCodeNotImplemented = 83886149
+	AssuanErrorCodeTimeout        = 83886142
+	AssuanErrorCodeUnknownOption  = 83886254
+	AssuanErrorCodeParameter      = 83886360
+	AssuanErrorCodeLineTooLong    = assuan.ErrorCodeLineTooLong
+	AssuanErrorCodeUnknownCommand = assuan.ErrorCodeUnknownCommand
+	AssuanErrorCodeSyntax         = 536871188
+)
+
+// Default texts used when repeating a PIN.
+const (
+	defaultRepeatPrompt = "Repeat:"
+	defaultRepeatError  = "Passphrases do not match"
+)
+
+// Errors that can be returned by funcs to send the corresponding Assuan
+// errors to the client.
+var (
+	ErrCancelled     = &pinentry.AssuanError{Code: pinentry.AssuanErrorCodeCancelled, Description: "Operation cancelled <Pinentry>"}
+	ErrNotConfirmed  = &pinentry.AssuanError{Code: pinentry.AssuanErrorCodeNotConfirmed, Description: "Not confirmed <Pinentry>"}
+	ErrUnknownOption = &pinentry.AssuanError{Code: AssuanErrorCodeUnknownOption, Description: "Unknown option <Pinentry>"}
+)
+
+// ErrNoQualityBar is returned by Quality when the client has not enabled the
+// quality bar with SETQUALITYBAR.
+var ErrNoQualityBar = errors.New("server: no quality bar")
+
+var (
+	errGetPINFunc = errors.New("server: GetPINFunc failed")
+	errNotGetPIN  = errors.New("server: Quality called outside GetPINFunc")
+
+	errNotImplemented = &assuan.Error{Code: AssuanErrorCodeNotImplemented, Description: "Not implemented"}
+	errParameter      = &assuan.Error{Code: AssuanErrorCodeParameter, Description: "IPC parameter error"}
+	errSyntax         = &assuan.Error{Code: AssuanErrorCodeSyntax, Description: "IPC syntax error - argument required"}
+)
+
+// A State is the state set by the client with SET* and OPTION commands. It is
+// passed to funcs, which must not retain it. QualityBar is set if the client
+// enabled the quality bar, in which case a GetPINFunc may call Quality. UIs
+// that cannot show a quality bar may ignore it, its label, and its tooltip, as
+// the client is only sent inquiries when Quality is called.
+// Funcs are passed a copy of the State and a context that is done when the
+// timeout set with SETTIMEOUT expires, after which the client gets an error
+// even if the func is still running.
+type State struct {
+	Title           string
+	Desc            string
+	Prompt          string
+	Error           string
+	OK              string
+	NotOK           string
+	Cancel          string
+	KeyInfo         string
+	Repeat          bool
+	RepeatPrompt    string
+	RepeatError     string
+	RepeatOK        string
+	QualityBar      bool
+	QualityBarLabel string
+	QualityBarTT    string
+	Timeout         time.Duration
+	Options         map[string]string
+}
+
+// A GetPINFunc gets a PIN from the user. If state.Repeat is set then the server
+// calls it again to ask the user to repeat the PIN, with state.Prompt set to
+// the repeat prompt and state.Repeat cleared, and calls it again with
+// state.Error set to the repeat error if the PINs do not match, so a GetPINFunc
+// only ever gets a single PIN. The server takes ownership of the returned
+// Secret and zeroes it once it has been sent to the client. The text of errors other than
+// *pinentry.AssuanErrors and context errors is not sent to the client, as it
+// may contain the PIN.
+type GetPINFunc func(ctx context.Context, state *State) (*pinentry.Secret, error)
+
+// A ConfirmFunc asks the user for confirmation. If oneButton is set then only
+// one button should be shown.
+type ConfirmFunc func(ctx context.Context, state *State, oneButton bool) (bool, error)
+
+// A MessageFunc shows the user a message.
+type MessageFunc func(ctx context.Context, state *State) error
+
+// A UI is a user interface that prompts the user. Its methods are called to
+// handle GETPIN, CONFIRM, and MESSAGE, and have the same semantics as a
+// GetPINFunc, a ConfirmFunc, and a MessageFunc respectively.
+type UI interface {
+	GetPIN(ctx context.Context, state *State) (*pinentry.Secret, error)
+	Confirm(ctx context.Context, state *State, oneButton bool) (bool, error)
+	Message(ctx context.Context, state *State) error
+}
+
+// An OptionFunc is called for each option set by the client. value is empty
+// if the option has no value. Returning ErrUnknownOption rejects the option.
+type OptionFunc func(name, value string) error
+
+// A Server is a pinentry server.
+type Server struct {
+	cache       pinentry.CacheBackend
+	cacheGate   CacheGateFunc
+	getPINFunc  GetPINFunc
+	confirmFunc ConfirmFunc
+	messageFunc MessageFunc
+	optionFunc  OptionFunc
+	flavor      string
+	logger      *slog.Logger
+	version     string
+}
+
+// An Option sets an option on a Server.
+type Option func(*Server)
+
+// A CacheGateFunc is called before a passphrase from the cache is returned,
+// for example to require the user's confirmation or a biometric check. The
+// cached passphrase is only returned if it returns true.
+type CacheGateFunc func(ctx context.Context, state *State) (bool, error)
+
+// WithCache sets the cache used for passphrases when the client allows it with
+// OPTION allow-external-password-cache and identifies the key with SETKEYINFO,
+// for example to integrate an OS keyring. GETPIN returns the cached passphrase
+// without calling the GetPINFunc, with S PASSWORD_FROM_CACHE, unless the
+// cache has already been tried in this session, an error is set, or the
+// passphrase must be repeated. Passphrases entered by the user are stored in
+// cache, and CLEARPASSPHRASE removes them. Errors from cache are ignored.
+func WithCache(cache pinentry.CacheBackend) Option {
+	return func(s *Server) {
+		s.cache = cache
+	}
+}
+
+// WithCacheGate sets the func called before a passphrase from the cache set
+// with WithCache is returned. If it returns false or an error then the cached
+// passphrase is zeroed and the GetPINFunc is called instead.
+func WithCacheGate(cacheGate CacheGateFunc) Option {
+	return func(s *Server) {
+		s.cacheGate = cacheGate
+	}
+}
+
+// WithConfirmFunc sets the func called to handle CONFIRM.
+func WithConfirmFunc(confirmFunc ConfirmFunc) Option {
+	return func(s *Server) {
+		s.confirmFunc = confirmFunc
+	}
+}
+
+// WithFlavor sets the flavor returned by GETINFO flavor.
+func WithFlavor(flavor string) Option {
+	return func(s *Server) {
+		s.flavor = flavor
+	}
+}
+
+// WithGetPINFunc sets the func called to handle GETPIN.
+func WithGetPINFunc(getPINFunc GetPINFunc) Option {
+	return func(s *Server) {
+		s.getPINFunc = getPINFunc
+	}
+}
+
+// WithLogger sets the logger used to log the commands and responses of each
+// session. PINs are redacted.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithMessageFunc sets the func called to handle MESSAGE. If no func is set
+// then MESSAGE is handled by the confirm func with one button.
+func WithMessageFunc(messageFunc MessageFunc) Option {
+	return func(s *Server) {
+		s.messageFunc = messageFunc
+	}
+}
+
+// WithOptionFunc sets the func called to handle OPTION.
+func WithOptionFunc(optionFunc OptionFunc) Option {
+	return func(s *Server) {
+		s.optionFunc = optionFunc
+	}
+}
+
+// WithUI sets the funcs called to handle GETPIN, CONFIRM, and MESSAGE to the
+// methods of ui.
+func WithUI(ui UI) Option {
+	return func(s *Server) {
+		s.getPINFunc = ui.GetPIN
+		s.confirmFunc = ui.Confirm
+		s.messageFunc = ui.Message
+	}
+}
+
+// WithVersion sets the version returned by GETINFO version.
+func WithVersion(version string) Option {
+	return func(s *Server) {
+		s.version = version
+	}
+}
+
+// New returns a new Server with the given options.
+func New(options ...Option) *Server {
+	s := &Server{
+		flavor:  "go",
+		version: "0.0.0",
+	}
+	for _, option := range options {
+		if option != nil {
+			option(s)
+		}
+	}
+	return s
+}
+
+// Serve serves a single client, reading commands from r and writing responses
+// to w, until the client sends BYE, r reaches EOF, or ctx is done. If ctx is
+// done while waiting for a command then Serve returns ctx's error immediately.
+// If r has a SetReadDeadline method, as net.Conn and *os.File do, then the
+// pending read is interrupted. Otherwise, it continues in the background until
+// r returns.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	if deadliner, ok := r.(interface{ SetReadDeadline(time.Time) error }); ok {
+		stop := context.AfterFunc(ctx, func() {
+			_ = deadliner.SetReadDeadline(time.Now())
+		})
+		defer stop()
+	}
+	sess := &session{
+		server: s,
+		ctx:    ctx,
+		conn:   assuan.NewConn(r, w, assuan.WithLogger(s.logger)),
+		state: State{
+			Options: make(map[string]string),
+		},
+	}
+	mux := assuan.NewServeMux(errorResponse)
+	for keyword, handler := range commandHandlers {
+		handler := handler
+		mux.Handle(keyword, func(args string) error {
+			return handler(sess, args)
+		})
+	}
+	return mux.Serve(ctx, sess.conn, "Pleased to meet you")
+}
+
+// ServeListener accepts connections from listener and serves them one at a
+// time, so prompts from multiple clients are never shown at the same time,
+// until ctx is done, when listener is closed and ctx's error is returned.
+// Errors from individual sessions are ignored.
+func (s *Server) ServeListener(ctx context.Context, listener net.Listener) error {
+	stop := context.AfterFunc(ctx, func() {
+		_ = listener.Close()
+	})
+	defer stop()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+		_ = s.Serve(ctx, conn, conn)
+		_ = conn.Close()
+	}
+}
+
+// A session is the state of a single connection.
+type session struct {
+	server *Server
+	ctx    context.Context
+	conn   *assuan.Conn
+	state  State
+
+	inquiryMu sync.Mutex
+	inGetPIN  bool
+
+	triedCache bool
+}
+
+// A qualityContextKey is the context key for the session whose GETPIN is in
+// progress.
+type qualityContextKey struct{}
+
+// Quality asks the client for the quality of pin, for UIs that update a
+// quality bar as the user types. ctx must be the context passed to a
+// GetPINFunc, which may call Quality from any goroutine until it returns. The
+// quality is a percentage between -100 and 100, where negative values indicate
+// that pin is not acceptable. If the client has not enabled the quality bar
+// then ErrNoQualityBar is returned. If the client cancels the inquiry then
+// ErrCancelled is returned.
+func Quality(ctx context.Context, pin []byte) (int, error) {
+	sess, ok := ctx.Value(qualityContextKey{}).(*session)
+	if !ok {
+		return 0, errNotGetPIN
+	}
+	return sess.quality(pin)
+}
+
+// A commandHandler handles a command with the unescaped arguments args.
+type commandHandler func(sess *session, args string) error
+
+// commandHandlers maps commands to their handlers.
+var commandHandlers = map[string]commandHandler{
+	"CLEARPASSPHRASE":  (*session).clearPassphrase,
+	"CONFIRM":          (*session).confirm,
+	"GETINFO":          (*session).getInfo,
+	"GETPIN":           (*session).getPIN,
+	"MESSAGE":          (*session).message,
+	"NOP":              (*session).ok,
+	"OPTION":           (*session).option,
+	"RESET":            (*session).reset,
+	"SETCANCEL":        setText(func(state *State) *string { return &state.Cancel }),
+	"SETDESC":          setText(func(state *State) *string { return &state.Desc }),
+	"SETERROR":         setText(func(state *State) *string { return &state.Error }),
+	"SETKEYINFO":       setText(func(state *State) *string { return &state.KeyInfo }),
+	"SETNOTOK":         setText(func(state *State) *string { return &state.NotOK }),
+	"SETOK":            setText(func(state *State) *string { return &state.OK }),
+	"SETPROMPT":        setText(func(state *State) *string { return &state.Prompt }),
+	"SETQUALITYBAR":    (*session).setQualityBar,
+	"SETQUALITYBAR_TT": (*session).setQualityBarTT,
+	"SETREPEAT":        (*session).setRepeat,
+	"SETREPEATERROR":   setText(func(state *State) *string { return &state.RepeatError }),
+	"SETREPEATOK":      setText(func(state *State) *string { return &state.RepeatOK }),
+	"SETTIMEOUT":       (*session).setTimeout,
+	"SETTITLE":         setText(func(state *State) *string { return &state.Title }),
+}
+
+// clearPassphrase handles CLEARPASSPHRASE.
+func (sess *session) clearPassphrase(args string) error {
+	if keyInfo := strings.TrimSpace(args); sess.server.cache != nil && keyInfo != "" {
+		_ = sess.server.cache.Invalidate(keyInfo)
+	}
+	return nil
+}
+
+// confirm handles CONFIRM.
+func (sess *session) confirm(args string) error {
+	if sess.server.confirmFunc == nil {
+		return errNotImplemented
+	}
+	oneButton := strings.TrimSpace(args) == "--one-button"
+	state := sess.state
+	sess.state.Error = ""
+	confirmed, err := callFunc(sess, state, func(ctx context.Context, state *State) (bool, error) {
+		return sess.server.confirmFunc(ctx, state, oneButton)
+	})
+	switch {
+	case err != nil:
+		return err
+	case !confirmed:
+		return ErrNotConfirmed
+	default:
+		return nil
+	}
+}
+
+// getInfo handles GETINFO.
+func (sess *session) getInfo(args string) error {
+	var data string
+	switch strings.TrimSpace(args) {
+	case "flavor":
+		data = sess.server.flavor
+	case "pid":
+		data = strconv.Itoa(os.Getpid())
+	case "ttyinfo":
+		fields := make([]string, 0, 3)
+		for _, option := range []string{"ttyname", "ttytype", "display"} {
+			value := sess.state.Options[option]
+			if value == "" {
+				value = "-"
+			}
+			fields = append(fields, value)
+		}
+		data = strings.Join(fields, " ")
+	case "version":
+		data = sess.server.version
+	default:
+		return errParameter
+	}
+	return sess.conn.WriteData([]byte(data))
+}
+
+// getPIN handles GETPIN.
+func (sess *session) getPIN(string) error {
+	if secret, ok := sess.cachedPIN(); ok {
+		defer secret.Zero()
+		if err := sess.conn.WriteLine("S PASSWORD_FROM_CACHE"); err != nil {
+			return err
+		}
+		return sess.conn.WriteData(secret.Bytes())
+	}
+	if sess.server.getPINFunc == nil {
+		return errNotImplemented
+	}
+	sess.setInGetPIN(true)
+	pin, err := sess.getPINRepeated()
+	sess.setInGetPIN(false)
+	if err != nil {
+		// The text of other errors is not sent as it may contain the PIN.
+		var assuanError *pinentry.AssuanError
+		if !errors.As(err, &assuanError) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			err = errGetPINFunc
+		}
+		return err
+	}
+	defer pin.Zero()
+	if sess.cacheAllowed() {
+		_ = sess.server.cache.Put(sess.state.KeyInfo, pin.Bytes())
+	}
+	if sess.state.Repeat {
+		if err := sess.conn.WriteLine("S PIN_REPEATED"); err != nil {
+			return err
+		}
+	}
+	return sess.conn.WriteData(pin.Bytes())
+}
+
+// getPINRepeated calls the GetPINFunc, and, if the client set SETREPEAT, calls
+// it again until the user repeats the same PIN. PINs that are not returned are
+// zeroed.
+func (sess *session) getPINRepeated() (*pinentry.Secret, error) {
+	state := sess.state
+	sess.state.Error = ""
+	for {
+		pin, err := sess.callGetPINFunc(state)
+		if err != nil || !state.Repeat {
+			return pin, err
+		}
+		repeatState := state
+		repeatState.Prompt = orDefault(state.RepeatPrompt, defaultRepeatPrompt)
+		repeatState.Error = ""
+		repeatState.Repeat = false
+		repeatState.QualityBar = false
+		repeat, err := sess.callGetPINFunc(repeatState)
+		if err != nil {
+			pin.Zero()
+			return nil, err
+		}
+		equal := bytes.Equal(repeat.Bytes(), pin.Bytes())
+		repeat.Zero()
+		if equal {
+			return pin, nil
+		}
+		pin.Zero()
+		state.Error = orDefault(state.RepeatError, defaultRepeatError)
+	}
+}
+
+// callGetPINFunc calls the GetPINFunc with state. The returned PIN is never nil
+// if err is nil. PINs returned after the timeout expires are zeroed.
+func (sess *session) callGetPINFunc(state State) (*pinentry.Secret, error) {
+	return callFunc(sess, state, func(ctx context.Context, state *State) (*pinentry.Secret, error) {
+		pin, err := sess.server.getPINFunc(context.WithValue(ctx, qualityContextKey{}, sess), state)
+		switch {
+		case err != nil:
+			return nil, err
+		case pin == nil:
+			return pinentry.NewSecret(nil), nil
+		case ctx.Err() != nil:
+			pin.Zero()
+			return nil, ctx.Err()
+		default:
+			return pin, nil
+		}
+	})
+}
+
+// message handles MESSAGE.
+func (sess *session) message(string) error {
+	if sess.server.messageFunc == nil {
+		if sess.server.confirmFunc == nil {
+			return errNotImplemented
+		}
+		return sess.confirm("--one-button")
+	}
+	state := sess.state
+	sess.state.Error = ""
+	_, err := callFunc(sess, state, func(ctx context.Context, state *State) (struct{}, error) {
+		return struct{}{}, sess.server.messageFunc(ctx, state)
+	})
+	return err
+}
+
+// quality sends INQUIRE QUALITY for pin and returns the client's response.
+func (sess *session) quality(pin []byte) (int, error) {
+	sess.inquiryMu.Lock()
+	defer sess.inquiryMu.Unlock()
+	switch {
+	case !sess.inGetPIN:
+		return 0, errNotGetPIN
+	case !sess.state.QualityBar:
+		return 0, ErrNoQualityBar
+	}
+	line := assuan.AppendEscape([]byte("INQUIRE QUALITY "), pin)
+	line = append(line, '\n')
+	err := sess.conn.WriteSecretLine(line)
+	for i := range line {
+		line[i] = 0
+	}
+	if err != nil {
+		return 0, err
+	}
+	var data strings.Builder
+	for {
+		line, err := sess.conn.ReadLine(sess.ctx)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case strings.HasPrefix(line, "D "):
+			data.WriteString(assuan.Unescape(line[2:]))
+		case line == "END":
+			quality, err := strconv.Atoi(strings.TrimSpace(data.String()))
+			if err != nil {
+				return 0, err
+			}
+			if quality < -100 {
+				quality = -100
+			} else if quality > 100 {
+				quality = 100
+			}
+			return quality, nil
+		case line == "CAN":
+			return 0, ErrCancelled
+		}
+	}
+}
+
+// setInGetPIN records whether a GetPINFunc is running, waiting for any
+// inquiry in progress to complete.
+func (sess *session) setInGetPIN(inGetPIN bool) {
+	sess.inquiryMu.Lock()
+	defer sess.inquiryMu.Unlock()
+	sess.inGetPIN = inGetPIN
+}
+
+// ok handles commands that are accepted and ignored.
+func (sess *session) ok(string) error {
+	return nil
+}
+
+// option handles OPTION.
+func (sess *session) option(args string) error {
+	args = strings.TrimPrefix(strings.TrimSpace(args), "--")
+	if args == "" {
+		return errSyntax
+	}
+	name, value, ok := strings.Cut(args, "=")
+	if !ok {
+		name, value, _ = strings.Cut(args, " ")
+	}
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if sess.server.optionFunc != nil {
+		if err := sess.server.optionFunc(name, value); err != nil {
+			return err
+		}
+	}
+	sess.state.Options[name] = value
+	return nil
+}
+
+// reset handles RESET. Options are preserved.
+func (sess *session) reset(string) error {
+	sess.state = State{
+		Options: sess.state.Options,
+	}
+	return nil
+}
+
+// setQualityBar handles SETQUALITYBAR.
+func (sess *session) setQualityBar(args string) error {
+	sess.state.QualityBar = true
+	sess.state.QualityBarLabel = args
+	return nil
+}
+
+// setQualityBarTT handles SETQUALITYBAR_TT.
+func (sess *session) setQualityBarTT(args string) error {
+	sess.state.QualityBarTT = args
+	return nil
+}
+
+// setRepeat handles SETREPEAT.
+func (sess *session) setRepeat(args string) error {
+	sess.state.Repeat = true
+	sess.state.RepeatPrompt = args
+	return nil
+}
+
+// setTimeout handles SETTIMEOUT.
+func (sess *session) setTimeout(args string) error {
+	seconds, err := strconv.Atoi(strings.TrimSpace(args))
+	if err != nil || seconds < 0 {
+		return errParameter
+	}
+	sess.state.Timeout = time.Duration(seconds) * time.Second
+	return nil
+}
+
+// setText returns a commandHandler that sets the text returned by field to its
+// arguments.
+func setText(field func(*State) *string) commandHandler {
+	return func(sess *session, args string) error {
+		*field(&sess.state) = args
+		return nil
+	}
+}
+
+// cacheAllowed returns if the client allows the passphrase to be cached.
+func (sess *session) cacheAllowed() bool {
+	if sess.server.cache == nil || sess.state.KeyInfo == "" {
+		return false
+	}
+	_, ok := sess.state.Options[pinentry.OptionAllowExternalPasswordCache]
+	return ok
+}
+
+// cachedPIN returns the cached passphrase, if it should be used.
+func (sess *session) cachedPIN() (*pinentry.Secret, bool) {
+	if !sess.cacheAllowed() || sess.triedCache || sess.state.Error != "" || sess.state.Repeat {
+		return nil, false
+	}
+	sess.triedCache = true
+	secret, ok, err := sess.server.cache.Get(sess.state.KeyInfo)
+	if err != nil || !ok {
+		return nil, false
+	}
+	if sess.server.cacheGate != nil {
+		if ok, err := callFunc(sess, sess.state, sess.server.cacheGate); err != nil || !ok {
+			secret.Zero()
+			return nil, false
+		}
+	}
+	return secret, true
+}
+
+// callFunc calls f with a copy of state and a context that is done when the
+// timeout set with SETTIMEOUT expires. If the context is done before f returns
+// then its error is returned without waiting for f, so the timeout is enforced
+// even if f ignores its context.
+func callFunc[T any](sess *session, state State, f func(context.Context, *State) (T, error)) (T, error) {
+	ctx, cancel := context.WithCancel(sess.ctx)
+	if sess.state.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(sess.ctx, sess.state.Timeout)
+	}
+	defer cancel()
+	options := state.Options
+	state.Options = make(map[string]string, len(options))
+	for name, value := range options {
+		state.Options[name] = value
+	}
+	type resultErr struct {
+		result T
+		err    error
+	}
+	resultErrCh := make(chan resultErr, 1)
+	go func() {
+		result, err := f(ctx, &state)
+		resultErrCh <- resultErr{result: result, err: err}
+	}()
+	select {
+	case resultErr := <-resultErrCh:
+		return resultErr.result, resultErr.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// errorResponse returns the error response for err, returned by a command
+// handler.
+func errorResponse(err error) *assuan.Error {
+	var assuanError *pinentry.AssuanError
+	switch {
+	case errors.As(err, &assuanError):
+		return &assuan.Error{Code: assuanError.Code, Description: assuanError.Description}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &assuan.Error{Code: AssuanErrorCodeTimeout, Description: "Timeout <Pinentry>"}
+	case errors.Is(err, context.Canceled):
+		return &assuan.Error{Code: pinentry.AssuanErrorCodeCancelled, Description: "Operation cancelled <Pinentry>"}
+	default:
+		return &assuan.Error{Code: AssuanErrorCodeGeneral, Description: err.Error() + " <Pinentry>"}
+	}
+}
+
+// orDefault returns s, or defaultS if s is empty.
+func orDefault(s, defaultS string) string {
+	if s == "" {
+		return defaultS
+	}
+	return s
+}
@@ -0,0 +1,658 @@
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/twpayne/go-pinentry/v4"
+	"github.com/twpayne/go-pinentry/v4/server"
+)
+
+// A pipeProcess is a pinentry.Process that is served by a Server.
+type pipeProcess struct {
+	server *server.Server
+	reader *bufio.Reader
+	writer *io.PipeWriter
+	errCh  chan error
+}
+
+func (p *pipeProcess) Close() error {
+	if err := p.writer.Close(); err != nil {
+		return err
+	}
+	return <-p.errCh
+}
+
+func (p *pipeProcess) ReadLine() ([]byte, bool, error) {
+	return p.reader.ReadLine()
+}
+
+func (p *pipeProcess) Start(string, []string) error {
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	p.reader = bufio.NewReader(clientReader)
+	p.writer = clientWriter
+	p.errCh = make(chan error, 1)
+	go func() {
+		err := p.server.Serve(context.Background(), serverReader, serverWriter)
+		p.errCh <- err
+		_ = serverWriter.Close()
+	}()
+	return nil
+}
+
+func (p *pipeProcess) Write(data []byte) (int, error) {
+	return p.writer.Write(data)
+}
+
+func newClient(t *testing.T, s *server.Server, options ...pinentry.ClientOption) *pinentry.Client {
+	t.Helper()
+	c, err := pinentry.NewClient(append([]pinentry.ClientOption{
+		pinentry.WithProcess(&pipeProcess{server: s}),
+	}, options...)...)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+	return c
+}
+
+func TestServerGetPIN(t *testing.T) {
+	var actualStates []server.State
+	s := server.New(
+		server.WithGetPINFunc(func(_ context.Context, state *server.State) (*pinentry.Secret, error) {
+			actualStates = append(actualStates, *state)
+			return pinentry.NewSecret([]byte("abc%\n")), nil
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithDesc("desc\nline"),
+		pinentry.WithError("error"),
+		pinentry.WithKeyInfo("n/0123"),
+		pinentry.WithOption("ttyname=/dev/pts/1"),
+		pinentry.WithPrompt("prompt"),
+		pinentry.WithRepeat("repeat"),
+		pinentry.WithTitle("title"),
+	)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc%\n", result.PIN)
+	assert.True(t, result.PINRepeated)
+	assert.Equal(t, []server.State{
+		{
+			Title:        "title",
+			Desc:         "desc\nline",
+			Prompt:       "prompt",
+			Error:        "error",
+			KeyInfo:      "n/0123",
+			Repeat:       true,
+			RepeatPrompt: "repeat",
+			Options: map[string]string{
+				"ttyname": "/dev/pts/1",
+			},
+		},
+		{
+			Title:        "title",
+			Desc:         "desc\nline",
+			Prompt:       "repeat",
+			KeyInfo:      "n/0123",
+			RepeatPrompt: "repeat",
+			Options: map[string]string{
+				"ttyname": "/dev/pts/1",
+			},
+		},
+	}, actualStates)
+
+	actualStates = nil
+	_, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(actualStates))
+	assert.Equal(t, "", actualStates[0].Error)
+}
+
+func TestServerGetPINZero(t *testing.T) {
+	pin := []byte("abc")
+	s := server.New(
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			return pinentry.NewSecret(pin), nil
+		}),
+	)
+	c := newClient(t, s)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.Equal(t, []byte{0, 0, 0}, pin)
+}
+
+// A lockedBuffer is a bytes.Buffer that is safe for concurrent use.
+type lockedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
+
+func (b *lockedBuffer) Write(data []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(data)
+}
+
+func TestServerLogger(t *testing.T) {
+	var log lockedBuffer
+	s := server.New(
+		server.WithGetPINFunc(func(ctx context.Context, _ *server.State) (*pinentry.Secret, error) {
+			if _, err := server.Quality(ctx, []byte("s3cr3t")); err != nil {
+				return nil, err
+			}
+			return pinentry.NewSecret([]byte("s3cr3t")), nil
+		}),
+		server.WithLogger(slog.New(slog.NewTextHandler(&log, nil))),
+	)
+	c := newClient(t, s,
+		pinentry.WithQualityBar(func(string) (int, bool) {
+			return 50, true
+		}),
+	)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", result.PIN)
+	assert.Contains(t, log.String(), "line=GETPIN")
+	assert.Contains(t, log.String(), `line="INQUIRE QUALITY [REDACTED]"`)
+	assert.Contains(t, log.String(), `line="D [REDACTED]"`)
+	assert.NotContains(t, log.String(), "s3cr3t")
+}
+
+func TestServerGetPINError(t *testing.T) {
+	s := server.New(
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			return nil, errors.New("invalid PIN: secret")
+		}),
+	)
+	c := newClient(t, s)
+
+	_, err := c.GetPIN()
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeGeneral, assuanError.Code)
+	assert.NotContains(t, assuanError.Description, "secret")
+}
+
+func TestServerGetPINRepeatMismatch(t *testing.T) {
+	var actualStates []server.State
+	pins := []string{"abc", "abd", "abc", "abc"}
+	s := server.New(
+		server.WithGetPINFunc(func(_ context.Context, state *server.State) (*pinentry.Secret, error) {
+			actualStates = append(actualStates, *state)
+			pin := pins[0]
+			pins = pins[1:]
+			return pinentry.NewSecret([]byte(pin)), nil
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithRepeat(""),
+	)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.True(t, result.PINRepeated)
+	assert.Equal(t, 4, len(actualStates))
+	assert.Equal(t, "", actualStates[0].Error)
+	assert.Equal(t, "Repeat:", actualStates[1].Prompt)
+	assert.False(t, actualStates[1].Repeat)
+	assert.Equal(t, "Passphrases do not match", actualStates[2].Error)
+	assert.True(t, actualStates[2].Repeat)
+	assert.Equal(t, "", actualStates[3].Error)
+}
+
+func TestServerGetPINCancelled(t *testing.T) {
+	s := server.New(
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			return nil, server.ErrCancelled
+		}),
+	)
+	c := newClient(t, s)
+
+	_, err := c.GetPIN()
+	assert.True(t, pinentry.IsCancelled(err))
+}
+
+func TestServerGetPINTimeout(t *testing.T) {
+	s := server.New(
+		server.WithGetPINFunc(func(ctx context.Context, state *server.State) (*pinentry.Secret, error) {
+			assert.Equal(t, time.Second, state.Timeout)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithTimeout(time.Second),
+	)
+
+	_, err := c.GetPIN()
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeTimeout, assuanError.Code)
+}
+
+func TestServerGetPINTimeoutIgnored(t *testing.T) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+	s := server.New(
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			<-doneCh
+			return pinentry.NewSecret([]byte("abc")), nil
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithTimeout(time.Second),
+	)
+
+	_, err := c.GetPIN()
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeTimeout, assuanError.Code)
+}
+
+func TestServerConfirm(t *testing.T) {
+	var actualOneButton bool
+	confirm := true
+	s := server.New(
+		server.WithConfirmFunc(func(_ context.Context, _ *server.State, oneButton bool) (bool, error) {
+			actualOneButton = oneButton
+			return confirm, nil
+		}),
+	)
+	c := newClient(t, s)
+
+	confirmed, err := c.Confirm("")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+	assert.False(t, actualOneButton)
+
+	assert.NoError(t, c.Message())
+	assert.True(t, actualOneButton)
+
+	confirm = false
+	_, err = c.Confirm("")
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, pinentry.AssuanErrorCodeNotConfirmed, assuanError.Code)
+}
+
+func TestServerGetInfo(t *testing.T) {
+	s := server.New(
+		server.WithFlavor("test"),
+		server.WithVersion("1.2.3"),
+	)
+	c := newClient(t, s,
+		pinentry.WithOption("ttytype=xterm"),
+	)
+
+	flavor, err := c.Flavor()
+	assert.NoError(t, err)
+	assert.Equal(t, "test", flavor)
+
+	version, err := c.Version()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3", version)
+
+	ttyInfo, err := c.TTYInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, pinentry.TTYInfo{TTYType: "xterm"}, ttyInfo)
+
+	pid, err := c.PID()
+	assert.NoError(t, err)
+	assert.NotZero(t, pid)
+}
+
+func TestServerOption(t *testing.T) {
+	s := server.New(
+		server.WithOptionFunc(func(name, _ string) error {
+			if name == "no-grab" {
+				return server.ErrUnknownOption
+			}
+			return nil
+		}),
+	)
+	_, err := pinentry.NewClient(
+		pinentry.WithOption("no-grab"),
+		pinentry.WithProcess(&pipeProcess{server: s}),
+	)
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeUnknownOption, assuanError.Code)
+}
+
+func TestServerUnknownCommand(t *testing.T) {
+	c := newClient(t, server.New())
+
+	assert.NoError(t, c.WriteCommand("FOO"))
+	_, err := c.ReadResponse()
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeUnknownCommand, assuanError.Code)
+
+	_, err = c.GetPIN()
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, server.AssuanErrorCodeNotImplemented, assuanError.Code)
+}
+
+func TestServerLineTooLong(t *testing.T) {
+	var output strings.Builder
+	s := server.New()
+	assert.NoError(t, s.Serve(context.Background(), strings.NewReader(""+
+		"SETDESC "+strings.Repeat("a", 5000)+"\n"+
+		"NOP "+strings.Repeat("a", 995)+"\n"+
+		"NOP "+strings.Repeat("a", 996)+"\n"+
+		"NOP\n",
+	), &output))
+	lineTooLong := "ERR " + strconv.Itoa(server.AssuanErrorCodeLineTooLong) + " Line too long\n"
+	assert.Equal(t, ""+
+		"OK Pleased to meet you\n"+
+		lineTooLong+
+		"OK\n"+
+		lineTooLong+
+		"OK\n",
+		output.String())
+}
+
+func TestServerContext(t *testing.T) {
+	serverReader, clientWriter := io.Pipe()
+	defer clientWriter.Close() //nolint:errcheck
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := server.New().Serve(ctx, serverReader, io.Discard)
+	assert.IsError(t, err, context.DeadlineExceeded)
+}
+
+// A testUI is a server.UI that records its calls.
+type testUI struct {
+	calls []string
+}
+
+func (ui *testUI) GetPIN(context.Context, *server.State) (*pinentry.Secret, error) {
+	ui.calls = append(ui.calls, "GetPIN")
+	return pinentry.NewSecret([]byte("abc")), nil
+}
+
+func (ui *testUI) Confirm(_ context.Context, _ *server.State, oneButton bool) (bool, error) {
+	ui.calls = append(ui.calls, "Confirm "+strconv.FormatBool(oneButton))
+	return true, nil
+}
+
+func (ui *testUI) Message(context.Context, *server.State) error {
+	ui.calls = append(ui.calls, "Message")
+	return nil
+}
+
+func TestServerListener(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := server.New(
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			return pinentry.NewSecret([]byte("abc")), nil
+		}),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- s.ServeListener(ctx, listener)
+	}()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		assert.NoError(t, err)
+		_, err = io.WriteString(conn, "GETPIN\nBYE\n")
+		assert.NoError(t, err)
+		output, err := io.ReadAll(conn)
+		assert.NoError(t, err)
+		assert.Equal(t, ""+
+			"OK Pleased to meet you\n"+
+			"D abc\n"+
+			"OK\n"+
+			"OK closing connection\n",
+			string(output))
+		assert.NoError(t, conn.Close())
+	}
+
+	cancel()
+	assert.IsError(t, <-errCh, context.Canceled)
+}
+
+func TestServerUI(t *testing.T) {
+	ui := &testUI{}
+	c := newClient(t, server.New(server.WithUI(ui)))
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+
+	confirmed, err := c.Confirm("")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+
+	assert.NoError(t, c.Message())
+
+	assert.Equal(t, []string{"GetPIN", "Confirm false", "Message"}, ui.calls)
+}
+
+func TestServerQuality(t *testing.T) {
+	var qualities []int
+	s := server.New(
+		server.WithGetPINFunc(func(ctx context.Context, _ *server.State) (*pinentry.Secret, error) {
+			for _, pin := range []string{"a", "ab%"} {
+				quality, err := server.Quality(ctx, []byte(pin))
+				if err != nil {
+					return nil, err
+				}
+				qualities = append(qualities, quality)
+			}
+			return pinentry.NewSecret([]byte("ab%")), nil
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithQualityBar(func(pin string) (int, bool) {
+			return 1000 * len(pin), true
+		}),
+	)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "ab%", result.PIN)
+	assert.Equal(t, []int{100, 100}, qualities)
+}
+
+func TestServerQualityNoQualityBar(t *testing.T) {
+	s := server.New(
+		server.WithGetPINFunc(func(ctx context.Context, _ *server.State) (*pinentry.Secret, error) {
+			_, err := server.Quality(ctx, []byte("a"))
+			return nil, err
+		}),
+	)
+	c := newClient(t, s)
+
+	_, err := c.GetPIN()
+	assert.Error(t, err)
+
+	_, err = server.Quality(context.Background(), []byte("a"))
+	assert.Error(t, err)
+}
+
+func TestServerQualityBarIgnored(t *testing.T) {
+	var states []server.State
+	s := server.New(
+		server.WithGetPINFunc(func(_ context.Context, state *server.State) (*pinentry.Secret, error) {
+			states = append(states, *state)
+			return pinentry.NewSecret([]byte("abc")), nil
+		}),
+	)
+	qualityFuncCalled := false
+	c := newClient(t, s,
+		pinentry.WithQualityBar(func(string) (int, bool) {
+			qualityFuncCalled = true
+			return 0, false
+		}),
+		pinentry.WithQualityBarToolTip("Entropy of the passphrase"),
+	)
+
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", result.PIN)
+	assert.False(t, qualityFuncCalled)
+	assert.Equal(t, 1, len(states))
+	assert.True(t, states[0].QualityBar)
+	assert.Equal(t, "Entropy of the passphrase", states[0].QualityBarTT)
+}
+
+func TestServerConfirmThreeButtons(t *testing.T) {
+	var button string
+	var actualState server.State
+	s := server.New(
+		server.WithConfirmFunc(func(_ context.Context, state *server.State, _ bool) (bool, error) {
+			actualState = *state
+			switch button {
+			case "ok":
+				return true, nil
+			case "notok":
+				return false, nil
+			default:
+				return false, server.ErrCancelled
+			}
+		}),
+	)
+	c := newClient(t, s,
+		pinentry.WithOK("_Yes"),
+		pinentry.WithNotOK("_No"),
+		pinentry.WithCancel("_Cancel"),
+	)
+
+	button = "ok"
+	confirmed, err := c.Confirm("")
+	assert.NoError(t, err)
+	assert.True(t, confirmed)
+	assert.Equal(t, "_Yes", actualState.OK)
+	assert.Equal(t, "_No", actualState.NotOK)
+	assert.Equal(t, "_Cancel", actualState.Cancel)
+
+	button = "notok"
+	confirmed, err = c.Confirm("")
+	var assuanError *pinentry.AssuanError
+	assert.True(t, errors.As(err, &assuanError))
+	assert.Equal(t, pinentry.AssuanErrorCodeNotConfirmed, assuanError.Code)
+	assert.False(t, confirmed)
+
+	button = "cancel"
+	_, err = c.Confirm("")
+	assert.True(t, pinentry.IsCancelled(err))
+
+	button = "ok"
+	assert.NoError(t, c.ConfirmOneButton())
+}
+
+func TestServerCache(t *testing.T) {
+	cache := pinentry.NewPassphraseCache(time.Minute)
+	defer cache.InvalidateAll()
+	assert.NoError(t, cache.Put("n/0123", []byte("cached")))
+	getPINs := 0
+	s := server.New(
+		server.WithCache(cache),
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			getPINs++
+			return pinentry.NewSecret([]byte("entered")), nil
+		}),
+	)
+
+	c := newClient(t, s,
+		pinentry.WithKeyInfo("n/0123"),
+	)
+	result, err := c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "entered", result.PIN)
+	assert.False(t, result.PasswordFromCache)
+	assert.Equal(t, 1, getPINs)
+
+	c = newClient(t, s,
+		pinentry.WithKeyInfo("n/0123"),
+		pinentry.WithOption(pinentry.OptionAllowExternalPasswordCache),
+	)
+	result, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "cached", result.PIN)
+	assert.True(t, result.PasswordFromCache)
+	assert.Equal(t, 1, getPINs)
+
+	result, err = c.GetPIN()
+	assert.NoError(t, err)
+	assert.Equal(t, "entered", result.PIN)
+	assert.False(t, result.PasswordFromCache)
+	assert.Equal(t, 2, getPINs)
+	secret, ok, err := cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("entered"), secret.Bytes())
+
+	assert.NoError(t, c.ClearPassphrase("n/0123"))
+	_, ok, err = cache.Get("n/0123")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestServerCacheGate(t *testing.T) {
+	cache := pinentry.NewPassphraseCache(time.Minute)
+	defer cache.InvalidateAll()
+	assert.NoError(t, cache.Put("n/0123", []byte("cached")))
+	var gates []string
+	allow := false
+	s := server.New(
+		server.WithCache(cache),
+		server.WithCacheGate(func(_ context.Context, state *server.State) (bool, error) {
+			gates = append(gates, state.KeyInfo)
+			return allow, nil
+		}),
+		server.WithGetPINFunc(func(context.Context, *server.State) (*pinentry.Secret, error) {
+			return pinentry.NewSecret([]byte("entered")), nil
+		}),
+	)
+
+	for _, tc := range []struct {
+		allow                     bool
+		expectedPIN               string
+		expectedPasswordFromCache bool
+	}{
+		{allow: false, expectedPIN: "entered"},
+		{allow: true, expectedPIN: "entered", expectedPasswordFromCache: true},
+	} {
+		allow = tc.allow
+		c := newClient(t, s,
+			pinentry.WithKeyInfo("n/0123"),
+			pinentry.WithOption(pinentry.OptionAllowExternalPasswordCache),
+		)
+		result, err := c.GetPIN()
+		assert.NoError(t, err)
+		assert.Equal(t, tc.expectedPIN, result.PIN)
+		assert.Equal(t, tc.expectedPasswordFromCache, result.PasswordFromCache)
+	}
+	assert.Equal(t, []string{"n/0123", "n/0123"}, gates)
+}
@@ -0,0 +1,84 @@
+package pinentry
+
+// The Set* methods change texts on a running client, for example to show an
+// error between prompts. They are equivalent to the corresponding With*
+// options.
+
+// SetCancel sets the cancel button label.
+func (c *Client) SetCancel(cancel string) error {
+	return c.lockedCommand("SETCANCEL " + escape(cancel))
+}
+
+// SetDesc sets the description.
+func (c *Client) SetDesc(desc string) error {
+	return c.lockedCommand("SETDESC " + escapeText(desc))
+}
+
+// SetError sets the error message.
+func (c *Client) SetError(err string) error {
+	return c.lockedCommand("SETERROR " + escape(err))
+}
+
+// SetGenPIN sets the generate PIN button label.
+func (c *Client) SetGenPIN(genPIN string) error {
+	return c.lockedCommand("SETGENPIN " + escape(genPIN))
+}
+
+// SetGenPINToolTip sets the generate PIN button tooltip.
+func (c *Client) SetGenPINToolTip(genPINTT string) error {
+	return c.lockedCommand("SETGENPIN_TT " + escape(genPINTT))
+}
+
+// SetKeyInfo sets the key information, used by pinentry to identify the
+// passphrase in an external password cache.
+func (c *Client) SetKeyInfo(keyInfo string) error {
+	if err := c.tryLock(); err != nil {
+		return err
+	}
+	defer c.mu.Unlock()
+	if err := c.command("SETKEYINFO " + escape(keyInfo)); err != nil {
+		return err
+	}
+	c.keyInfo = keyInfo
+	return nil
+}
+
+// SetNotOK sets the not OK button label.
+func (c *Client) SetNotOK(notOK string) error {
+	return c.lockedCommand("SETNOTOK " + escape(notOK))
+}
+
+// SetOK sets the OK button label.
+func (c *Client) SetOK(ok string) error {
+	return c.lockedCommand("SETOK " + escape(ok))
+}
+
+// SetPrompt sets the prompt.
+func (c *Client) SetPrompt(prompt string) error {
+	return c.lockedCommand("SETPROMPT " + escapeText(prompt))
+}
+
+// SetQualityBarToolTip sets the quality bar tooltip.
+func (c *Client) SetQualityBarToolTip(qualityBarTT string) error {
+	return c.lockedCommand("SETQUALITYBAR_TT " + escape(qualityBarTT))
+}
+
+// SetRepeat sets the repeat passphrase prompt, asking the user to enter the passphrase twice.
+func (c *Client) SetRepeat(repeat string) error {
+	return c.lockedCommand("SETREPEAT " + escape(repeat))
+}
+
+// SetRepeatError sets the error shown when the repeated passphrase does not match.
+func (c *Client) SetRepeatError(repeatError string) error {
+	return c.lockedCommand("SETREPEATERROR " + escape(repeatError))
+}
+
+// SetRepeatOK sets the message shown when the repeated passphrase matches.
+func (c *Client) SetRepeatOK(repeatOK string) error {
+	return c.lockedCommand("SETREPEATOK " + escape(repeatOK))
+}
+
+// SetTitle sets the title.
+func (c *Client) SetTitle(title string) error {
+	return c.lockedCommand("SETTITLE " + escapeText(title))
+}
@@ -0,0 +1,87 @@
+package pinentry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A SmartcardPIN identifies which of a smartcard's PINs is requested.
+type SmartcardPIN int
+
+// Smartcard PINs.
+const (
+	SmartcardUserPIN SmartcardPIN = iota
+	SmartcardSigningPIN
+	SmartcardAdminPIN
+	SmartcardResetCode
+)
+
+// A SmartcardPrompt describes a request for a smartcard PIN.
+type SmartcardPrompt struct {
+	PIN          SmartcardPIN
+	SerialNumber string
+	Holder       string
+	// SignatureCounter is the number of signatures made with the card. It is
+	// only shown for SmartcardSigningPIN.
+	SignatureCounter int
+	// RemainingAttempts is the number of attempts remaining before the PIN is
+	// blocked. It is only shown if it is positive.
+	RemainingAttempts int
+}
+
+// Desc returns the description for p, using the same wording as GnuPG.
+func (p SmartcardPrompt) Desc() string {
+	var sb strings.Builder
+	switch p.PIN {
+	case SmartcardAdminPIN:
+		sb.WriteString("Please enter the Admin PIN")
+	case SmartcardResetCode:
+		sb.WriteString("Please enter the Reset Code for the card")
+	default:
+		sb.WriteString("Please enter the PIN")
+	}
+	if p.SerialNumber != "" {
+		sb.WriteString("\n\nNumber: ")
+		sb.WriteString(p.SerialNumber)
+		if p.Holder != "" {
+			sb.WriteString("\nHolder: ")
+			sb.WriteString(p.Holder)
+		}
+	}
+	if p.PIN == SmartcardSigningPIN {
+		fmt.Fprintf(&sb, "\n[sigs done: %d]", p.SignatureCounter)
+	}
+	return sb.String()
+}
+
+// ErrorText returns the error text for p, or the empty string if there is none.
+func (p SmartcardPrompt) ErrorText() string {
+	if p.RemainingAttempts <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Remaining attempts: %d", p.RemainingAttempts)
+}
+
+// Prompt returns the prompt for p.
+func (p SmartcardPrompt) Prompt() string {
+	switch p.PIN {
+	case SmartcardAdminPIN:
+		return "Admin PIN"
+	case SmartcardResetCode:
+		return "Reset Code"
+	default:
+		return "PIN"
+	}
+}
+
+// WithSmartcardPrompt sets the description, prompt, and, if there are a
+// limited number of remaining attempts, the error for prompt.
+func WithSmartcardPrompt(prompt SmartcardPrompt) ClientOption {
+	return func(c *Client) {
+		WithDesc(prompt.Desc())(c)
+		WithPrompt(prompt.Prompt())(c)
+		if err := prompt.ErrorText(); err != "" {
+			WithError(err)(c)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package pinentry
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestSmartcardPrompt(t *testing.T) {
+	for i, tc := range []struct {
+		prompt            SmartcardPrompt
+		expectedDesc      string
+		expectedPrompt    string
+		expectedErrorText string
+	}{
+		{
+			expectedDesc:   "Please enter the PIN",
+			expectedPrompt: "PIN",
+		},
+		{
+			prompt: SmartcardPrompt{
+				PIN:          SmartcardSigningPIN,
+				SerialNumber: "D2760001240103040006123456780000",
+			},
+			expectedDesc:   "Please enter the PIN\n\nNumber: D2760001240103040006123456780000\n[sigs done: 0]",
+			expectedPrompt: "PIN",
+		},
+		{
+			prompt: SmartcardPrompt{
+				PIN:               SmartcardAdminPIN,
+				RemainingAttempts: 3,
+			},
+			expectedDesc:      "Please enter the Admin PIN",
+			expectedPrompt:    "Admin PIN",
+			expectedErrorText: "Remaining attempts: 3",
+		},
+		{
+			prompt: SmartcardPrompt{
+				PIN:    SmartcardResetCode,
+				Holder: "Alice",
+			},
+			expectedDesc:   "Please enter the Reset Code for the card",
+			expectedPrompt: "Reset Code",
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tc.expectedDesc, tc.prompt.Desc())
+			assert.Equal(t, tc.expectedPrompt, tc.prompt.Prompt())
+			assert.Equal(t, tc.expectedErrorText, tc.prompt.ErrorText())
+		})
+	}
+}
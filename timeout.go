@@ -0,0 +1,94 @@
+package pinentry
+
+import (
+	"os"
+	"time"
+)
+
+// A TimeoutError is returned when the pinentry process does not complete an
+// operation within its timeout. Op is the operation, either greeting, read, or
+// write. The pinentry process is killed when the timeout expires. It matches
+// os.ErrDeadlineExceeded.
+type TimeoutError struct {
+	Op      string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return "pinentry: " + e.Op + ": timeout after " + e.Timeout.String()
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == os.ErrDeadlineExceeded
+}
+
+// WithReadTimeout sets the maximum time to wait for each line from the
+// pinentry process. This includes the time that the user takes to respond to a
+// prompt, so timeout should be longer than any prompt is expected to be shown.
+// If the timeout expires then the pinentry process is killed and a
+// *TimeoutError is returned. The default is to wait indefinitely.
+func WithReadTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithWriteTimeout sets the maximum time to wait for each line to be written
+// to the pinentry process. If the timeout expires then the pinentry process is
+// killed and a *TimeoutError is returned. The default is to wait indefinitely.
+func WithWriteTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.writeTimeout = timeout
+	}
+}
+
+// readProcessLine reads a line from the pinentry process within the read
+// timeout.
+func (c *Client) readProcessLine() ([]byte, error) {
+	line, err := withTimeout(c, "read", c.readTimeout, func() ([]byte, error) {
+		line, _, err := c.process.ReadLine()
+		return line, err
+	})
+	return line, c.cancelledError(err)
+}
+
+// writeProcess writes data to the pinentry process within the write timeout.
+func (c *Client) writeProcess(data []byte) error {
+	_, err := withTimeout(c, "write", c.writeTimeout, func() (int, error) {
+		return c.process.Write(data)
+	})
+	return c.cancelledError(err)
+}
+
+// withTimeout calls f and, if timeout is positive and expires before f
+// returns, kills the pinentry process and returns a *TimeoutError once f has
+// returned, so that f does not use the process concurrently with later
+// operations.
+func withTimeout[T any](c *Client, op string, timeout time.Duration, f func() (T, error)) (T, error) {
+	if timeout <= 0 {
+		return f()
+	}
+	type resultErr struct {
+		result T
+		err    error
+	}
+	resultErrCh := make(chan resultErr, 1)
+	go func() {
+		result, err := f()
+		resultErrCh <- resultErr{result: result, err: err}
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resultErr := <-resultErrCh:
+		return resultErr.result, resultErr.err
+	case <-timer.C:
+		c.kill()
+		<-resultErrCh
+		var zero T
+		return zero, &TimeoutError{
+			Op:      op,
+			Timeout: timeout,
+		}
+	}
+}
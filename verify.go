@@ -0,0 +1,118 @@
+package pinentry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// An UntrustedBinaryError is returned when the pinentry binary fails
+// verification.
+type UntrustedBinaryError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UntrustedBinaryError) Error() string {
+	return fmt.Sprintf("pinentry: %s: untrusted binary: %s", e.Path, e.Reason)
+}
+
+// WithBinaryAllowlist refuses to execute the pinentry binary unless its path,
+// after resolving symbolic links, is one of paths, and it is owned by a
+// privileged account. On Unix systems, the binary must be owned by root and
+// must not be world-writable. On Windows, it must be owned by Administrators,
+// SYSTEM, or TrustedInstaller. Otherwise, starting the pinentry process fails
+// with an *UntrustedBinaryError. On other systems, starting the pinentry
+// process fails with errors.ErrUnsupported.
+func WithBinaryAllowlist(paths ...string) ClientOption {
+	return func(c *Client) {
+		if c.binaryAllowlist == nil {
+			c.binaryAllowlist = []string{}
+		}
+		c.binaryAllowlist = append(c.binaryAllowlist, paths...)
+	}
+}
+
+// WithBinarySHA256 refuses to execute the pinentry binary unless its SHA-256
+// digest is one of hexDigests. Otherwise, starting the pinentry process fails
+// with an *UntrustedBinaryError.
+func WithBinarySHA256(hexDigests ...string) ClientOption {
+	return func(c *Client) {
+		if c.binarySHA256s == nil {
+			c.binarySHA256s = []string{}
+		}
+		c.binarySHA256s = append(c.binarySHA256s, hexDigests...)
+	}
+}
+
+// verifyBinary resolves the path to the binary name and verifies it against
+// allowlist, returning the resolved path.
+func verifyBinary(name string, allowlist []string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return "", err
+	}
+
+	allowed := false
+	for _, allowedPath := range allowlist {
+		if allowedPath, err := filepath.EvalSymlinks(allowedPath); err == nil && allowedPath == path {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", &UntrustedBinaryError{
+			Path:   path,
+			Reason: "not in allowlist",
+		}
+	}
+
+	if err := verifyOwner(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// verifyBinarySHA256 resolves the path to the binary name and verifies that
+// its SHA-256 digest is one of hexDigests, returning the resolved path.
+func verifyBinarySHA256(name string, hexDigests []string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close() //nolint:errcheck
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	digest := hash.Sum(nil)
+
+	for _, hexDigest := range hexDigests {
+		if expectedDigest, err := hex.DecodeString(hexDigest); err == nil && string(expectedDigest) == string(digest) {
+			return path, nil
+		}
+	}
+	return "", &UntrustedBinaryError{
+		Path:   path,
+		Reason: "SHA-256 digest " + hex.EncodeToString(digest) + " does not match",
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package pinentry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// verifyOwner returns errors.ErrUnsupported as file ownership cannot be
+// checked.
+func verifyOwner(string) error {
+	return fmt.Errorf("binary owner: %w", errors.ErrUnsupported)
+}
@@ -0,0 +1,71 @@
+package pinentry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+)
+
+func TestVerifyBinary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on Windows")
+	}
+
+	tempDir, err := filepath.EvalSymlinks(t.TempDir())
+	assert.NoError(t, err)
+	binary := filepath.Join(tempDir, "pinentry")
+	assert.NoError(t, os.WriteFile(binary, nil, 0o755))
+	symlink := filepath.Join(tempDir, "pinentry-symlink")
+	assert.NoError(t, os.Symlink(binary, symlink))
+
+	_, err = verifyBinary(binary, nil)
+	assert.Equal(t, error(&UntrustedBinaryError{Path: binary, Reason: "not in allowlist"}), err)
+
+	path, err := verifyBinary(symlink, []string{binary})
+	if os.Getuid() == 0 {
+		assert.NoError(t, err)
+		assert.Equal(t, binary, path)
+	} else {
+		assert.Equal(t, error(&UntrustedBinaryError{Path: binary, Reason: "not owned by root"}), err)
+	}
+
+	assert.NoError(t, os.Chmod(binary, 0o757))
+	_, err = verifyBinary(binary, []string{symlink})
+	assert.Equal(t, error(&UntrustedBinaryError{Path: binary, Reason: "world-writable"}), err)
+}
+
+func TestVerifyBinarySHA256(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "pinentry")
+	assert.NoError(t, os.WriteFile(binary, []byte("pinentry"), 0o755))
+	digest := sha256.Sum256([]byte("pinentry"))
+	hexDigest := hex.EncodeToString(digest[:])
+
+	path, err := verifyBinarySHA256(binary, []string{"00", hexDigest})
+	assert.NoError(t, err)
+	assert.Equal(t, binary, path)
+
+	_, err = verifyBinarySHA256(binary, []string{"00"})
+	assert.Equal(t, error(&UntrustedBinaryError{
+		Path:   binary,
+		Reason: "SHA-256 digest " + hexDigest + " does not match",
+	}), err)
+}
+
+func TestVerifyBinaryChroot(t *testing.T) {
+	for _, option := range []ClientOption{
+		WithBinaryAllowlist("/usr/bin/pinentry"),
+		WithBinarySHA256("00"),
+	} {
+		_, err := NewClient(
+			WithBinaryName("/usr/bin/pinentry"),
+			WithChroot(t.TempDir()),
+			option,
+		)
+		assert.IsError(t, err, ErrChrootVerification)
+	}
+}
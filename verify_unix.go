@@ -0,0 +1,30 @@
+//go:build unix
+
+package pinentry
+
+import (
+	"os"
+	"syscall"
+)
+
+// verifyOwner verifies that the binary at path is owned by root and is not
+// world-writable.
+func verifyOwner(path string) error {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fileInfo.Mode().Perm()&0o002 != 0 {
+		return &UntrustedBinaryError{
+			Path:   path,
+			Reason: "world-writable",
+		}
+	}
+	if stat, ok := fileInfo.Sys().(*syscall.Stat_t); !ok || stat.Uid != 0 {
+		return &UntrustedBinaryError{
+			Path:   path,
+			Reason: "not owned by root",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package pinentry
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	seFileObject             = 1
+	ownerSecurityInformation = 1
+)
+
+// Security identifiers of accounts that may own trusted binaries, in addition
+// to localSystemSID.
+const (
+	administratorsSID   = "S-1-5-32-544"
+	trustedInstallerSID = "S-1-5-80-956008885-3418522649-1831038044-1853292631-2271478464"
+)
+
+var procGetNamedSecurityInfoW = advapi32.NewProc("GetNamedSecurityInfoW")
+
+// verifyOwner verifies that the binary at path is owned by Administrators,
+// SYSTEM, or TrustedInstaller.
+func verifyOwner(path string) error {
+	pathUTF16, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	var owner *syscall.SID
+	var securityDescriptor syscall.Handle
+	if r1, _, _ := procGetNamedSecurityInfoW.Call(
+		uintptr(unsafe.Pointer(pathUTF16)),
+		seFileObject,
+		ownerSecurityInformation,
+		uintptr(unsafe.Pointer(&owner)),
+		0,
+		0,
+		0,
+		uintptr(unsafe.Pointer(&securityDescriptor)),
+	); r1 != 0 {
+		return syscall.Errno(r1)
+	}
+	defer syscall.LocalFree(securityDescriptor) //nolint:errcheck
+	sid, err := owner.String()
+	if err != nil {
+		return err
+	}
+	switch sid {
+	case administratorsSID, localSystemSID, trustedInstallerSID:
+		return nil
+	default:
+		return &UntrustedBinaryError{
+			Path:   path,
+			Reason: "not owned by Administrators, SYSTEM, or TrustedInstaller: " + sid,
+		}
+	}
+}
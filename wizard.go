@@ -0,0 +1,95 @@
+package pinentry
+
+// A WizardResult is the result of a call to Client.RunWizard.
+type WizardResult struct {
+	// PINs maps the name of each completed GetPIN step to the PIN entered.
+	PINs map[string]string
+	// Confirmations maps the name of each completed confirmation step to
+	// whether the user confirmed.
+	Confirmations map[string]bool
+	// Completed is whether all steps were completed.
+	Completed bool
+}
+
+// A WizardStep is a step in a wizard. It returns false if the wizard should
+// stop.
+type WizardStep func(c *Client, result *WizardResult) (bool, error)
+
+// WizardConfirm returns a step that asks the user to confirm desc and records
+// the response under name. If required is true and the user does not confirm
+// then the wizard stops.
+func WizardConfirm(name, desc string, required bool) WizardStep {
+	return func(c *Client, result *WizardResult) (bool, error) {
+		if err := c.lockedCommand("SETDESC " + escapeText(desc)); err != nil {
+			return false, err
+		}
+		confirmed, err := c.Confirm("")
+		if err != nil && !isNotConfirmed(err) {
+			return false, err
+		}
+		result.Confirmations[name] = confirmed
+		return confirmed || !required, nil
+	}
+}
+
+// WizardGetPIN returns a step that gets a PIN from the user with desc and
+// prompt and records it under name. If repeat is not empty then the user is
+// asked to enter the PIN again with repeat as the prompt.
+func WizardGetPIN(name, desc, prompt, repeat string) WizardStep {
+	return func(c *Client, result *WizardResult) (bool, error) {
+		commands := []string{
+			"SETDESC " + escapeText(desc),
+			"SETPROMPT " + escapeText(prompt),
+		}
+		if repeat != "" {
+			commands = append(commands, "SETREPEAT "+escape(repeat))
+		}
+		for _, command := range commands {
+			if err := c.lockedCommand(command); err != nil {
+				return false, err
+			}
+		}
+		getPINResult, err := c.GetPIN()
+		if err != nil {
+			return false, err
+		}
+		result.PINs[name] = getPINResult.PIN
+		return true, nil
+	}
+}
+
+// WizardMessage returns a step that shows the user desc.
+func WizardMessage(desc string) WizardStep {
+	return func(c *Client, _ *WizardResult) (bool, error) {
+		if err := c.lockedCommand("SETDESC " + escapeText(desc)); err != nil {
+			return false, err
+		}
+		return true, c.Message()
+	}
+}
+
+// RunWizard runs steps in order with a shared title, collecting their
+// results. It stops early if a step stops the wizard or returns an error. If
+// the user cancels, the returned result contains the results of the completed
+// steps and an error is returned which can be tested with IsCancelled.
+func (c *Client) RunWizard(title string, steps ...WizardStep) (WizardResult, error) {
+	result := WizardResult{
+		PINs:          make(map[string]string),
+		Confirmations: make(map[string]bool),
+	}
+	if title != "" {
+		if err := c.lockedCommand("SETTITLE " + escapeText(title)); err != nil {
+			return result, err
+		}
+	}
+	for _, step := range steps {
+		switch ok, err := step(c, &result); {
+		case err != nil:
+			return result, err
+		case !ok:
+			return result, nil
+		}
+	}
+	result.Completed = true
+	return result, nil
+}